@@ -2,11 +2,12 @@ package core
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Agent orchestrates conversations with an LLM and executes tools.
@@ -23,10 +24,29 @@ type Agent struct {
 	webErr                 error
 	subAgents              map[string]subAgentEntry
 	subAgentLastOutputPath map[string]string // tracks output_path across revision cycles
+	sessionID              string
+	sessionCreatedAt       time.Time
+	lastCompactUsage       *ResponseUsage // usage reading that last triggered auto compaction
+	checkpoints            *checkpointStore
+	jobs                   *shellJobs    // background run_shell jobs, killed by Close
+	python                 *pythonKernel // persistent python_runtime process, killed by Close
+	shellPolicy            ShellPolicy
+	approvals              *ApprovalStore
+	spillMu                sync.Mutex
+	spills                 []string   // temp files holding truncated tool output, removed by Close
+	fallbackMu             sync.Mutex // serializes OnSandboxFallback calls
 
 	// Optional hooks - nil means default behavior (auto-execute, no output)
 
-	// OnToolCall is called before executing a tool.
+	// OnToolStart is called for every tool call before it runs, including
+	// calls the permission rules allow or deny. Use it to display or log calls.
+	OnToolStart func(name string, args map[string]any)
+
+	// OnToolCall is called before executing a tool the permission rules ask
+	// about; allowed calls run and denied calls are skipped without it, so
+	// it no longer sees every call (use OnToolStart for that). Use
+	// PreviewTool to show the diff a file-changing call would make, and
+	// Approvals to record the user's answer.
 	// Return false to skip tool execution (sends "cancelled by user" as result).
 	OnToolCall func(name string, args map[string]any) bool
 
@@ -49,16 +69,24 @@ type Agent struct {
 	// OnSubAgentEnd is called when a subagent completes execution.
 	OnSubAgentEnd func(name string)
 
-	// OnSandboxFallback is called when sandbox blocks a command.
+	// OnSandboxFallback is called when sandbox blocks a command or a file tool
+	// path; for file tools command is "<tool> <path>". Calls are never
+	// concurrent, even when read-only tool calls run in parallel.
 	// Return true to execute outside sandbox (requires approval), false to cancel.
 	OnSandboxFallback func(command string, reason string) bool
 
 	// OnContextUsage is called after each LLM response with the prompt usage percentage and cumulative cost.
 	OnContextUsage func(pct float64, totalCost float64)
 
-	// OnResponseModel is called after each LLM response with the model identifier actually used.
+	// OnResponseModel is called after each LLM response with the model identifier actually used,
+	// which is a Config.FallbackModels entry when the primary model failed.
 	OnResponseModel func(model string)
 
+	// OnAutoCompact is called after Chat summarizes and compacts history because prompt
+	// usage reached Config.AutoCompactThreshold. Receives the triggering usage percentage
+	// and the number of messages replaced by the summary.
+	OnAutoCompact func(usagePct float64, replaced int)
+
 	// OnContentDelta is called for each text content fragment during streaming.
 	// If set, enables streaming mode. If nil, responses are buffered (non-streaming).
 	OnContentDelta func(delta string)
@@ -66,6 +94,12 @@ type Agent struct {
 	// OnReasoningDelta is called for each reasoning text fragment during streaming.
 	OnReasoningDelta func(delta string)
 
+	// OnToolOutputDelta is called with each chunk of output run_shell and
+	// python_runtime produce while the command runs, keyed by tool call ID.
+	// stream is "stdout" or "stderr". It is called from the command's output
+	// goroutines; the final ToolResult still carries the full output.
+	OnToolOutputDelta func(toolCallID, stream, delta string)
+
 	// OnSubAgentApproval is called after subagent hooks complete when an
 	// ApprovalHook is registered. Blocks until the user responds.
 	// If nil, ApprovalHook auto-approves.
@@ -91,22 +125,45 @@ func NewAgent(config Config) (*Agent, error) {
 	}
 	InitSandbox(sandboxCfg)
 
-	a := &Agent{config: config, client: client, subAgents: make(map[string]subAgentEntry)}
+	a := &Agent{config: config, client: client, subAgents: make(map[string]subAgentEntry), checkpoints: &checkpointStore{}, jobs: &shellJobs{}}
 
 	// Build tool registry — all tools get the same treatment.
 	// Dependencies are injected as closures that capture the agent pointer.
 	// Hooks (OnSandboxFallback etc.) are set by the caller after NewAgent returns;
 	// closures evaluate them at call time, so they pick up the final values.
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
 	shellPolicy := config.ShellPolicy
-	shellExec := func(req ShellRequest) ToolResult {
-		return ExecuteShellRequest(req, shellPolicy, a.OnSandboxFallback)
+	if shellPolicy == nil {
+		if shellPolicy, err = LoadShellPolicy(cwd); err != nil {
+			return nil, err
+		}
+	}
+	a.shellPolicy = shellPolicy
+	if a.approvals, err = NewApprovalStore(cwd); err != nil {
+		return nil, err
 	}
+	shellExec := func(ctx context.Context, req ShellRequest) ToolResult {
+		if req.Background {
+			return a.jobs.start(req, shellPolicy)
+		}
+		req.OnOutput = a.shellOutputHook(ctx)
+		return ExecuteShellRequestContext(ctx, req, shellPolicy, a.sandboxFallback)
+	}
+	fileFallback := a.sandboxFallback
 	a.registry = NewRegistry()
-	a.registry.Register(ReadFileTool())
-	a.registry.Register(WriteFileTool())
-	a.registry.Register(EditFileTool())
-	a.registry.Register(RunShellTool(shellExec))
-	a.registry.Register(PythonRuntimeTool(shellExec))
+	a.registry.Register(ReadFileToolWithFallback(fileFallback))
+	a.registry.Register(WriteFileToolWithFallback(fileFallback))
+	a.registry.Register(EditFileToolWithFallback(fileFallback))
+	a.registry.Register(ApplyPatchTool(fileFallback))
+	a.registry.Register(RunShellToolContext(shellExec))
+	a.registry.Register(ShellJobOutputTool(a.jobs.output))
+	a.registry.Register(ShellJobStatusTool(a.jobs.status))
+	a.registry.Register(ShellJobKillTool(a.jobs.kill))
+	a.python = &pythonKernel{policy: shellPolicy, onFallback: fileFallback}
+	a.registry.Register(PythonRuntimeToolContext(shellExec, a.python.run))
 	a.registry.Register(CompactContextTool(a.compactMessages))
 
 	// Register enter_plan_mode tool with injected subagent runner.
@@ -160,8 +217,13 @@ func NewAgent(config Config) (*Agent, error) {
 
 	a.apiTools = a.registry.Tools(config.AllowedTools...)
 
-	if config.SystemPrompt != "" {
-		a.msgs = append(a.msgs, Message{Role: "system", Content: config.SystemPrompt})
+	if config.ResumeSession != nil {
+		a.restoreSession(config.ResumeSession)
+	} else {
+		a.newSession()
+		if config.SystemPrompt != "" {
+			a.msgs = append(a.msgs, Message{Role: "system", Content: config.SystemPrompt})
+		}
 	}
 
 	// Register default middleware.
@@ -173,14 +235,45 @@ func NewAgent(config Config) (*Agent, error) {
 	return a, nil
 }
 
-// Close releases resources owned by the agent.
+// sandboxFallback asks OnSandboxFallback whether to run a blocked command or
+// file access outside the sandbox, one prompt at a time: parallel read-only
+// tool calls can be blocked together.
+func (a *Agent) sandboxFallback(command, reason string) bool {
+	if a.OnSandboxFallback == nil {
+		return false
+	}
+	a.fallbackMu.Lock()
+	defer a.fallbackMu.Unlock()
+	return a.OnSandboxFallback(command, reason)
+}
+
+// Close releases resources owned by the agent, killing its background jobs
+// and removing saved tool output.
 func (a *Agent) Close() error {
-	if a == nil || a.codeSearch == nil {
+	if a == nil {
+		return nil
+	}
+	if a.jobs != nil {
+		a.jobs.closeAll()
+	}
+	if a.python != nil {
+		a.python.close()
+	}
+	a.removeSpills()
+	if a.codeSearch == nil {
 		return nil
 	}
 	return a.codeSearch.Close()
 }
 
+// Approvals returns the store hosts record approval decisions in.
+func (a *Agent) Approvals() *ApprovalStore {
+	if a == nil {
+		return nil
+	}
+	return a.approvals
+}
+
 // CodeSearchService returns the initialized code-search service, if available.
 func (a *Agent) CodeSearchService() *CodeSearchService {
 	if a == nil {
@@ -245,9 +338,12 @@ func (a *Agent) Use(mw ...MessageMiddleware) {
 
 // Chat sends a user message and processes the complete turn.
 // Blocks until the assistant provides a final text response.
-// Tool calls are executed automatically unless OnToolCall returns false.
+// Tool calls run when the permission rules allow them, or when they ask
+// and OnToolCall doesn't return false.
 // On the first call, pre-tasks are automatically executed if configured.
 func (a *Agent) Chat(ctx context.Context, input string) (string, error) {
+	defer a.autoSaveSession()
+
 	// Auto-run pre-tasks on first call
 	if !a.preTasksDone && len(a.config.PreTasks) > 0 {
 		if err := a.runPreTasks(ctx); err != nil {
@@ -256,6 +352,9 @@ func (a *Agent) Chat(ctx context.Context, input string) (string, error) {
 		a.preTasksDone = true
 	}
 
+	if a.checkpoints != nil {
+		a.checkpoints.begin(input, a.msgs)
+	}
 	a.msgs = append(a.msgs, Message{Role: "user", Content: input})
 
 	toolCallsSinceLastSummary := 0
@@ -263,6 +362,9 @@ func (a *Agent) Chat(ctx context.Context, input string) (string, error) {
 		if reachedTurnLimit(turns, a.config.MaxChatTurns) {
 			return "", ErrMaxTurnsExceeded
 		}
+		if err := a.maybeAutoCompact(ctx); err != nil {
+			return "", err
+		}
 		msg, err := a.chatCompletion(ctx)
 		if err != nil {
 			return "", err
@@ -311,41 +413,19 @@ func (a *Agent) Chat(ctx context.Context, input string) (string, error) {
 		hitCap := a.config.MaxToolCallsPerTurn > 0 && toolCallsSinceLastSummary >= a.config.MaxToolCallsPerTurn
 
 		// Execute tools and collect results
-		var toolResults []Message
-		cancelled := false
-
-		for i, tc := range toRun {
-			var args map[string]any
-			json.Unmarshal([]byte(tc.Function.Arguments), &args)
-
-			// Check if tool should execute
-			if a.OnToolCall != nil && !a.OnToolCall(tc.Function.Name, args) {
-				cancelled = true
-				break
-			}
-
-			var result ToolResult
-			tool, ok := a.registry.Get(tc.Function.Name)
-			if !ok {
-				result = ToolResult{Success: false, Error: fmt.Errorf("unknown tool: %s", tc.Function.Name), Status: "fail: unknown tool"}
-			} else {
-				result = tool.Execute(args)
-			}
+		toolResults, cancelled := a.runToolCalls(ctx, toRun, nil)
 
-			if a.OnToolDone != nil {
-				a.OnToolDone(tc.Function.Name, args, result)
-			}
+		// Gentle nudge on last result when we hit the cap (like read_file truncation message)
+		if hitCap && !cancelled && len(toolResults) == len(toRun) {
+			last := &toolResults[len(toolResults)-1]
+			out, _ := last.Content.(string)
+			last.Content = out + fmt.Sprintf("\n\n[You've used %d tool calls this round. Consider summarizing what you've learned before making more tool calls to save context.]", a.config.MaxToolCallsPerTurn)
+		}
 
-			out := result.Output
-			// Gentle nudge on last result when we hit the cap (like read_file truncation message)
-			if hitCap && i == len(toRun)-1 {
-				out = out + fmt.Sprintf("\n\n[You've used %d tool calls this round. Consider summarizing what you've learned before making more tool calls to save context.]", a.config.MaxToolCallsPerTurn)
-			}
-			toolResults = append(toolResults, Message{
-				Role:       "tool",
-				ToolCallID: tc.ID,
-				Content:    out,
-			})
+		// Chat context cancelled mid-batch: drop the partial turn so history
+		// never holds tool calls without matching results.
+		if err := ctx.Err(); err != nil {
+			return "", err
 		}
 
 		// If cancelled, we never appended the assistant message; nothing to rollback
@@ -360,6 +440,7 @@ func (a *Agent) Chat(ctx context.Context, input string) (string, error) {
 		}
 		a.msgs = append(a.msgs, assistantMsg)
 		a.msgs = append(a.msgs, toolResults...)
+		a.autoSaveSession()
 
 		// Summary round when we hit the cap: ask for summary, then compact and continue loop
 		if hitCap {
@@ -458,7 +539,9 @@ func (a *Agent) WarmModelUsageLimits(ctx context.Context, model string) error {
 }
 
 // Reset clears conversation history (keeps system prompt if configured).
+// The agent starts a new session; a previously saved session is left intact.
 func (a *Agent) Reset() {
+	a.newSession()
 	a.msgs = nil
 	if a.config.SystemPrompt != "" {
 		a.msgs = append(a.msgs, Message{Role: "system", Content: a.config.SystemPrompt})
@@ -567,37 +650,10 @@ func (a *Agent) runPreTask(ctx context.Context, task PreTaskConfig) error {
 		}
 
 		// Execute tools and collect results
-		var toolResults []Message
-		cancelled := false
-
-		for _, tc := range msg.ToolCalls {
-			var args map[string]any
-			json.Unmarshal([]byte(tc.Function.Arguments), &args)
-
-			// Check if tool should execute
-			if a.OnToolCall != nil && !a.OnToolCall(tc.Function.Name, args) {
-				cancelled = true
-				break
-			}
-
-			var result ToolResult
-			tool, ok := a.registry.Get(tc.Function.Name)
-			if !ok {
-				result = ToolResult{Success: false, Error: fmt.Errorf("unknown tool: %s", tc.Function.Name), Status: "fail: unknown tool"}
-			} else {
-				result = tool.Execute(args)
-			}
+		toolResults, cancelled := a.runToolCalls(ctx, msg.ToolCalls, nil)
 
-			if a.OnToolDone != nil {
-				a.OnToolDone(tc.Function.Name, args, result)
-			}
-
-			// Append tool result message
-			toolResults = append(toolResults, Message{
-				Role:       "tool",
-				ToolCallID: tc.ID,
-				Content:    result.Output,
-			})
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
 		// If cancelled, rollback and skip remaining pre-tasks
@@ -689,45 +745,10 @@ func (a *Agent) RunSubAgent(ctx context.Context, sa SubAgent, input string) (*Su
 			return result, nil
 		}
 
-		var toolResults []Message
-		cancelled := false
-
-		for _, tc := range msg.ToolCalls {
-			var args map[string]any
-			json.Unmarshal([]byte(tc.Function.Arguments), &args)
-
-			// Runtime rejection for disallowed tools.
-			if len(allowSet) > 0 && !allowSet[tc.Function.Name] {
-				toolResults = append(toolResults, Message{
-					Role:       "tool",
-					ToolCallID: tc.ID,
-					Content:    fmt.Sprintf("error: tool %q is not available in this mode", tc.Function.Name),
-				})
-				continue
-			}
-
-			if a.OnToolCall != nil && !a.OnToolCall(tc.Function.Name, args) {
-				cancelled = true
-				break
-			}
+		toolResults, cancelled := a.runToolCalls(ctx, msg.ToolCalls, allowSet)
 
-			var result ToolResult
-			tool, ok := a.registry.Get(tc.Function.Name)
-			if !ok {
-				result = ToolResult{Success: false, Error: fmt.Errorf("unknown tool: %s", tc.Function.Name), Status: "fail: unknown tool"}
-			} else {
-				result = tool.Execute(args)
-			}
-
-			if a.OnToolDone != nil {
-				a.OnToolDone(tc.Function.Name, args, result)
-			}
-
-			toolResults = append(toolResults, Message{
-				Role:       "tool",
-				ToolCallID: tc.ID,
-				Content:    result.Output,
-			})
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
 
 		if cancelled {
@@ -930,44 +951,10 @@ func (a *Agent) continueSubAgentLoop(
 			return content, nil
 		}
 
-		var toolResults []Message
-		cancelled := false
-
-		for _, tc := range msg.ToolCalls {
-			var args map[string]any
-			json.Unmarshal([]byte(tc.Function.Arguments), &args)
-
-			if len(allowSet) > 0 && !allowSet[tc.Function.Name] {
-				toolResults = append(toolResults, Message{
-					Role:       "tool",
-					ToolCallID: tc.ID,
-					Content:    fmt.Sprintf("error: tool %q is not available in this mode", tc.Function.Name),
-				})
-				continue
-			}
-
-			if a.OnToolCall != nil && !a.OnToolCall(tc.Function.Name, args) {
-				cancelled = true
-				break
-			}
+		toolResults, cancelled := a.runToolCalls(ctx, msg.ToolCalls, allowSet)
 
-			var result ToolResult
-			tool, ok := a.registry.Get(tc.Function.Name)
-			if !ok {
-				result = ToolResult{Success: false, Error: fmt.Errorf("unknown tool: %s", tc.Function.Name), Status: "fail: unknown tool"}
-			} else {
-				result = tool.Execute(args)
-			}
-
-			if a.OnToolDone != nil {
-				a.OnToolDone(tc.Function.Name, args, result)
-			}
-
-			toolResults = append(toolResults, Message{
-				Role:       "tool",
-				ToolCallID: tc.ID,
-				Content:    result.Output,
-			})
+		if err := ctx.Err(); err != nil {
+			return "", err
 		}
 
 		if cancelled {
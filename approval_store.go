@@ -0,0 +1,329 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ProjectApprovalsPath is where project-scope approvals are saved. It
+// supports the same ~ and {cwd} expansion as DefaultSessionDir, with {cwd}
+// the project directory, so approvals are kept in the user's home and a
+// file committed to the project can't grant any.
+const ProjectApprovalsPath = "~/.bono/{cwd}/approvals.json"
+
+// ApprovalScope is how long a recorded approval lasts.
+type ApprovalScope int
+
+const (
+	// ApproveOnce approves the next matching call only.
+	ApproveOnce ApprovalScope = iota
+	// ApproveSession approves matching calls until the agent is closed.
+	ApproveSession
+	// ApproveProject approves matching calls in this project from now on;
+	// the approval is saved to ProjectApprovalsPath.
+	ApproveProject
+)
+
+// Approval lets calls to a tool run without prompting when their arguments
+// match Pattern, in which "*" matches any run of characters and an empty
+// pattern matches anything. What the pattern is matched against depends on
+// the tool: each simple command for run_shell (every one must match), each
+// path for file tools, the URL for web_fetch, the query for web_search and
+// the code for python_runtime. Other tools match their arguments as JSON.
+//
+// A run_shell pattern is itself a command. Its words are matched against the
+// command's, while variable assignments and redirections to or from files
+// must be named by the pattern: "go test *" doesn't approve
+// "CGO_ENABLED=0 go test ./..." or "go test ./... > out.txt", but
+// "CGO_ENABLED=* go test *" and "go test * > *" do.
+type Approval struct {
+	Tool    string        `json:"tool"`
+	Pattern string        `json:"pattern,omitempty"`
+	Scope   ApprovalScope `json:"-"`
+}
+
+// ApprovalStore holds the approvals a host has recorded. It is safe for
+// concurrent use.
+type ApprovalStore struct {
+	root string // project directory; file paths are matched relative to it
+
+	mu        sync.Mutex
+	approvals []Approval
+}
+
+// NewApprovalStore returns a store for the project in root, loaded with the
+// project's saved approvals. An empty root keeps everything in memory.
+func NewApprovalStore(root string) (*ApprovalStore, error) {
+	s := &ApprovalStore{root: root}
+	if root == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(s.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.approvals); err != nil {
+		return nil, fmt.Errorf("parse approvals %s: %w", s.path(), err)
+	}
+	for i := range s.approvals {
+		s.approvals[i].Scope = ApproveProject
+	}
+	return s, nil
+}
+
+// Record adds an approval. Project-scope approvals are saved at once.
+func (s *ApprovalStore) Record(a Approval) error {
+	a.Tool = normalizeToolName(a.Tool)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvals = append(s.approvals, a)
+	if a.Scope == ApproveProject {
+		return s.save()
+	}
+	return nil
+}
+
+// Approvals returns the recorded approvals.
+func (s *ApprovalStore) Approvals() []Approval {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Approval(nil), s.approvals...)
+}
+
+// Approved reports whether recorded approvals cover a call, consuming the
+// once-scope approvals it used.
+func (s *ApprovalStore) Approved(tool string, args map[string]any) bool {
+	return s.approved(tool, args, true)
+}
+
+// approved is Approved; consume controls whether once-scope approvals are
+// used up.
+func (s *ApprovalStore) approved(tool string, args map[string]any, consume bool) bool {
+	tool = normalizeToolName(tool)
+	subjects := approvalSubjects(tool, args, s.root)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	used := make(map[int]bool)
+	for _, subject := range subjects {
+		match := -1
+		for i, a := range s.approvals {
+			if a.Tool != tool || !subject(a.Pattern) {
+				continue
+			}
+			// Prefer lasting approvals so a once approval isn't spent needlessly.
+			if match < 0 || s.approvals[match].Scope == ApproveOnce {
+				match = i
+			}
+		}
+		if match < 0 {
+			return false
+		}
+		used[match] = true
+	}
+	if !consume {
+		return true
+	}
+
+	kept := s.approvals[:0]
+	for i, a := range s.approvals {
+		if !used[i] || a.Scope != ApproveOnce {
+			kept = append(kept, a)
+		}
+	}
+	s.approvals = kept
+	return true
+}
+
+func (s *ApprovalStore) path() string {
+	return expandDirTemplate(ProjectApprovalsPath, s.root)
+}
+
+// save writes the project-scope approvals. Called with s.mu held.
+func (s *ApprovalStore) save() error {
+	if s.root == "" {
+		return nil
+	}
+	var project []Approval
+	for _, a := range s.approvals {
+		if a.Scope == ApproveProject {
+			project = append(project, a)
+		}
+	}
+	data, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path()), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(), append(data, '\n'), 0o644)
+}
+
+// normalizeToolName converts a tool name to snake case, so "WebFetch",
+// "web-fetch" and "web_fetch" name the same tool.
+func normalizeToolName(name string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case r == '-' || r == ' ':
+			r = '_'
+		case unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// approvalSubjects returns a matcher for each part of a call that approval
+// patterns must cover; the call is approved only if every one matches.
+func approvalSubjects(tool string, args map[string]any, root string) []func(pattern string) bool {
+	str := func(key string) string {
+		s, _ := args[key].(string)
+		return s
+	}
+	var subjects []string
+	switch tool {
+	case "run_shell":
+		// A command that doesn't parse into commands can't be checked, so it
+		// matches no approval.
+		cmds, err := ParseShellCommands(str("command"))
+		if err != nil || len(cmds) == 0 {
+			return []func(string) bool{func(string) bool { return false }}
+		}
+		matchers := make([]func(string) bool, len(cmds))
+		for i, cmd := range cmds {
+			matchers[i] = func(pattern string) bool { return matchCommandApproval(pattern, cmd) }
+		}
+		return matchers
+	case "python_runtime":
+		subjects = []string{str("code")}
+	case "read_file", "write_file", "edit_file":
+		subjects = []string{approvalPath(str("path"), root)}
+	case "apply_patch":
+		for _, p := range patchPaths(args) {
+			subjects = append(subjects, approvalPath(p, root))
+		}
+		if len(subjects) == 0 {
+			subjects = []string{str("patch")}
+		}
+	case "web_fetch":
+		subjects = []string{str("url")}
+	case "web_search":
+		subjects = []string{str("query")}
+	default:
+		data, _ := json.Marshal(args)
+		subjects = []string{string(data)}
+	}
+	matchers := make([]func(string) bool, len(subjects))
+	for i, subject := range subjects {
+		matchers[i] = func(pattern string) bool { return matchWildcard(pattern, subject) }
+	}
+	return matchers
+}
+
+// matchCommandApproval matches a simple command against a run_shell
+// approval pattern, parsed as a command. Words are matched as one string
+// with matchWildcard; the command's variable assignments and file
+// redirections must each be matched by one the pattern names.
+func matchCommandApproval(pattern string, cmd ShellCommand) bool {
+	if pattern == "" {
+		return true
+	}
+	parsed, err := ParseShellCommands(pattern)
+	if err != nil || len(parsed) == 0 {
+		return false
+	}
+	p := parsed[0]
+	if !matchWildcard(strings.Join(p.Argv, " "), strings.Join(cmd.Argv, " ")) {
+		return false
+	}
+
+	if len(p.Env) != len(cmd.Env) {
+		return false
+	}
+	for name, value := range cmd.Env {
+		want, ok := p.Env[name]
+		if !ok || !matchWildcard(want, value) {
+			return false
+		}
+	}
+
+	want, got := fileRedirects(p), fileRedirects(cmd)
+	if len(want) != len(got) {
+		return false
+	}
+	for i, r := range got {
+		if want[i].Op != r.Op || !matchWildcard(want[i].Target, r.Target) {
+			return false
+		}
+	}
+	return true
+}
+
+// fileRedirects returns cmd's redirections that read or write files,
+// leaving out descriptor duplications such as 2>&1 and here-documents.
+func fileRedirects(cmd ShellCommand) []ShellRedirect {
+	var files []ShellRedirect
+	for _, r := range cmd.Redirects {
+		dup := strings.HasSuffix(r.Op, "&") && (strings.Trim(r.Target, "0123456789") == "" || r.Target == "-")
+		if strings.Contains(r.Op, "<<") || dup {
+			continue
+		}
+		files = append(files, r)
+	}
+	return files
+}
+
+// approvalPath returns path relative to root when it is inside root, so
+// saved approvals don't depend on where the project is checked out, and
+// absolute otherwise.
+func approvalPath(path, root string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if root != "" && pathWithin(root, abs) {
+		if rel, err := filepath.Rel(root, abs); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return abs
+}
+
+// matchWildcard matches s against pattern, where "*" matches any run of
+// characters, including none.
+func matchWildcard(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return s == pattern
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, last)
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApprovalStoreScopes(t *testing.T) {
+	root, home := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	s, err := NewApprovalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shell := func(command string) map[string]any { return map[string]any{"command": command} }
+
+	s.Record(Approval{Tool: "run_shell", Pattern: "make test*", Scope: ApproveOnce})
+	if !s.Approved("run_shell", shell("make test -v")) {
+		t.Fatal("once approval not applied")
+	}
+	if s.Approved("run_shell", shell("make test")) {
+		t.Fatal("once approval applied twice")
+	}
+
+	s.Record(Approval{Tool: "Run-Shell", Pattern: "go test *", Scope: ApproveSession})
+	s.Record(Approval{Tool: "run_shell", Pattern: "cd *", Scope: ApproveSession})
+	for command, want := range map[string]bool{
+		"go test ./...":                 true,
+		"cd pkg && go test ./...":       true,
+		"go test ./... && rm -rf /":     false,
+		"bash -c 'go test ./x; curl x'": false,
+	} {
+		if got := s.Approved("run_shell", shell(command)); got != want {
+			t.Errorf("Approved(%q) = %v, want %v", command, got, want)
+		}
+	}
+
+	if err := s.Record(Approval{Tool: "write_file", Pattern: "docs/*", Scope: ApproveProject}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Approved("write_file", map[string]any{"path": filepath.Join(root, "docs", "a.md")}) {
+		t.Fatal("project approval should match a path inside the project")
+	}
+	if s.Approved("write_file", map[string]any{"path": "/tmp/docs/a.md"}) {
+		t.Fatal("project approval matched a path outside the project")
+	}
+
+	reloaded, err := NewApprovalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reloaded.Approvals()
+	if len(got) != 1 || got[0] != (Approval{Tool: "write_file", Pattern: "docs/*", Scope: ApproveProject}) {
+		t.Fatalf("reloaded approvals = %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".bono", root, "approvals.json")); err != nil {
+		t.Fatalf("project approvals not saved under the home directory: %v", err)
+	}
+}
+
+func TestApprovalStoreShellEnvAndRedirects(t *testing.T) {
+	s, _ := NewApprovalStore("")
+	s.Record(Approval{Tool: "run_shell", Pattern: "echo *", Scope: ApproveSession})
+	s.Record(Approval{Tool: "run_shell", Pattern: "go test *", Scope: ApproveSession})
+	s.Record(Approval{Tool: "run_shell", Pattern: "CGO_ENABLED=* go build *", Scope: ApproveSession})
+	s.Record(Approval{Tool: "run_shell", Pattern: "make * > build.log", Scope: ApproveSession})
+
+	for command, want := range map[string]bool{
+		"echo hello":                         true,
+		"go test ./... 2>&1":                 true,
+		"echo x > ~/.bashrc":                 false,
+		"echo x >& ~/.bashrc":                false,
+		"LD_PRELOAD=/tmp/x.so go test ./...": false,
+		"env LD_PRELOAD=/tmp/x.so go test":   false,
+		"CGO_ENABLED=0 go build ./cmd":       true,
+		"CGO_ENABLED=0 CC=x go build ./cmd":  false,
+		"make all > build.log":               true,
+		"make all > /etc/motd":               false,
+		"go test a; case x in":               false,
+		"":                                   false,
+	} {
+		if got := s.Approved("run_shell", map[string]any{"command": command}); got != want {
+			t.Errorf("Approved(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
+
+func TestApprovalStoreIgnoresWorkingTree(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	writeTestFiles(t, root, map[string]string{".bono/approvals.json": `[{"tool": "run_shell"}]`})
+
+	s, err := NewApprovalStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Approved("run_shell", map[string]any{"command": "rm -rf /"}) {
+		t.Fatal("approvals file in the working tree was trusted")
+	}
+}
+
+func TestCheckPermissionConsultsStore(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	approvals, _ := NewApprovalStore("")
+	a := newToolCallTestAgent(1, RunShellTool(nil), WebFetchTool(nil))
+	a.approvals = approvals
+	shell := map[string]any{"command": "npm install"}
+
+	if d := a.CheckPermission("run_shell", shell); d.Permission != PermissionAsk {
+		t.Fatalf("host-routed shell call without a recorded approval = %+v", d)
+	}
+	approvals.Record(Approval{Tool: "run_shell", Pattern: "npm install", Scope: ApproveOnce})
+	for range 2 {
+		if d := a.CheckPermission("run_shell", shell); d.Permission != PermissionAllow {
+			t.Fatalf("recorded approval not consulted: %+v", d)
+		}
+	}
+	if !approvals.Approved("run_shell", shell) || approvals.Approved("run_shell", shell) {
+		t.Fatal("CheckPermission should not use up a once approval")
+	}
+	if d := a.CheckPermission("WebFetch", map[string]any{"url": "https://example.com"}); d.Permission != PermissionAllow {
+		t.Fatalf("tool Permission should still apply: %+v", d)
+	}
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"log"
+	"strings"
+)
+
+// defaultAutoCompactKeepExchanges is used when Config.AutoCompactKeepTools is 0.
+const defaultAutoCompactKeepExchanges = 3
+
+const autoCompactPrompt = "The conversation is approaching the context limit and older messages will be replaced with your summary. Write a working-state summary covering: the task, key findings, changes made, decisions and rationale, and what's pending. Focus on what's needed to continue — skip files read but not relevant, commands that didn't produce useful results, etc. Reply with your summary only (no tool calls)."
+
+// maybeAutoCompact summarizes and compacts history when the last response's
+// PromptUsagePct reached Config.AutoCompactThreshold. The system prompt, the
+// latest user request and the most recent tool exchanges are kept verbatim.
+// Summarization failures are logged and the turn continues uncompacted;
+// only context cancellation is returned.
+func (a *Agent) maybeAutoCompact(ctx context.Context) error {
+	threshold := a.config.AutoCompactThreshold
+	if threshold <= 0 {
+		return nil
+	}
+	usage := a.client.LastUsage()
+	if usage == nil || usage.PromptUsagePct == nil || *usage.PromptUsagePct < threshold {
+		return nil
+	}
+	// Usage is only refreshed by the next response; don't compact twice on the same reading.
+	if usage == a.lastCompactUsage {
+		return nil
+	}
+	a.lastCompactUsage = usage
+
+	keepN := a.config.AutoCompactKeepTools
+	if keepN <= 0 {
+		keepN = defaultAutoCompactKeepExchanges
+	}
+	keep, dropped := autoCompactKeep(a.msgs, keepN)
+	if dropped == 0 {
+		return nil
+	}
+
+	// The tools are still sent: providers such as the Messages API reject
+	// history holding tool calls and results without them.
+	req := append(append([]Message(nil), a.msgs...), Message{Role: "user", Content: autoCompactPrompt})
+	msg, err := a.client.ChatCompletionWithTools(ctx, req, a.apiTools)
+	// The summarization response replaces the reading; it still reflects the full history.
+	a.lastCompactUsage = a.client.LastUsage()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		log.Printf("auto compact: summarize: %v", err)
+		return nil
+	}
+	if len(msg.ToolCalls) > 0 {
+		log.Printf("auto compact: model called tools instead of summarizing")
+		return nil
+	}
+	summary := strings.TrimSpace(messageContent(msg))
+	if summary == "" {
+		log.Printf("auto compact: model returned an empty summary")
+		return nil
+	}
+
+	if res := a.compactMessages(summary); !res.Success {
+		log.Printf("auto compact: %s", res.Status)
+		return nil
+	}
+	a.msgs = append(a.msgs, keep...)
+	a.autoSaveSession()
+
+	if a.OnAutoCompact != nil {
+		a.OnAutoCompact(*usage.PromptUsagePct, dropped)
+	}
+	return nil
+}
+
+// autoCompactKeep selects the messages auto compaction keeps verbatim: the
+// latest user request and the last n tool exchanges (an assistant message with
+// tool calls plus its tool results), in their original order. dropped counts
+// the non-system messages that will be replaced by the summary.
+func autoCompactKeep(msgs []Message, n int) (keep []Message, dropped int) {
+	kept := make([]bool, len(msgs))
+
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			kept[i] = true
+			break
+		}
+	}
+
+	exchanges := 0
+	for i := len(msgs) - 1; i >= 0 && exchanges < n; i-- {
+		if msgs[i].Role != "assistant" || len(msgs[i].ToolCalls) == 0 {
+			continue
+		}
+		kept[i] = true
+		for j := i + 1; j < len(msgs) && msgs[j].Role == "tool"; j++ {
+			kept[j] = true
+		}
+		exchanges++
+	}
+
+	for i, m := range msgs {
+		switch {
+		case i == 0 && m.Role == "system":
+		case kept[i]:
+			keep = append(keep, m)
+		default:
+			dropped++
+		}
+	}
+	return keep, dropped
+}
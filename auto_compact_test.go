@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/webforspeed/bono-core/llm"
+)
+
+func toolExchange(id string) []Message {
+	return []Message{
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: id, Type: "function", Function: FunctionCall{Name: "read_file", Arguments: `{"path":"` + id + `"}`}}}},
+		{Role: "tool", ToolCallID: id, Content: "contents of " + id},
+	}
+}
+
+func compactHistory() []Message {
+	msgs := []Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "first task"},
+		{Role: "assistant", Content: "done"},
+		{Role: "user", Content: "second task"},
+	}
+	for _, id := range []string{"c1", "c2", "c3"} {
+		msgs = append(msgs, toolExchange(id)...)
+	}
+	return msgs
+}
+
+func TestAutoCompactKeep(t *testing.T) {
+	keep, dropped := autoCompactKeep(compactHistory(), 2)
+
+	var got []string
+	for _, m := range keep {
+		switch {
+		case m.Role == "user":
+			got = append(got, "user:"+messageContent(&m))
+		case m.Role == "assistant":
+			got = append(got, "call:"+m.ToolCalls[0].ID)
+		case m.Role == "tool":
+			got = append(got, "result:"+m.ToolCallID)
+		}
+	}
+	want := "user:second task,call:c2,result:c2,call:c3,result:c3"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("kept = %s, want %s", strings.Join(got, ","), want)
+	}
+	// first task, its answer, and the c1 exchange.
+	if dropped != 4 {
+		t.Fatalf("dropped = %d, want 4", dropped)
+	}
+}
+
+func TestMaybeAutoCompact(t *testing.T) {
+	p := &scriptedProvider{outcome: map[string][]func() (*llm.Response, error){
+		"primary/model": {func() (*llm.Response, error) {
+			return &llm.Response{Model: "primary/model", Content: "working state"}, nil
+		}},
+	}}
+	client := newFallbackTestClient(t, p, 0)
+	a := &Agent{config: Config{AutoCompactThreshold: 80, AutoCompactKeepTools: 1}, client: client}
+	a.msgs = compactHistory()
+	a.apiTools = []Tool{{Type: "function", Function: ToolFunction{Name: "read_file"}}}
+
+	var firedPct float64
+	var firedReplaced int
+	a.OnAutoCompact = func(pct float64, replaced int) {
+		firedPct, firedReplaced = pct, replaced
+	}
+
+	pct := 79.0
+	client.lastUsage = &ResponseUsage{PromptUsagePct: &pct}
+	if err := a.maybeAutoCompact(context.Background()); err != nil {
+		t.Fatalf("maybeAutoCompact: %v", err)
+	}
+	if len(p.calls) != 0 || len(a.msgs) != 10 {
+		t.Fatalf("compacted below threshold: calls=%v msgs=%d", p.calls, len(a.msgs))
+	}
+
+	pct = 85
+	client.lastUsage = &ResponseUsage{PromptUsagePct: &pct}
+	if err := a.maybeAutoCompact(context.Background()); err != nil {
+		t.Fatalf("maybeAutoCompact: %v", err)
+	}
+	if len(p.calls) != 1 {
+		t.Fatalf("summarization calls = %d, want 1", len(p.calls))
+	}
+	sent := p.reqs[0]
+	if len(sent.Tools) != 1 || !slices.ContainsFunc(sent.Messages, func(m llm.Message) bool { return len(m.ToolCalls) > 0 }) {
+		t.Fatalf("summarization request must carry the tool calls with the tools: tools=%d messages=%+v", len(sent.Tools), sent.Messages)
+	}
+	if len(a.msgs) != 5 {
+		t.Fatalf("msgs after compaction = %d, want 5: %+v", len(a.msgs), a.msgs)
+	}
+	if a.msgs[0].Content != "sys" {
+		t.Fatalf("system prompt not kept: %+v", a.msgs[0])
+	}
+	if got := messageContent(&a.msgs[1]); !strings.Contains(got, "working state") {
+		t.Fatalf("summary message = %q", got)
+	}
+	if got := messageContent(&a.msgs[2]); got != "second task" {
+		t.Fatalf("latest user request not kept: %q", got)
+	}
+	if a.msgs[3].ToolCalls[0].ID != "c3" || a.msgs[4].ToolCallID != "c3" {
+		t.Fatalf("latest tool exchange not kept: %+v", a.msgs[3:])
+	}
+	if firedPct != 85 || firedReplaced != 6 {
+		t.Fatalf("OnAutoCompact(%v, %d), want (85, 6)", firedPct, firedReplaced)
+	}
+
+	// The summarization response's own reading must not trigger another round.
+	if err := a.maybeAutoCompact(context.Background()); err != nil {
+		t.Fatalf("maybeAutoCompact: %v", err)
+	}
+	if len(p.calls) != 1 {
+		t.Fatalf("compacted twice on the same reading: %d calls", len(p.calls))
+	}
+}
+
+func TestMaybeAutoCompactIgnoresToolCalls(t *testing.T) {
+	p := &scriptedProvider{outcome: map[string][]func() (*llm.Response, error){
+		"primary/model": {func() (*llm.Response, error) {
+			return &llm.Response{Model: "primary/model", Content: "let me look", ToolCalls: []llm.ToolCall{{ID: "x", Name: "read_file"}}}, nil
+		}},
+	}}
+	client := newFallbackTestClient(t, p, 0)
+	a := &Agent{config: Config{AutoCompactThreshold: 80, AutoCompactKeepTools: 1}, client: client}
+	a.msgs = compactHistory()
+
+	pct := 85.0
+	client.lastUsage = &ResponseUsage{PromptUsagePct: &pct}
+	if err := a.maybeAutoCompact(context.Background()); err != nil {
+		t.Fatalf("maybeAutoCompact: %v", err)
+	}
+	if len(a.msgs) != 10 {
+		t.Fatalf("compacted with a tool-call reply as the summary: %d msgs", len(a.msgs))
+	}
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCheckpointFileSize caps the size of a file snapshotted before a write.
+// Larger files are modified without a checkpoint.
+const maxCheckpointFileSize = 8 << 20
+
+// Checkpoint describes the files modified during one chat turn.
+type Checkpoint struct {
+	Turn      int       // Turn number, increasing across the agent's lifetime
+	Input     string    // User input that started the turn; empty for edits made outside Chat
+	CreatedAt time.Time // When the turn started
+	Files     []string  // Absolute paths modified during the turn, in first-touch order
+}
+
+// fileSnapshot is a file's state before the first write in a turn.
+type fileSnapshot struct {
+	path    string
+	existed bool
+	content []byte
+	mode    os.FileMode
+}
+
+type turnCheckpoint struct {
+	Checkpoint
+	history   []Message // conversation before the turn's user input
+	snapshots map[string]fileSnapshot
+}
+
+// checkpointStore keeps in-memory file snapshots grouped by chat turn.
+// It needs no VCS, so it works in any directory.
+type checkpointStore struct {
+	mu       sync.Mutex
+	turns    []*turnCheckpoint
+	lastTurn int
+	open     bool // a turn is accepting snapshots
+}
+
+// begin opens a new turn. history is the conversation before the turn;
+// the store keeps it by reference, so callers must not mutate it in place.
+func (s *checkpointStore) begin(input string, history []Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastTurn++
+	s.turns = append(s.turns, &turnCheckpoint{
+		Checkpoint: Checkpoint{Turn: s.lastTurn, Input: input, CreatedAt: time.Now()},
+		history:    history[:len(history):len(history)],
+		snapshots:  make(map[string]fileSnapshot),
+	})
+	s.open = true
+}
+
+// snapshot records each path's current state unless the open turn already has it.
+// Without an open turn, one is started with history as its conversation.
+func (s *checkpointStore) snapshot(paths []string, history []Message) {
+	if len(paths) == 0 {
+		return
+	}
+	s.mu.Lock()
+	open := s.open
+	s.mu.Unlock()
+	if !open {
+		s.begin("", history)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	turn := s.turns[len(s.turns)-1]
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if _, ok := turn.snapshots[abs]; ok {
+			continue
+		}
+		snap, err := readFileSnapshot(abs)
+		if err != nil {
+			log.Printf("checkpoint: %s not snapshotted: %v", abs, err)
+			continue
+		}
+		turn.snapshots[abs] = snap
+		turn.Files = append(turn.Files, abs)
+	}
+}
+
+func readFileSnapshot(path string) (fileSnapshot, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileSnapshot{path: path}, nil
+	}
+	if err != nil {
+		return fileSnapshot{}, err
+	}
+	if info.IsDir() {
+		return fileSnapshot{}, fmt.Errorf("is a directory")
+	}
+	if info.Size() > maxCheckpointFileSize {
+		return fileSnapshot{}, fmt.Errorf("larger than %d bytes", maxCheckpointFileSize)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileSnapshot{}, err
+	}
+	return fileSnapshot{path: path, existed: true, content: content, mode: info.Mode().Perm()}, nil
+}
+
+// since returns the index of turn and, for every file modified in that turn
+// or later, its snapshot from before the first modification.
+func (s *checkpointStore) since(turn int) (int, []fileSnapshot, error) {
+	idx := -1
+	for i, t := range s.turns {
+		if t.Turn == turn {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return 0, nil, fmt.Errorf("%w: turn %d", ErrCheckpointNotFound, turn)
+	}
+	seen := make(map[string]bool)
+	var snaps []fileSnapshot
+	for _, t := range s.turns[idx:] {
+		for _, p := range t.Files {
+			if !seen[p] {
+				seen[p] = true
+				snaps = append(snaps, t.snapshots[p])
+			}
+		}
+	}
+	return idx, snaps, nil
+}
+
+func (snap fileSnapshot) restore() error {
+	if !snap.existed {
+		if err := os.Remove(snap.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(snap.path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(snap.path, snap.content, snap.mode); err != nil {
+		return err
+	}
+	return os.Chmod(snap.path, snap.mode)
+}
+
+// checkpointFiles snapshots paths into the current turn before a tool modifies them.
+func (a *Agent) checkpointFiles(paths []string) {
+	if a.checkpoints == nil {
+		return
+	}
+	a.checkpoints.snapshot(paths, a.msgs)
+}
+
+// Checkpoints lists chat turns, oldest first. Turns without file changes are
+// included so the conversation can still be rewound to them.
+func (a *Agent) Checkpoints() []Checkpoint {
+	if a.checkpoints == nil {
+		return nil
+	}
+	s := a.checkpoints
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Checkpoint
+	for _, t := range s.turns {
+		cp := t.Checkpoint
+		cp.Files = append([]string(nil), t.Files...)
+		out = append(out, cp)
+	}
+	return out
+}
+
+// CheckpointDiff returns a unified diff from the state before turn to the
+// files currently on disk, covering every file modified in turn or later.
+func (a *Agent) CheckpointDiff(turn int) (string, error) {
+	if a.checkpoints == nil {
+		return "", fmt.Errorf("%w: turn %d", ErrCheckpointNotFound, turn)
+	}
+	a.checkpoints.mu.Lock()
+	_, snaps, err := a.checkpoints.since(turn)
+	a.checkpoints.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for _, snap := range snaps {
+		current, err := os.ReadFile(snap.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("checkpoint diff: %w", err)
+		}
+		sb.WriteString(fileDiff(snap.path, snap.existed, string(snap.content), err == nil, string(current)))
+	}
+	return sb.String(), nil
+}
+
+// Rewind restores every file modified in turn or later to its state before
+// turn, deleting files those turns created, and drops the rewound checkpoints.
+// With conversation set, history is also truncated to before turn's input.
+// Returns the restored paths; restore failures are joined into the error.
+func (a *Agent) Rewind(turn int, conversation bool) ([]string, error) {
+	if a.checkpoints == nil {
+		return nil, fmt.Errorf("%w: turn %d", ErrCheckpointNotFound, turn)
+	}
+	s := a.checkpoints
+	s.mu.Lock()
+	idx, snaps, err := s.since(turn)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	history := s.turns[idx].history
+	s.turns = s.turns[:idx]
+	s.open = false
+	s.mu.Unlock()
+
+	var restored []string
+	var errs []error
+	for _, snap := range snaps {
+		if err := snap.restore(); err != nil {
+			errs = append(errs, fmt.Errorf("rewind %s: %w", snap.path, err))
+			continue
+		}
+		restored = append(restored, snap.path)
+	}
+
+	if conversation {
+		a.msgs = append([]Message(nil), history...)
+		a.autoSaveSession()
+	}
+	return restored, errors.Join(errs...)
+}
+
+// displayPath shows path relative to cwd when it is inside it.
+func displayPath(cwd, path string) string {
+	if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCheckpointTestAgent() *Agent {
+	a := &Agent{registry: NewRegistry(), checkpoints: &checkpointStore{}}
+	a.registry.Register(WriteFileTool())
+	a.registry.Register(EditFileTool())
+	return a
+}
+
+func TestCheckpointRewind(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "sub", "new.go")
+	if err := os.WriteFile(existing, []byte("v1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newCheckpointTestAgent()
+	ctx := context.Background()
+
+	// Turn 1: edit the existing file.
+	a.checkpoints.begin("first", a.msgs)
+	a.msgs = append(a.msgs, Message{Role: "user", Content: "first"})
+	if res := a.executeTool(ctx, "edit_file", map[string]any{"path": existing, "old_string": "v1", "new_string": "v2"}); !res.Success {
+		t.Fatalf("edit_file: %v", res.Error)
+	}
+
+	// Turn 2: edit it again and create a new file.
+	a.checkpoints.begin("second", a.msgs)
+	a.msgs = append(a.msgs, Message{Role: "user", Content: "second"})
+	a.executeTool(ctx, "edit_file", map[string]any{"path": existing, "old_string": "v2", "new_string": "v3"})
+	a.executeTool(ctx, "write_file", map[string]any{"path": created, "content": "package sub\n"})
+
+	cps := a.Checkpoints()
+	if len(cps) != 2 || cps[0].Input != "first" || len(cps[1].Files) != 2 {
+		t.Fatalf("unexpected checkpoints: %+v", cps)
+	}
+
+	diff, err := a.CheckpointDiff(2)
+	if err != nil {
+		t.Fatalf("CheckpointDiff: %v", err)
+	}
+	if !strings.Contains(diff, "-v2\n+v3\n") || !strings.Contains(diff, "--- /dev/null") {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	restored, err := a.Rewind(1, true)
+	if err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("restored = %v, want 2 files", restored)
+	}
+	got, _ := os.ReadFile(existing)
+	if string(got) != "v1\n" {
+		t.Fatalf("existing file = %q, want v1", got)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0600 {
+		t.Fatalf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if _, err := os.Stat(created); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("created file should be removed, stat err = %v", err)
+	}
+	if len(a.msgs) != 0 {
+		t.Fatalf("conversation not rewound: %+v", a.msgs)
+	}
+	if len(a.Checkpoints()) != 0 {
+		t.Fatalf("rewound checkpoints should be dropped: %+v", a.Checkpoints())
+	}
+	if _, err := a.Rewind(2, false); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Fatalf("expected ErrCheckpointNotFound, got %v", err)
+	}
+}
+
+func TestCheckpointKeepsStateBeforeFirstWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.txt")
+	os.WriteFile(path, []byte("orig"), 0644)
+
+	a := newCheckpointTestAgent()
+	ctx := context.Background()
+	// No Chat turn open: the edit starts an implicit one.
+	a.executeTool(ctx, "write_file", map[string]any{"path": path, "content": "one"})
+	a.executeTool(ctx, "write_file", map[string]any{"path": path, "content": "two"})
+
+	cps := a.Checkpoints()
+	if len(cps) != 1 || len(cps[0].Files) != 1 {
+		t.Fatalf("unexpected checkpoints: %+v", cps)
+	}
+	if _, err := a.Rewind(cps[0].Turn, false); err != nil {
+		t.Fatalf("Rewind: %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "orig" {
+		t.Fatalf("file = %q, want orig", got)
+	}
+}
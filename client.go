@@ -52,6 +52,8 @@ type APILogEntry struct {
 	StatusCode      int               `json:"status_code,omitempty"`
 	Error           string            `json:"error,omitempty"`
 	DurationMs      int64             `json:"duration_ms"`
+	RetryAttempt    int               `json:"retry_attempt,omitempty"`  // set on failed attempts that were retried
+	RetryDelayMs    int64             `json:"retry_delay_ms,omitempty"` // wait before the next attempt
 }
 
 // ResponseUsage contains token usage plus computed context-window percentages.
@@ -90,29 +92,15 @@ type modelEndpointLimit struct {
 }
 
 // NewClient creates a new API client with the given configuration.
-// LLM inference calls are routed through llm.CompletionsClient;
-// a capturing HTTP transport preserves raw body logging.
+// LLM inference calls are routed through the provider selected by
+// Config.ProviderAPI (or Config.Provider); a capturing HTTP transport
+// preserves raw body logging for the built-in providers.
 func NewClient(config Config) (*Client, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
 
-	transport := &capturingTransport{base: http.DefaultTransport}
-	llmHTTPClient := &http.Client{
-		Timeout:   config.HTTPTimeout,
-		Transport: transport,
-	}
-
-	provider, err := llm.NewCompletionsClient(llm.Config{
-		APIKey:      config.APIKey,
-		BaseURL:     config.BaseURL,
-		HTTPTimeout: config.HTTPTimeout,
-		HTTPReferer: "https://webforspeed.com",
-		AppTitle:    "webforspeed Bono",
-		Categories:  "cli-agent",
-		HTTPClient:  llmHTTPClient,
-		SkipAuth:    config.APIKey == "" || isLocalURL(config.BaseURL),
-	})
+	provider, transport, err := newLLMProvider(config, config.BaseURL, config.APIKey == "" || isLocalURL(config.BaseURL))
 	if err != nil {
 		return nil, fmt.Errorf("create llm provider: %w", err)
 	}
@@ -126,10 +114,11 @@ func NewClient(config Config) (*Client, error) {
 		modelLimits: make(map[string]modelLimitCacheEntry),
 	}
 
-	// Warm model limits once at startup so response usage can be computed cheaply per request.
+	// Warm model limits once at startup (primary and fallback models) so
+	// response usage can be computed cheaply per request.
 	warmCtx, cancel := context.WithTimeout(context.Background(), modelLimitsWarmupTimeout(config.HTTPTimeout))
 	defer cancel()
-	_ = c.WarmModelUsageLimits(warmCtx, config.Model)
+	c.warmModels(warmCtx)
 
 	return c, nil
 }
@@ -149,6 +138,16 @@ func (c *Client) LastModel() string {
 	return c.lastModel
 }
 
+// TotalCost returns the cumulative session cost.
+func (c *Client) TotalCost() float64 {
+	return c.totalCost
+}
+
+// SetTotalCost restores cumulative session cost, e.g. when resuming a session.
+func (c *Client) SetTotalCost(cost float64) {
+	c.totalCost = cost
+}
+
 // ResetCost zeroes cumulative session cost and clears the last usage snapshot.
 func (c *Client) ResetCost() {
 	c.totalCost = 0
@@ -162,36 +161,70 @@ func (c *Client) SetBaseURL(baseURL string) {
 		baseURL = DefaultBaseURL
 	}
 	c.config.BaseURL = baseURL
-	
-	isLocal := isLocalURL(baseURL)
-	
-	// Recreate the provider with the new base URL
-	transport := &capturingTransport{base: http.DefaultTransport}
-	llmHTTPClient := &http.Client{
-		Timeout:   c.config.HTTPTimeout,
-		Transport: transport,
+
+	if c.config.Provider != nil {
+		// Caller-supplied providers own their endpoint.
+		return
 	}
-	
-	provider, err := llm.NewCompletionsClient(llm.Config{
-		APIKey:      c.config.APIKey,
-		BaseURL:     baseURL,
-		HTTPTimeout: c.config.HTTPTimeout,
-		HTTPReferer: "https://webforspeed.com",
-		AppTitle:    "webforspeed Bono",
-		Categories:  "cli-agent",
-		HTTPClient:  llmHTTPClient,
-		SkipAuth:    isLocal, // Skip auth for local providers like Ollama
-	})
+
+	// Recreate the provider with the new base URL.
+	// Skip auth for local providers like Ollama.
+	provider, transport, err := newLLMProvider(c.config, baseURL, isLocalURL(baseURL))
 	if err != nil {
 		// Log but don't fail - the old provider still works
 		log.Printf("warning: failed to recreate provider with new base URL: %v", err)
 		return
 	}
-	
+
 	c.provider = provider
 	c.transport = transport
 }
 
+// newLLMProvider builds the inference provider for config against baseURL.
+// Built-in providers send through a fresh capturingTransport so raw request and
+// response bodies can be logged; a caller-supplied Config.Provider is returned as-is.
+func newLLMProvider(config Config, baseURL string, skipAuth bool) (llm.Provider, *capturingTransport, error) {
+	transport := &capturingTransport{base: http.DefaultTransport}
+	if config.Provider != nil {
+		return config.Provider, transport, nil
+	}
+
+	llmConfig := llm.Config{
+		APIKey:      config.APIKey,
+		BaseURL:     baseURL,
+		HTTPTimeout: config.HTTPTimeout,
+		HTTPReferer: "https://webforspeed.com",
+		AppTitle:    "webforspeed Bono",
+		Categories:  "cli-agent",
+		HTTPClient: &http.Client{
+			Timeout:   config.HTTPTimeout,
+			Transport: transport,
+		},
+		SkipAuth: skipAuth,
+		Retry:    config.Retry,
+		OnRetry: func(a llm.RetryAttempt) {
+			logRetryAttempt(config.APILogPath, transport.lastCapture(), a)
+		},
+	}
+
+	var (
+		provider llm.Provider
+		err      error
+	)
+	switch config.ProviderAPI {
+	case ProviderAPIMessages:
+		provider, err = llm.NewMessagesClient(llmConfig)
+	case ProviderAPIResponses:
+		provider, err = llm.NewResponsesClient(llmConfig)
+	default:
+		provider, err = llm.NewCompletionsClient(llmConfig)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return provider, transport, nil
+}
+
 // isLocalURL returns true if the URL points to a local service.
 func isLocalURL(baseURL string) bool {
 	return strings.Contains(baseURL, "localhost") || strings.Contains(baseURL, "127.0.0.1")
@@ -563,6 +596,16 @@ func (c *Client) buildResponseUsage(responseBody []byte, requestedModel string)
 	promptTokens, hasPrompt := asInt64(usageMap["prompt_tokens"])
 	completionTokens, hasCompletion := asInt64(usageMap["completion_tokens"])
 	totalTokens, hasTotal := asInt64(usageMap["total_tokens"])
+	// Messages and Responses APIs name the same counts input/output tokens.
+	if !hasPrompt {
+		promptTokens, hasPrompt = asInt64(usageMap["input_tokens"])
+	}
+	if !hasCompletion {
+		completionTokens, hasCompletion = asInt64(usageMap["output_tokens"])
+	}
+	if !hasTotal && hasPrompt && hasCompletion {
+		totalTokens, hasTotal = promptTokens+completionTokens, true
+	}
 	if !hasPrompt && !hasCompletion && !hasTotal {
 		return nil
 	}
@@ -649,9 +692,19 @@ func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (*Messa
 
 // ChatCompletionWithTools sends messages with a custom tool set.
 // All LLM inference calls are routed through the llm.Provider.
+// Failures in a Config.FallbackOn class are retried on Config.FallbackModels.
 func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message, tools []Tool, opts ...llmRequestOption) (*Message, error) {
-	req := buildLLMRequest(c.config.Model, c.applyMiddleware(messages), tools, opts...)
+	messages = c.applyMiddleware(messages)
+	return c.withFallback(ctx, nil, func(model string) (*Message, error) {
+		return c.chatCompletionModel(ctx, model, messages, tools, opts...)
+	})
+}
+
+// chatCompletionModel sends one buffered request to model.
+func (c *Client) chatCompletionModel(ctx context.Context, model string, messages []Message, tools []Tool, opts ...llmRequestOption) (*Message, error) {
+	req := buildLLMRequest(model, messages, tools, opts...)
 
+	start := time.Now()
 	resp, err := c.provider.SendMessage(ctx, req)
 
 	// Warm model limits for the actual response model (cached after first call per model).
@@ -664,8 +717,8 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 	}
 
 	// Log and track usage from captured HTTP data.
-	captured := c.transport.lastCapture()
-	c.logFromCapture(captured, err)
+	captured := c.callCapture(req, resp, err, start)
+	c.logFromCapture(captured, model, err)
 
 	if err != nil {
 		// Translate llm errors to core errors for backward compat.
@@ -685,7 +738,7 @@ func (c *Client) ChatCompletionWithTools(ctx context.Context, messages []Message
 
 	c.lastModel = strings.TrimSpace(resp.Model)
 	if c.lastModel == "" {
-		c.lastModel = c.config.Model
+		c.lastModel = model
 	}
 
 	return llmResponseToMessage(resp), nil
@@ -716,14 +769,44 @@ func (c *Client) ChatCompletionWithToolsStream(
 		return c.ChatCompletionWithTools(ctx, messages, tools, opts...)
 	}
 
-	req := buildLLMRequest(c.config.Model, c.applyMiddleware(messages), tools, opts...)
+	// Once any delta, content or reasoning, has reached the caller, switching
+	// models would replay output from a second model.
+	var streamed bool
+	forward := func(onDelta func(string)) func(string) {
+		return func(delta string) {
+			streamed = true
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		}
+	}
+	onContent, onReasoning := forward(onContentDelta), forward(onReasoningDelta)
+
+	messages = c.applyMiddleware(messages)
+	return c.withFallback(ctx, func() bool { return streamed }, func(model string) (*Message, error) {
+		return c.chatCompletionModelStream(ctx, streamProvider, model, messages, tools, onContent, onReasoning, opts...)
+	})
+}
+
+// chatCompletionModelStream sends one streaming request to model.
+func (c *Client) chatCompletionModelStream(
+	ctx context.Context,
+	streamProvider llm.StreamProvider,
+	model string,
+	messages []Message,
+	tools []Tool,
+	onContentDelta func(string),
+	onReasoningDelta func(string),
+	opts ...llmRequestOption,
+) (*Message, error) {
+	req := buildLLMRequest(model, messages, tools, opts...)
 
 	start := time.Now()
 	stream, err := streamProvider.SendMessageStream(ctx, req)
 
-	captured := c.transport.lastCapture()
+	captured := c.callCapture(req, nil, err, start)
 	if err != nil {
-		c.logFromCapture(captured, err)
+		c.logFromCapture(captured, model, err)
 		if errors.Is(err, llm.ErrNoChoices) {
 			return nil, ErrNoChoices
 		}
@@ -757,17 +840,41 @@ func (c *Client) ChatCompletionWithToolsStream(
 	}
 
 	if err := stream.Err(); err != nil {
-		c.logFromCapture(captured, err)
+		c.logFromCapture(c.callCapture(req, nil, err, start), model, err)
 		return nil, fmt.Errorf("llm stream: %w", err)
 	}
 
 	if resp == nil {
-		c.logFromCapture(captured, llm.ErrEmptyResponse)
+		c.logFromCapture(captured, model, llm.ErrEmptyResponse)
 		return nil, ErrEmptyResponse
 	}
 
-	// Reconstruct a synthetic response body for logging/usage tracking.
-	// Use raw usage JSON from the SSE stream to preserve cost_details.
+	// Fill the streaming capture with a synthetic body for logging/usage tracking.
+	c.transport.completeStreamCapture(syntheticResponseBody(resp), time.Since(start))
+
+	// Warm model limits for the actual response model so buildResponseUsage
+	// can compute context percentages. Cached after first call per model.
+	if model := strings.TrimSpace(resp.Model); model != "" {
+		warmCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		_ = c.WarmModelUsageLimits(warmCtx, model)
+		cancel()
+	}
+
+	captured = c.callCapture(req, resp, nil, start)
+	c.logFromCapture(captured, model, nil)
+
+	c.lastModel = strings.TrimSpace(resp.Model)
+	if c.lastModel == "" {
+		c.lastModel = model
+	}
+
+	return llmResponseToMessage(resp), nil
+}
+
+// syntheticResponseBody reconstructs a minimal response body (id, model, usage)
+// for responses that were not captured whole, such as SSE streams. Raw usage
+// JSON from the stream is preserved so cost_details survive.
+func syntheticResponseBody(resp *llm.Response) []byte {
 	var usageData any
 	if len(resp.RawUsage) > 0 {
 		var raw any
@@ -782,41 +889,70 @@ func (c *Client) ChatCompletionWithToolsStream(
 			"total_tokens":      resp.Usage.InputTokens + resp.Usage.OutputTokens,
 		}
 	}
-	syntheticBody, _ := json.Marshal(map[string]any{
+	body, _ := json.Marshal(map[string]any{
 		"id":    resp.ID,
 		"model": resp.Model,
 		"usage": usageData,
 	})
-	c.transport.completeStreamCapture(syntheticBody, time.Since(start))
+	return body
+}
 
-	// Warm model limits for the actual response model so buildResponseUsage
-	// can compute context percentages. Cached after first call per model.
-	if model := strings.TrimSpace(resp.Model); model != "" {
-		warmCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-		_ = c.WarmModelUsageLimits(warmCtx, model)
-		cancel()
+// callCapture returns the captured HTTP data for the call that started at start.
+// A caller-supplied Config.Provider bypasses the capturing transport, so its
+// capture is synthesized from the llm request and response instead.
+func (c *Client) callCapture(req *llm.Request, resp *llm.Response, callErr error, start time.Time) *capturedRoundTrip {
+	if c.config.Provider == nil {
+		return c.transport.lastCapture()
 	}
 
-	captured = c.transport.lastCapture()
-	c.logFromCapture(captured, nil)
-
-	c.lastModel = strings.TrimSpace(resp.Model)
-	if c.lastModel == "" {
-		c.lastModel = c.config.Model
-	}
+	captured := &capturedRoundTrip{
+		RequestURL: fmt.Sprintf("provider:%T", c.provider),
+		Duration:   time.Since(start),
+		Error:      callErr,
+	}
+	captured.RequestBody, _ = json.Marshal(req)
+	var llmErr *llm.APIError
+	switch {
+	case errors.As(callErr, &llmErr):
+		captured.StatusCode = llmErr.StatusCode
+	case resp != nil:
+		captured.StatusCode = http.StatusOK
+		captured.ResponseBody = syntheticResponseBody(resp)
+	}
+	return captured
+}
 
-	return llmResponseToMessage(resp), nil
+// logRetryAttempt logs a failed attempt that the llm provider is about to retry.
+// The capture is the attempt's own round trip, or nil if it never got a response.
+func logRetryAttempt(path string, captured *capturedRoundTrip, a llm.RetryAttempt) {
+	entry := APILogEntry{
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Error:        a.Err.Error(),
+		RetryAttempt: a.Attempt,
+		RetryDelayMs: a.Delay.Milliseconds(),
+	}
+	if captured != nil {
+		entry.RequestURL = captured.RequestURL
+		entry.RequestPayload = payloadForLog(captured.RequestBody)
+		entry.RequestHeaders = headersForLog(captured.RequestHeaders, true)
+		entry.StatusCode = captured.StatusCode
+		entry.ResponsePayload = payloadForLog(captured.ResponseBody)
+		entry.ResponseHeaders = headersForLog(captured.ResponseHeaders, false)
+		entry.DurationMs = captured.Duration.Milliseconds()
+	}
+	writeLogEntry(path, entry)
 }
 
 // logFromCapture logs an API call using data captured by the HTTP transport.
-func (c *Client) logFromCapture(captured *capturedRoundTrip, callErr error) {
+// requestedModel is used for usage limits when the response names no model.
+func (c *Client) logFromCapture(captured *capturedRoundTrip, requestedModel string, callErr error) {
 	if captured == nil {
 		return
 	}
 
 	var responseUsage *ResponseUsage
 	if captured.StatusCode == http.StatusOK && len(captured.ResponseBody) > 0 {
-		responseUsage = c.buildResponseUsage(captured.ResponseBody, c.config.Model)
+		responseUsage = c.buildResponseUsage(captured.ResponseBody, requestedModel)
 	}
 	if responseUsage != nil {
 		c.lastUsage = responseUsage
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/webforspeed/bono-core/llm"
+)
+
+// readAPILog returns the entries written to a JSONL API log.
+func readAPILog(t *testing.T, path string) []APILogEntry {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open api log: %v", err)
+	}
+	defer f.Close()
+	var entries []APILogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e APILogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("decode api log: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestNewClientMessagesAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       "anthropic/claude-test",
+			"stop_reason": "end_turn",
+			"content":     []map[string]any{{"type": "text", "text": "hi there"}},
+			"usage":       map[string]any{"input_tokens": 12, "output_tokens": 3},
+		})
+	}))
+	defer srv.Close()
+
+	logPath := filepath.Join(t.TempDir(), "api.jsonl")
+	client, err := NewClient(Config{
+		APIKey:      "test-key",
+		BaseURL:     srv.URL,
+		Model:       "anthropic/claude-test",
+		ProviderAPI: ProviderAPIMessages,
+		APILogPath:  logPath,
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, ok := client.provider.(*llm.MessagesClient); !ok {
+		t.Fatalf("provider = %T, want *llm.MessagesClient", client.provider)
+	}
+
+	msg, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hello"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if msg.Content != "hi there" {
+		t.Fatalf("content = %v", msg.Content)
+	}
+
+	usage := client.LastUsage()
+	if usage == nil || usage.PromptTokens == nil || *usage.PromptTokens != 12 || *usage.TotalTokens != 15 {
+		t.Fatalf("usage not tracked from input/output tokens: %+v", usage)
+	}
+	entries := readAPILog(t, logPath)
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].RequestURL, "/messages") {
+		t.Fatalf("unexpected log entries: %+v", entries)
+	}
+}
+
+type stubProvider struct {
+	got  *llm.Request
+	resp *llm.Response
+}
+
+func (p *stubProvider) SendMessage(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	p.got = req
+	return p.resp, nil
+}
+
+func TestNewClientCustomProvider(t *testing.T) {
+	// Serves only the model-limits warmup; inference never reaches HTTP.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	stub := &stubProvider{resp: &llm.Response{
+		ID:      "stub-1",
+		Model:   "stub/model",
+		Content: "from stub",
+		Usage:   llm.Usage{InputTokens: 7, OutputTokens: 2},
+	}}
+	logPath := filepath.Join(t.TempDir(), "api.jsonl")
+	client, err := NewClient(Config{
+		BaseURL:     srv.URL,
+		Model:       "stub/model",
+		ProviderAPI: "ignored-when-provider-set",
+		Provider:    stub,
+		APILogPath:  logPath,
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	client.Use(func(msgs []Message) []Message {
+		return append([]Message{{Role: "system", Content: "injected"}}, msgs...)
+	})
+
+	msg, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hello"}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if msg.Content != "from stub" {
+		t.Fatalf("content = %v", msg.Content)
+	}
+	if stub.got == nil || stub.got.System != "injected" {
+		t.Fatalf("middleware not applied to custom provider request: %+v", stub.got)
+	}
+	if client.LastModel() != "stub/model" {
+		t.Fatalf("LastModel = %q", client.LastModel())
+	}
+
+	usage := client.LastUsage()
+	if usage == nil || usage.PromptTokens == nil || *usage.PromptTokens != 7 {
+		t.Fatalf("usage not tracked for custom provider: %+v", usage)
+	}
+	entries := readAPILog(t, logPath)
+	if len(entries) != 1 || entries[0].StatusCode != http.StatusOK || entries[0].RequestPayload == nil {
+		t.Fatalf("unexpected log entries: %+v", entries)
+	}
+
+	// SetBaseURL must not replace a caller-supplied provider.
+	client.SetBaseURL("http://localhost:1")
+	if client.provider != llm.Provider(stub) {
+		t.Fatalf("SetBaseURL replaced custom provider with %T", client.provider)
+	}
+}
+
+func TestClientLogsRetryAttempts(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":503,"message":"overloaded"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"c1","model":"test/model","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	logPath := filepath.Join(t.TempDir(), "api.jsonl")
+	client, err := NewClient(Config{
+		APIKey:     "test-key",
+		BaseURL:    srv.URL,
+		Model:      "test/model",
+		APILogPath: logPath,
+		Retry:      llm.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if _, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hello"}}); err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+
+	entries := readAPILog(t, logPath)
+	if len(entries) != 2 {
+		t.Fatalf("expected retry entry plus final entry, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].RetryAttempt != 1 || entries[0].StatusCode != http.StatusServiceUnavailable || entries[0].Error == "" {
+		t.Fatalf("retry entry = %+v", entries[0])
+	}
+	if entries[1].RetryAttempt != 0 || entries[1].StatusCode != http.StatusOK {
+		t.Fatalf("final entry = %+v", entries[1])
+	}
+}
@@ -47,12 +47,10 @@ func NewCodeSearchService(cfg CodeSearchConfig) (*CodeSearchService, error) {
 
 // Tool returns the standard code_search tool definition.
 func (s *CodeSearchService) Tool() *ToolDef {
-	return CodeSearchTool(func(query string, rawOpts map[string]any) ToolResult {
-		return s.search(query, rawOpts)
-	})
+	return CodeSearchTool(s.search)
 }
 
-func (s *CodeSearchService) search(query string, rawOpts map[string]any) ToolResult {
+func (s *CodeSearchService) search(ctx context.Context, query string, rawOpts map[string]any) ToolResult {
 	if s == nil || s.engine == nil {
 		return ToolResult{
 			Success: false,
@@ -62,7 +60,7 @@ func (s *CodeSearchService) search(query string, rawOpts map[string]any) ToolRes
 		}
 	}
 	opts := parseCodeSearchOptions(rawOpts)
-	results, err := s.engine.Search(context.Background(), query, opts)
+	results, err := s.engine.Search(ctx, query, opts)
 	if err != nil {
 		return ToolResult{
 			Success: false,
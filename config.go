@@ -1,9 +1,21 @@
 package core
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"github.com/webforspeed/bono-core/llm"
+)
 
 const DefaultBaseURL = "https://openrouter.ai/api/v1"
 
+// Wire APIs selectable with Config.ProviderAPI.
+const (
+	ProviderAPICompletions = "completions" // OpenAI-compatible Chat Completions (default)
+	ProviderAPIMessages    = "messages"    // Anthropic Messages
+	ProviderAPIResponses   = "responses"   // OpenAI Responses
+)
+
 // PreTaskConfig defines a sub-agent task that runs before the main agent.
 type PreTaskConfig struct {
 	Name         string // Display name (e.g., "exploring")
@@ -14,13 +26,14 @@ type PreTaskConfig struct {
 
 // SandboxConfig holds configuration for sandboxed shell execution.
 type SandboxConfig struct {
-	Enabled                bool          // Enable sandboxing (true on macOS by default)
+	Enabled                bool          // Enable sandboxing (true by default on macOS and on Linux with Landlock)
 	AllowNetwork           bool          // Allow outbound network from sandbox (default false)
 	ReadPaths              []string      // Read-only path allowlist
 	WritePaths             []string      // Read/write path allowlist (default: cwd + temp)
 	ExecPaths              []string      // Executable path allowlist
 	FallbackOutsideSandbox bool          // Allow approval-based rerun outside sandbox if blocked (default true)
 	CommandTimeout         time.Duration // Max runtime for shell/python commands; 0 uses default timeout, <0 disables timeout
+	AllowSymlinkEscape     bool          // Let file tools write through symlinks that resolve outside the workspace (default false)
 }
 
 // WebConfig configures the web search and fetch tools.
@@ -38,24 +51,36 @@ type WebConfig struct {
 
 // Config holds the configuration for the agent.
 type Config struct {
-	APIKey              string            // Required: API key for authentication
-	BaseURL             string            // Base URL for the API (defaults to OpenRouter)
-	Model               string            // Model to use (defaults to claude-opus-4.5)
-	AllowedTools        []string          // Tool names to enable. Empty = all registered tools.
-	SystemPrompt        string            // Optional system prompt
-	HTTPTimeout         time.Duration     // HTTP client timeout
-	PreTasks            []PreTaskConfig   // Pre-tasks to run on first Chat() call
-	Sandbox             SandboxConfig     // Sandbox configuration for shell execution
-	ShellPolicy         ShellPolicy       // Optional shell routing policy. Nil uses the default rule-based policy.
-	CodeSearch          *CodeSearchConfig // Optional code search configuration. Nil disables code_search.
-	Web                 *WebConfig        // Optional web search/fetch configuration. Nil disables web tools.
-	APILogPath          string            // Path to JSONL log file (default: logs/api_calls.jsonl)
-	MaxToolCallsPerTurn int               // Cap tool calls per round; 0 = unlimited. When hit, agent asks for a summary before continuing.
-	MaxChatTurns        int               // Cap main chat rounds; 0 = unlimited.
-	MaxPreTaskTurns     int               // Cap pre-task rounds; 0 = unlimited.
-	MaxSubAgentTurns    int               // Cap subagent rounds; 0 = unlimited.
-	DisableLimits       bool              // Skip timeout/turn default limits and honor zero-values as unlimited.
-	ReasoningEffort     string            // Reasoning effort level (e.g., "high", "medium", "low"). Empty = not sent.
+	APIKey               string            // Required: API key for authentication
+	BaseURL              string            // Base URL for the API (defaults to OpenRouter)
+	ProviderAPI          string            // Wire API for inference: "completions" (default), "messages" or "responses". Ignored when Provider is set.
+	Provider             llm.Provider      // Optional caller-supplied provider; overrides ProviderAPI. Implement llm.StreamProvider to stream.
+	Model                string            // Model to use (defaults to claude-opus-4.5)
+	FallbackModels       []string          // Ordered models tried when Model fails with a FallbackOn error class.
+	FallbackOn           FallbackTrigger   // Error classes that trigger fallback; 0 = DefaultFallbackTriggers.
+	AllowedTools         []string          // Tool names to enable. Empty = all registered tools.
+	SystemPrompt         string            // Optional system prompt
+	HTTPTimeout          time.Duration     // HTTP client timeout
+	Retry                llm.RetryPolicy   // Retry policy for LLM API calls (429/5xx/transport errors). Zero value uses llm defaults; MaxRetries < 0 disables.
+	PreTasks             []PreTaskConfig   // Pre-tasks to run on first Chat() call
+	Sandbox              SandboxConfig     // Sandbox configuration for shell execution
+	ShellPolicy          ShellPolicy       // Optional shell routing policy. Nil uses LoadShellPolicy for the working directory: policy files, then the default rules.
+	PermissionRules      []PermissionRule  // Ordered rules deciding whether tool calls run, checked before each tool's own Permission; first match decides.
+	CodeSearch           *CodeSearchConfig // Optional code search configuration. Nil disables code_search.
+	Web                  *WebConfig        // Optional web search/fetch configuration. Nil disables web tools.
+	APILogPath           string            // Path to JSONL log file (default: logs/api_calls.jsonl)
+	MaxToolCallsPerTurn  int               // Cap tool calls per round; 0 = unlimited. When hit, agent asks for a summary before continuing.
+	AutoCompactThreshold float64           // Prompt usage percentage (0-100) at which Chat summarizes and compacts history; 0 disables.
+	AutoCompactKeepTools int               // Most recent tool exchanges kept verbatim by auto compaction; 0 = 3.
+	MaxParallelToolCalls int               // Max concurrent ReadOnly tool calls from one response; 0 or 1 = sequential.
+	ToolOutputBudget     int               // Max bytes of a tool result kept in history; longer output keeps its head and tail and is saved in full to a temp file. 0 = 32 KiB, <0 = unlimited.
+	MaxChatTurns         int               // Cap main chat rounds; 0 = unlimited.
+	MaxPreTaskTurns      int               // Cap pre-task rounds; 0 = unlimited.
+	MaxSubAgentTurns     int               // Cap subagent rounds; 0 = unlimited.
+	DisableLimits        bool              // Skip timeout/turn default limits and honor zero-values as unlimited.
+	ReasoningEffort      string            // Reasoning effort level (e.g., "high", "medium", "low"). Empty = not sent.
+	SessionStore         SessionStore      // Optional store; when set the conversation is saved after every round.
+	ResumeSession        *Session          // Optional session to resume: restores messages, cost, pre-task state and subagent output paths.
 }
 
 // Validate checks the configuration and sets defaults.
@@ -63,6 +88,16 @@ func (c *Config) Validate() error {
 	if c.BaseURL == "" {
 		c.BaseURL = DefaultBaseURL
 	}
+	if c.Provider == nil {
+		switch c.ProviderAPI {
+		case "":
+			c.ProviderAPI = ProviderAPICompletions
+		case ProviderAPICompletions, ProviderAPIMessages, ProviderAPIResponses:
+		default:
+			return fmt.Errorf("unknown provider API %q (want %q, %q or %q)",
+				c.ProviderAPI, ProviderAPICompletions, ProviderAPIMessages, ProviderAPIResponses)
+		}
+	}
 	if c.Model == "" {
 		c.Model = "anthropic/claude-opus-4.5"
 	}
@@ -38,3 +38,18 @@ func TestConfigValidateDisableLimitsKeepsUnlimitedZeros(t *testing.T) {
 		t.Fatalf("MaxSubAgentTurns = %d, want 0", cfg.MaxSubAgentTurns)
 	}
 }
+
+func TestConfigValidateProviderAPI(t *testing.T) {
+	cfg := Config{}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate returned error: %v", err)
+	}
+	if cfg.ProviderAPI != ProviderAPICompletions {
+		t.Fatalf("ProviderAPI = %q, want %q", cfg.ProviderAPI, ProviderAPICompletions)
+	}
+
+	cfg = Config{ProviderAPI: "grpc"}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for unknown provider API")
+	}
+}
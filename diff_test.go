@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "single change",
+			old:  "a\nb\nc\n",
+			new:  "a\nB\nc\n",
+			want: "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "x\n",
+			want: "--- a/f\n+++ b/f\n@@ -0,0 +1,1 @@\n+x\n",
+		},
+		{
+			name: "missing final newline",
+			old:  "a\n",
+			new:  "a\nb",
+			want: "--- a/f\n+++ b/f\n@@ -1,1 +1,2 @@\n a\n+b\n\\ No newline at end of file\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a/f", "b/f", tt.old, tt.new); got != tt.want {
+				t.Fatalf("diff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffSeparateHunks(t *testing.T) {
+	var old []string
+	for i := 1; i <= 20; i++ {
+		old = append(old, fmt.Sprintf("line %d\n", i))
+	}
+	changed := append([]string(nil), old...)
+	changed[1] = "second\n"
+	changed[17] = "eighteenth\n"
+
+	got := unifiedDiff("a/f", "b/f", strings.Join(old, ""), strings.Join(changed, ""))
+	if n := strings.Count(got, "@@ -"); n != 2 {
+		t.Fatalf("expected 2 hunks, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@") || !strings.Contains(got, "@@ -15,6 +15,6 @@") {
+		t.Fatalf("unexpected hunk headers:\n%s", got)
+	}
+}
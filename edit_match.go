@@ -0,0 +1,332 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// editMatchTier is how loosely old_string matched the file, strictest first.
+type editMatchTier int
+
+const (
+	matchExact              editMatchTier = iota // byte-for-byte
+	matchLineEndings                             // CRLF and LF treated alike
+	matchTrailingWhitespace                      // whole lines, trailing spaces/tabs ignored
+	matchIndentation                             // whole lines, same relative indentation at any depth
+)
+
+func (t editMatchTier) String() string {
+	switch t {
+	case matchLineEndings:
+		return "line-ending-normalized"
+	case matchTrailingWhitespace:
+		return "trailing-whitespace-insensitive"
+	case matchIndentation:
+		return "indentation-relative"
+	}
+	return "exact"
+}
+
+// editMatch is a byte range of the file matched by old_string and the text
+// that replaces it, already adapted to the file's line endings and indentation.
+type editMatch struct {
+	start, end  int
+	replacement string
+}
+
+// tabWidth is the column width of a tab when comparing indentation.
+const tabWidth = 4
+
+// findEditMatches returns the non-overlapping matches of oldStr in content
+// from the strictest tier that finds any.
+func findEditMatches(content, oldStr, newStr string) ([]editMatch, editMatchTier) {
+	if oldStr == "" {
+		if content == "" {
+			return []editMatch{{0, 0, newStr}}, matchExact
+		}
+		return nil, matchExact
+	}
+	if m := exactMatches(content, oldStr, newStr); len(m) > 0 {
+		return m, matchExact
+	}
+	if m := lineEndingMatches(content, oldStr, newStr); len(m) > 0 {
+		return m, matchLineEndings
+	}
+	if m := lineMatches(content, oldStr, newStr, false); len(m) > 0 {
+		return m, matchTrailingWhitespace
+	}
+	if m := lineMatches(content, oldStr, newStr, true); len(m) > 0 {
+		return m, matchIndentation
+	}
+	return nil, matchExact
+}
+
+func exactMatches(content, oldStr, newStr string) []editMatch {
+	var out []editMatch
+	for from := 0; ; {
+		i := strings.Index(content[from:], oldStr)
+		if i < 0 {
+			return out
+		}
+		start := from + i
+		out = append(out, editMatch{start, start + len(oldStr), newStr})
+		from = start + len(oldStr)
+	}
+}
+
+func lineEndingMatches(content, oldStr, newStr string) []editMatch {
+	norm, offsets := normalizeLineEndings(content)
+	normOld := strings.ReplaceAll(oldStr, "\r\n", "\n")
+	if norm == content && normOld == oldStr {
+		return nil // nothing to normalize; exact already failed
+	}
+	repl := toFileLineEndings(content, newStr)
+	var out []editMatch
+	for _, m := range exactMatches(norm, normOld, repl) {
+		// Map the first and last matched bytes, so a CRLF just outside the
+		// match keeps its \r and one at its edges is replaced whole.
+		start, end := offsets[m.start], offsets[m.end-1]+1
+		if norm[m.start] == '\n' && start > 0 && content[start-1] == '\r' {
+			start--
+		}
+		out = append(out, editMatch{start, end, repl})
+	}
+	return out
+}
+
+// normalizeLineEndings converts CRLF to LF. offsets maps each byte position
+// of the result (and its end) to the corresponding position in s; an LF
+// from a CRLF maps to the LF.
+func normalizeLineEndings(s string) (string, []int) {
+	var sb strings.Builder
+	offsets := make([]int, 0, len(s)+1)
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+			continue
+		}
+		offsets = append(offsets, i)
+		sb.WriteByte(s[i])
+	}
+	offsets = append(offsets, len(s))
+	return sb.String(), offsets
+}
+
+// toFileLineEndings rewrites s to CRLF when content uses CRLF, else to LF.
+func toFileLineEndings(content, s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if strings.Contains(content, "\r\n") {
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+	}
+	return s
+}
+
+// fileLine is one line of content; end excludes the line terminator.
+type fileLine struct {
+	text       string
+	start, end int
+	next       int // start of the following line
+}
+
+func indexLines(content string) []fileLine {
+	var lines []fileLine
+	for start := 0; start < len(content); {
+		nl := strings.IndexByte(content[start:], '\n')
+		next := len(content)
+		end := len(content)
+		if nl >= 0 {
+			end = start + nl
+			next = end + 1
+		}
+		if end > start && content[end-1] == '\r' {
+			end--
+		}
+		lines = append(lines, fileLine{content[start:end], start, end, next})
+		start = next
+	}
+	return lines
+}
+
+// lineMatches matches oldStr as a block of whole lines, ignoring trailing
+// whitespace and, with relativeIndent, the block's overall indentation.
+func lineMatches(content, oldStr, newStr string, relativeIndent bool) []editMatch {
+	oldText := strings.ReplaceAll(oldStr, "\r\n", "\n")
+	withNewline := strings.HasSuffix(oldText, "\n")
+	want := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	lines := indexLines(content)
+
+	var out []editMatch
+	for i := 0; i+len(want) <= len(lines); {
+		block := lines[i : i+len(want)]
+		if !blockEqual(block, want, relativeIndent) {
+			i++
+			continue
+		}
+		last := block[len(block)-1]
+		end := last.end
+		if withNewline {
+			end = last.next
+		}
+		repl := newStr
+		if relativeIndent {
+			repl = reindent(repl, blockIndent(want), blockIndent(fileLineTexts(block)), fileUsesTabs(block))
+		}
+		out = append(out, editMatch{block[0].start, end, toFileLineEndings(content, repl)})
+		i += len(want)
+	}
+	return out
+}
+
+func blockEqual(block []fileLine, want []string, relativeIndent bool) bool {
+	have := fileLineTexts(block)
+	haveBase, wantBase := 0, 0
+	if relativeIndent {
+		haveBase, wantBase = blockIndent(have), blockIndent(want)
+	}
+	for j := range want {
+		a := strings.TrimRight(have[j], " \t\r")
+		b := strings.TrimRight(want[j], " \t\r")
+		if !relativeIndent {
+			if a != b {
+				return false
+			}
+			continue
+		}
+		if (a == "") != (b == "") {
+			return false
+		}
+		if a == "" {
+			continue
+		}
+		if indentWidth(a)-haveBase != indentWidth(b)-wantBase || strings.TrimLeft(a, " \t") != strings.TrimLeft(b, " \t") {
+			return false
+		}
+	}
+	return true
+}
+
+func fileLineTexts(block []fileLine) []string {
+	texts := make([]string, len(block))
+	for i, l := range block {
+		texts[i] = l.text
+	}
+	return texts
+}
+
+// indentWidth is the column width of line's leading whitespace.
+func indentWidth(line string) int {
+	w := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			w++
+		case '\t':
+			w += tabWidth
+		default:
+			return w
+		}
+	}
+	return w
+}
+
+// blockIndent is the smallest indentation width among non-blank lines.
+func blockIndent(lines []string) int {
+	base := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if w := indentWidth(l); base < 0 || w < base {
+			base = w
+		}
+	}
+	return max(base, 0)
+}
+
+func fileUsesTabs(block []fileLine) bool {
+	for _, l := range block {
+		if strings.HasPrefix(l.text, "\t") {
+			return true
+		}
+	}
+	return false
+}
+
+// reindent shifts s from base indentation from to base indentation to,
+// keeping each line's relative depth, and writes indentation with tabs
+// when the file does.
+func reindent(s string, from, to int, tabs bool) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		w := max(indentWidth(l)-from, 0) + to
+		indent := strings.Repeat(" ", w)
+		if tabs {
+			indent = strings.Repeat("\t", w/tabWidth) + strings.Repeat(" ", w%tabWidth)
+		}
+		lines[i] = indent + strings.TrimLeft(l, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxClosestScanLines bounds the closest-region search on large files.
+const maxClosestScanLines = 20000
+
+// closestEditRegion finds the block of lines most similar to oldStr and
+// returns it with line numbers in read_file's format, or "" if nothing is close.
+func closestEditRegion(content, oldStr string) (first, last int, region string) {
+	want := strings.Split(strings.TrimSuffix(strings.ReplaceAll(oldStr, "\r\n", "\n"), "\n"), "\n")
+	lines := indexLines(content)
+	if len(lines) == 0 || len(lines) > maxClosestScanLines {
+		return 0, 0, ""
+	}
+	n := min(len(want), len(lines))
+
+	best, bestScore := -1, 0.0
+	for i := 0; i+n <= len(lines); i++ {
+		score := 0.0
+		for j := 0; j < n; j++ {
+			score += lineSimilarity(lines[i+j].text, want[j])
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore/float64(len(want)) < 0.3 {
+		return 0, 0, ""
+	}
+
+	var sb strings.Builder
+	for j := 0; j < n; j++ {
+		if j > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "%4d | %s", best+j+1, lines[best+j].text)
+	}
+	return best + 1, best + n, sb.String()
+}
+
+// lineSimilarity is the Dice coefficient of the character bigrams of the
+// whitespace-trimmed lines, from 0 (nothing shared) to 1 (equal).
+func lineSimilarity(a, b string) float64 {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return 1
+	}
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+	bigrams := make(map[string]int, len(a))
+	for i := 0; i+1 < len(a); i++ {
+		bigrams[a[i:i+2]]++
+	}
+	shared := 0
+	for i := 0; i+1 < len(b); i++ {
+		if bigrams[b[i:i+2]] > 0 {
+			bigrams[b[i:i+2]]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b)-2)
+}
@@ -27,8 +27,27 @@ var (
 	// ErrMultipleMatches is returned when multiple matches are found without replace_all.
 	ErrMultipleMatches = errors.New("multiple matches found, use replace_all")
 
-	// ErrSandboxBlocked is returned when sandbox policy blocks command execution.
+	// ErrSandboxBlocked is returned when sandbox policy blocks command execution or file access.
 	ErrSandboxBlocked = errors.New("command blocked by sandbox policy")
+
+	// ErrCommandDenied is returned when the shell policy denies a command outright.
+	ErrCommandDenied = errors.New("command denied by shell policy")
+
+	// ErrPermissionDenied is returned when the permission engine denies a tool call.
+	ErrPermissionDenied = errors.New("tool call denied by permission rules")
+
+	// ErrSymlinkEscape is returned when a file tool would write through a symlink
+	// that leads out of the workspace.
+	ErrSymlinkEscape = errors.New("symlink resolves outside the workspace")
+
+	// ErrPatchFailed is returned when apply_patch cannot apply a patch; no file is changed.
+	ErrPatchFailed = errors.New("patch does not apply")
+
+	// ErrCheckpointNotFound is returned when a rewind or diff names an unknown turn.
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+	// ErrJobNotFound is returned when a shell job tool names an unknown job.
+	ErrJobNotFound = errors.New("background job not found")
 )
 
 // APIError represents an error from the chat API.
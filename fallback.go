@@ -0,0 +1,160 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// FallbackTrigger is a set of error classes that move a request from one model
+// to the next entry in Config.FallbackModels.
+type FallbackTrigger uint8
+
+const (
+	// FallbackOnProviderError covers rate limits, 5xx, timeouts, unavailable
+	// models (404) and transport or mid-stream failures.
+	FallbackOnProviderError FallbackTrigger = 1 << iota
+	// FallbackOnContextLength covers requests rejected for exceeding the model's context window.
+	FallbackOnContextLength
+	// FallbackOnEmptyResponse covers models that answer with neither content nor
+	// tool calls on two consecutive attempts.
+	FallbackOnEmptyResponse
+
+	// DefaultFallbackTriggers is used when Config.FallbackOn is zero.
+	DefaultFallbackTriggers = FallbackOnProviderError | FallbackOnContextLength | FallbackOnEmptyResponse
+)
+
+// emptyResponseAttempts is how many empty answers a model gets before fallback.
+const emptyResponseAttempts = 2
+
+// models returns the primary model followed by the fallback chain, without duplicates.
+func (c *Client) models() []string {
+	models := []string{c.config.Model}
+	seen := map[string]bool{c.config.Model: true}
+	for _, m := range c.config.FallbackModels {
+		m = strings.TrimSpace(m)
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		models = append(models, m)
+	}
+	return models
+}
+
+// withFallback calls send with the primary model, then with each fallback
+// model while the failure belongs to an enabled FallbackTrigger class.
+// committed, if non-nil, reports that output already reached the caller,
+// which rules out switching models. The last model's result is returned as-is.
+func (c *Client) withFallback(ctx context.Context, committed func() bool, send func(model string) (*Message, error)) (*Message, error) {
+	models := c.models()
+	triggers := c.config.FallbackOn
+	if triggers == 0 {
+		triggers = DefaultFallbackTriggers
+	}
+
+	var (
+		msg *Message
+		err error
+	)
+	for i, model := range models {
+		last := i == len(models)-1
+		for attempt := 1; ; attempt++ {
+			msg, err = send(model)
+			// Give an empty-handed model one more try before moving on, unless
+			// its output already reached the caller.
+			if !last && err == nil && triggers&FallbackOnEmptyResponse != 0 && isEmptyResponse(msg) && attempt < emptyResponseAttempts &&
+				(committed == nil || !committed()) {
+				continue
+			}
+			break
+		}
+		if last || ctx.Err() != nil || (committed != nil && committed()) {
+			return msg, err
+		}
+		class := classifyFallback(msg, err)
+		if class == 0 || triggers&class == 0 {
+			return msg, err
+		}
+		reason := "empty response"
+		if err != nil {
+			reason = err.Error()
+		}
+		log.Printf("model %s failed (%s); falling back to %s", model, reason, models[i+1])
+	}
+	return msg, err
+}
+
+// classifyFallback maps a send result to the FallbackTrigger it falls under, or 0.
+func classifyFallback(msg *Message, err error) FallbackTrigger {
+	if err == nil {
+		if isEmptyResponse(msg) {
+			return FallbackOnEmptyResponse
+		}
+		return 0
+	}
+	if errors.Is(err, ErrEmptyResponse) || errors.Is(err, ErrNoChoices) {
+		return FallbackOnEmptyResponse
+	}
+	if errors.Is(err, context.Canceled) {
+		return 0
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		// Transport failures and errors reported mid-stream.
+		return FallbackOnProviderError
+	}
+	if isContextLengthError(apiErr) {
+		return FallbackOnContextLength
+	}
+	switch code := apiErr.StatusCode; {
+	case code == http.StatusNotFound, code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+		return FallbackOnProviderError
+	}
+	return 0
+}
+
+// contextLengthMarkers are substrings providers use when a prompt is too long.
+var contextLengthMarkers = []string{
+	"context_length_exceeded",
+	"context length",
+	"context window",
+	"maximum context",
+	"prompt is too long",
+	"input is too long",
+	"too many tokens",
+}
+
+func isContextLengthError(e *APIError) bool {
+	if e.StatusCode != http.StatusBadRequest && e.StatusCode != http.StatusRequestEntityTooLarge {
+		return false
+	}
+	body := strings.ToLower(e.Body)
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyResponse(msg *Message) bool {
+	return msg != nil && len(msg.ToolCalls) == 0 && strings.TrimSpace(messageContent(msg)) == ""
+}
+
+// warmModels fetches usage limits for every model in the chain concurrently.
+func (c *Client) warmModels(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, model := range c.models() {
+		wg.Add(1)
+		go func(model string) {
+			defer wg.Done()
+			_ = c.WarmModelUsageLimits(ctx, model)
+		}(model)
+	}
+	wg.Wait()
+}
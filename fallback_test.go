@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/webforspeed/bono-core/llm"
+)
+
+// scriptedProvider answers each request from a per-model queue of outcomes.
+type scriptedProvider struct {
+	mu      sync.Mutex
+	calls   []string
+	reqs    []*llm.Request
+	outcome map[string][]func() (*llm.Response, error)
+}
+
+func (p *scriptedProvider) SendMessage(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, req.Model)
+	p.reqs = append(p.reqs, req)
+	queue := p.outcome[req.Model]
+	if len(queue) == 0 {
+		return &llm.Response{Model: req.Model, Content: "answer from " + req.Model}, nil
+	}
+	next := queue[0]
+	p.outcome[req.Model] = queue[1:]
+	return next()
+}
+
+func apiFailure(status int, msg string) func() (*llm.Response, error) {
+	return func() (*llm.Response, error) {
+		return nil, &llm.APIError{StatusCode: status, Message: msg}
+	}
+}
+
+func emptyAnswer() (*llm.Response, error) {
+	return &llm.Response{}, nil
+}
+
+func newFallbackTestClient(t *testing.T, p *scriptedProvider, on FallbackTrigger) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	client, err := NewClient(Config{
+		BaseURL:        srv.URL,
+		Model:          "primary/model",
+		FallbackModels: []string{"backup/model", "primary/model", ""},
+		FallbackOn:     on,
+		Provider:       p,
+		APILogPath:     t.TempDir() + "/api.jsonl",
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestClientFallback(t *testing.T) {
+	tests := []struct {
+		name      string
+		on        FallbackTrigger
+		primary   []func() (*llm.Response, error)
+		wantCalls string
+		wantErr   bool
+		wantModel string
+	}{
+		{
+			name:      "provider error falls back",
+			primary:   []func() (*llm.Response, error){apiFailure(503, "overloaded")},
+			wantCalls: "primary/model,backup/model",
+			wantModel: "backup/model",
+		},
+		{
+			name:      "context length falls back",
+			primary:   []func() (*llm.Response, error){apiFailure(400, "This model's maximum context length is 8192 tokens")},
+			wantCalls: "primary/model,backup/model",
+			wantModel: "backup/model",
+		},
+		{
+			name:      "repeated empty response falls back",
+			primary:   []func() (*llm.Response, error){emptyAnswer, emptyAnswer},
+			wantCalls: "primary/model,primary/model,backup/model",
+			wantModel: "backup/model",
+		},
+		{
+			name:      "single empty response retried on same model",
+			primary:   []func() (*llm.Response, error){emptyAnswer},
+			wantCalls: "primary/model,primary/model",
+			wantModel: "primary/model",
+		},
+		{
+			name:      "bad request does not fall back",
+			primary:   []func() (*llm.Response, error){apiFailure(400, "invalid tool schema")},
+			wantCalls: "primary/model",
+			wantErr:   true,
+		},
+		{
+			name:      "disabled class does not fall back",
+			on:        FallbackOnContextLength,
+			primary:   []func() (*llm.Response, error){apiFailure(503, "overloaded")},
+			wantCalls: "primary/model",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &scriptedProvider{outcome: map[string][]func() (*llm.Response, error){"primary/model": tt.primary}}
+			client := newFallbackTestClient(t, p, tt.on)
+
+			msg, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+
+			if got := strings.Join(p.calls, ","); got != tt.wantCalls {
+				t.Fatalf("calls = %s, want %s", got, tt.wantCalls)
+			}
+			if tt.wantErr {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("err = %v, want *APIError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChatCompletion: %v", err)
+			}
+			if msg.Content != "answer from "+tt.wantModel {
+				t.Fatalf("content = %v", msg.Content)
+			}
+			if client.LastModel() != tt.wantModel {
+				t.Fatalf("LastModel = %q, want %q", client.LastModel(), tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestClientFallbackExhaustedReturnsLastError(t *testing.T) {
+	p := &scriptedProvider{outcome: map[string][]func() (*llm.Response, error){
+		"primary/model": {apiFailure(503, "primary down")},
+		"backup/model":  {apiFailure(502, "backup down")},
+	}}
+	client := newFallbackTestClient(t, p, 0)
+
+	_, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 502 {
+		t.Fatalf("err = %v, want backup model's 502", err)
+	}
+}
+
+func TestNewClientWarmsFallbackModels(t *testing.T) {
+	var mu sync.Mutex
+	var warmed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		warmed = append(warmed, r.URL.Path)
+		mu.Unlock()
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(Config{
+		BaseURL:        srv.URL,
+		Model:          "primary/model",
+		FallbackModels: []string{"backup/model", "other/model"},
+		Provider:       &scriptedProvider{},
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	got := strings.Join(warmed, " ")
+	for _, model := range []string{"primary/model", "backup/model", "other/model"} {
+		if !strings.Contains(got, "/models/"+model+"/endpoints") {
+			t.Errorf("model %s not warmed; requests: %s", model, got)
+		}
+	}
+}
+
+func TestClientStreamNoFallbackAfterReasoning(t *testing.T) {
+	var mu sync.Mutex
+	var models []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct{ Model string }
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+		// A reasoning delta and nothing else: an empty answer.
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"id":"1","model":"`+req.Model+`","choices":[{"index":0,"delta":{"reasoning":"thinking"}}]}`+"\n\n")
+	}))
+	t.Cleanup(srv.Close)
+
+	provider, err := llm.NewCompletionsClient(llm.Config{APIKey: "test-key", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(Config{
+		BaseURL:        srv.URL,
+		Model:          "primary/model",
+		FallbackModels: []string{"backup/model"},
+		Provider:       provider,
+		APILogPath:     t.TempDir() + "/api.jsonl",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reasoning []string
+	client.ChatCompletionWithToolsStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil,
+		nil, func(delta string) { reasoning = append(reasoning, delta) })
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(models, ",") != "primary/model" || strings.Join(reasoning, "") != "thinking" {
+		t.Fatalf("models = %q, reasoning = %q; want no fallback once reasoning was streamed", models, reasoning)
+	}
+}
+
+func TestClassifyFallbackIgnoresCancellation(t *testing.T) {
+	if class := classifyFallback(nil, context.Canceled); class != 0 {
+		t.Fatalf("classifyFallback(context.Canceled) = %d, want 0", class)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package core
+
+import "os"
+
+// copyFileOwner is a no-op where file ownership is not exposed.
+func copyFileOwner(f *os.File, info os.FileInfo) error { return nil }
+
+// fileHardLinks reports a single link where link counts are not exposed.
+func fileHardLinks(info os.FileInfo) uint64 { return 1 }
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+)
+
+// checkFileAccess applies the sandbox path policy to a file tool. The path is
+// resolved through symlinks and ".." first, so links can't smuggle an access
+// past the allowlists. Writes must land under WritePaths; reads may also use
+// ReadPaths and ExecPaths. It returns nil when no sandbox is active or the
+// path is allowed, and otherwise a blocked result shaped like a sandboxed
+// shell failure.
+func checkFileAccess(path string, write bool) *ToolResult {
+	cfg, ok := sandboxExecutorConfig(GetExecutor())
+	if !ok {
+		return nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil // the tool reports the bad path itself
+	}
+	real, err := resolvePath(abs)
+	if err != nil {
+		real = abs
+	}
+
+	access, allowed := "write", cfg.WritePaths
+	if !write {
+		access, allowed = "read", slices.Concat(cfg.ReadPaths, cfg.WritePaths, cfg.ExecPaths)
+	}
+	for _, root := range allowed {
+		if root == "" {
+			continue
+		}
+		if r, err := filepath.Abs(root); err == nil {
+			if resolved, err := resolveExistingPrefix(r); err == nil {
+				r = resolved
+			}
+			if pathWithin(r, real) {
+				return nil
+			}
+		}
+	}
+
+	meta := ExecMeta{
+		Sandboxed:     true,
+		SandboxError:  true,
+		SandboxReason: fmt.Sprintf("%s outside allowed paths: %s", access, real),
+	}
+	err = fmt.Errorf("%w: %s", ErrSandboxBlocked, meta.SandboxReason)
+	return &ToolResult{
+		Success:  false,
+		Output:   err.Error(),
+		Error:    err,
+		Status:   "sandbox blocked",
+		ExecMeta: &meta,
+	}
+}
+
+// guardFileAccess checks every path a file tool is about to touch. A blocked
+// path is offered to onFallback, as a blocked shell command would be; the
+// call proceeds only if every blocked path is approved. It returns nil when
+// the tool may run.
+func guardFileAccess(tool string, paths []string, write bool, onFallback func(cmd, reason string) bool) *ToolResult {
+	for _, path := range paths {
+		blocked := checkFileAccess(path, write)
+		if blocked == nil {
+			continue
+		}
+		if shouldOfferSandboxFallback(*blocked) && onFallback != nil && onFallback(tool+" "+path, sandboxFallbackReason(*blocked)) {
+			continue
+		}
+		return blocked
+	}
+	return nil
+}
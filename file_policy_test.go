@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileToolsSandboxPolicy(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	t.Chdir(workspace)
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("keep"), 0644)
+	os.Symlink(outside, filepath.Join(workspace, "link"))
+	os.WriteFile("ok.txt", []byte("old"), 0644)
+
+	defaultExecutor = &LinuxSandboxExecutor{config: SandboxConfig{
+		Enabled:                true,
+		WritePaths:             []string{workspace},
+		FallbackOutsideSandbox: true,
+	}}
+
+	var asked []string
+	approve := false
+	onFallback := func(cmd, reason string) bool {
+		asked = append(asked, cmd+": "+reason)
+		return approve
+	}
+
+	blocked := []struct {
+		name string
+		tool *ToolDef
+		args map[string]any
+	}{
+		{"read absolute", ReadFileToolWithFallback(onFallback), map[string]any{"path": filepath.Join(outside, "secret")}},
+		{"read dotdot", ReadFileToolWithFallback(onFallback), map[string]any{"path": "../" + filepath.Base(outside) + "/secret"}},
+		{"write through link", WriteFileToolWithFallback(onFallback), map[string]any{"path": "link/new.txt", "content": "x"}},
+		{"edit", EditFileToolWithFallback(onFallback), map[string]any{"path": filepath.Join(outside, "secret"), "old_string": "keep", "new_string": "lost"}},
+		{"patch", ApplyPatchTool(onFallback), map[string]any{"patch": "*** Begin Patch\n*** Add File: " + filepath.Join(outside, "p.txt") + "\n+x\n*** End Patch"}},
+	}
+	for _, tt := range blocked {
+		asked = nil
+		res := tt.tool.Execute(tt.args)
+		if res.Success || !errors.Is(res.Error, ErrSandboxBlocked) {
+			t.Fatalf("%s: expected ErrSandboxBlocked, got %v", tt.name, res.Error)
+		}
+		if res.ExecMeta == nil || !res.ExecMeta.Sandboxed || !res.ExecMeta.SandboxError {
+			t.Fatalf("%s: expected sandbox ExecMeta, got %+v", tt.name, res.ExecMeta)
+		}
+		if len(asked) != 1 || !strings.Contains(asked[0], "outside allowed paths") {
+			t.Fatalf("%s: fallback calls = %q", tt.name, asked)
+		}
+	}
+	if got := readTestFile(t, filepath.Join(outside, "secret")); got != "keep" {
+		t.Fatalf("secret modified: %q", got)
+	}
+
+	// Paths inside the workspace never ask.
+	asked = nil
+	if res := EditFileToolWithFallback(onFallback).Execute(map[string]any{"path": "ok.txt", "old_string": "old", "new_string": "new"}); !res.Success {
+		t.Fatalf("edit in workspace failed: %v", res.Error)
+	}
+	if len(asked) != 0 {
+		t.Fatalf("unexpected fallback calls: %q", asked)
+	}
+
+	// An approved fallback runs the call outside the policy.
+	approve = true
+	res := ReadFileToolWithFallback(onFallback).Execute(map[string]any{"path": filepath.Join(outside, "secret")})
+	if !res.Success || !strings.Contains(res.Output, "keep") {
+		t.Fatalf("approved read failed: %+v", res)
+	}
+}
+
+func TestCheckFileAccessReadPaths(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+
+	readable := t.TempDir()
+	writable := t.TempDir()
+	defaultExecutor = &LinuxSandboxExecutor{config: SandboxConfig{
+		Enabled:    true,
+		ReadPaths:  []string{readable},
+		WritePaths: []string{writable},
+	}}
+
+	tests := []struct {
+		path  string
+		write bool
+		ok    bool
+	}{
+		{filepath.Join(readable, "a"), false, true},
+		{filepath.Join(readable, "a"), true, false},
+		{filepath.Join(writable, "a"), false, true},
+		{filepath.Join(writable, "a", "..", "b"), true, true},
+		{filepath.Join(writable, "..", "x"), true, false},
+		{"/etc/passwd", false, false},
+	}
+	for _, tt := range tests {
+		if got := checkFileAccess(tt.path, tt.write) == nil; got != tt.ok {
+			t.Errorf("checkFileAccess(%q, write=%v) allowed = %v, want %v", tt.path, tt.write, got, tt.ok)
+		}
+	}
+
+	InitSandbox(SandboxConfig{})
+	if checkFileAccess("/etc/passwd", true) != nil {
+		t.Fatal("expected no policy without a sandbox")
+	}
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// maxSymlinkHops bounds symlink chains when resolving a write target.
+const maxSymlinkHops = 40
+
+// fileWrite is a staged replacement of one file. Content is written to a
+// temp file beside the target and renamed over it on commit, so readers
+// never see a partial file. Files whose ownership can't be reproduced on a
+// new inode, or that have hard links, are rewritten in place instead.
+type fileWrite struct {
+	target  string // resolved path, after following symlinks
+	tmp     string // staged temp file; empty for in-place writes
+	content []byte
+	mode    os.FileMode
+	info    os.FileInfo // existing target; nil for a new file
+	orig    []byte      // existing content
+}
+
+// writeFileAtomic replaces path's contents via stageFileWrite and commit.
+// New files are created with 0644.
+func writeFileAtomic(path string, content []byte) error {
+	w, err := stageFileWrite(path, content, 0644)
+	if err != nil {
+		return err
+	}
+	return w.commit()
+}
+
+// writeFileDiff is writeFileAtomic that also returns the change as a
+// unified diff. With dryRun set, the diff is computed but nothing is written.
+func writeFileDiff(path string, content []byte, dryRun bool) (string, error) {
+	w, err := planFileWrite(path, content, 0644)
+	if err != nil {
+		return "", err
+	}
+	diff := w.diff(path)
+	if dryRun {
+		return diff, nil
+	}
+	if err := w.stage(); err != nil {
+		return "", err
+	}
+	return diff, w.commit()
+}
+
+// stageFileWrite prepares to replace path with content: planFileWrite
+// followed by stage.
+func stageFileWrite(path string, content []byte, newMode os.FileMode) (*fileWrite, error) {
+	w, err := planFileWrite(path, content, newMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.stage(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// planFileWrite works out how path would be replaced with content without
+// touching the disk. An existing file keeps its mode, owner, BOM and CRLF
+// line endings; newMode applies to new files. Symlinks are written through
+// to their target, which must stay in the workspace unless
+// SandboxConfig.AllowSymlinkEscape is set.
+func planFileWrite(path string, content []byte, newMode os.FileMode) (*fileWrite, error) {
+	target, err := resolveWriteTarget(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &fileWrite{target: target, content: content, mode: newMode}
+
+	info, err := os.Stat(target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case info.IsDir():
+		return nil, fmt.Errorf("%s is a directory", path)
+	default:
+		w.info = info
+		w.mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if existing, err := os.ReadFile(target); err == nil {
+			w.orig = existing
+			w.content = matchTextFormat(existing, content)
+		}
+	}
+	return w, nil
+}
+
+// stage writes the planned content to a temp file beside the target, or
+// leaves it for an in-place write on commit.
+func (w *fileWrite) stage() error {
+	if err := os.MkdirAll(filepath.Dir(w.target), 0755); err != nil {
+		return err
+	}
+	if w.info != nil && fileHardLinks(w.info) > 1 {
+		return nil // renaming would split the hard link
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(w.target), "."+filepath.Base(w.target)+".tmp-*")
+	if err != nil {
+		if w.info != nil {
+			return nil // directory not writable but the file may be
+		}
+		return err
+	}
+	w.tmp = tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(w.tmp)
+		w.tmp = ""
+		return err
+	}
+	if _, err := tmp.Write(w.content); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Chmod(w.mode); err != nil {
+		return fail(err)
+	}
+	if w.info != nil {
+		if err := copyFileOwner(tmp, w.info); err != nil {
+			// Can't hand the new inode to the original owner; write in place.
+			fail(nil)
+			return nil
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(w.tmp)
+		w.tmp = ""
+		return err
+	}
+	return nil
+}
+
+// diff returns the change as a unified diff labelled with path.
+func (w *fileWrite) diff(path string) string {
+	return fileDiff(path, w.info != nil, string(w.orig), true, string(w.content))
+}
+
+// commit moves the staged content into place.
+func (w *fileWrite) commit() error {
+	if w.tmp == "" {
+		return os.WriteFile(w.target, w.content, w.mode)
+	}
+	if err := os.Rename(w.tmp, w.target); err != nil {
+		os.Remove(w.tmp)
+		return err
+	}
+	return nil
+}
+
+// discard drops a staged write without touching the target.
+func (w *fileWrite) discard() {
+	if w.tmp != "" {
+		os.Remove(w.tmp)
+	}
+}
+
+// matchTextFormat carries the existing file's BOM and CRLF line endings
+// over to content written without them. Line endings are only converted
+// when every line of the existing file ends in CRLF; a file mixing CRLF and
+// LF is written as given, so its intentional LF lines are kept.
+func matchTextFormat(existing, content []byte) []byte {
+	if bytes.HasPrefix(existing, utf8BOM) && !bytes.HasPrefix(content, utf8BOM) {
+		content = append(append([]byte(nil), utf8BOM...), content...)
+	}
+	crlf := bytes.Count(existing, []byte("\r\n"))
+	if crlf > 0 && crlf == bytes.Count(existing, []byte("\n")) {
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+	}
+	return content
+}
+
+// resolveWriteTarget follows symlinks in path and returns the file to write.
+// A path inside the workspace that resolves outside it is refused unless
+// the sandbox config allows it.
+func resolveWriteTarget(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	real, err := resolvePath(abs)
+	if err != nil {
+		return "", err
+	}
+	if real == abs || activeSandboxConfig().AllowSymlinkEscape {
+		return real, nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil || !pathWithin(cwd, abs) {
+		return real, nil // not a workspace path; nothing to escape from
+	}
+	workspace := cwd
+	if resolved, err := filepath.EvalSymlinks(cwd); err == nil {
+		workspace = resolved
+	}
+	if pathWithin(workspace, real) {
+		return real, nil
+	}
+	return "", fmt.Errorf("%w: %s -> %s", ErrSymlinkEscape, path, real)
+}
+
+// resolvePath returns abs with every symlink resolved, including a dangling
+// final link, whose target may not exist yet.
+func resolvePath(abs string) (string, error) {
+	p := abs
+	for hops := 0; ; hops++ {
+		if hops > maxSymlinkHops {
+			return "", fmt.Errorf("%s: too many levels of symbolic links", abs)
+		}
+		dir, err := resolveExistingPrefix(filepath.Dir(p))
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(dir, filepath.Base(p))
+		info, err := os.Lstat(p)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return p, nil
+		}
+		link, err := os.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(p), link)
+		}
+		p = filepath.Clean(link)
+	}
+}
+
+// resolveExistingPrefix resolves symlinks in the longest existing ancestor
+// of dir and appends the missing remainder unchanged.
+func resolveExistingPrefix(dir string) (string, error) {
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				real = filepath.Join(real, missing[i])
+			}
+			return real, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(dir))
+		dir = parent
+	}
+}
+
+// pathWithin reports whether path is root or below it.
+func pathWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicPreservesModeAndFormat(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\r\necho hi\r\n"), 0755)
+
+	if res := ExecuteWriteFile(script, "#!/bin/sh\necho bye\n"); !res.Success {
+		t.Fatalf("write failed: %v", res.Error)
+	}
+	info, _ := os.Stat(script)
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("mode = %v, want 0755", info.Mode().Perm())
+	}
+	if got := readTestFile(t, script); got != "#!/bin/sh\r\necho bye\r\n" {
+		t.Fatalf("content = %q, want CRLF preserved", got)
+	}
+
+	bom := filepath.Join(dir, "bom.txt")
+	os.WriteFile(bom, []byte("\xEF\xBB\xBFold\n"), 0644)
+	if res := ExecuteEditFile(bom, "old", "new", false); !res.Success {
+		t.Fatalf("edit failed: %v", res.Error)
+	}
+	if got := readTestFile(t, bom); got != "\xEF\xBB\xBFnew\n" {
+		t.Fatalf("content = %q, want BOM preserved", got)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("temp files left behind: %v", entries)
+	}
+}
+
+func TestWriteFileMixedLineEndings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mixed.txt")
+	os.WriteFile(path, []byte("a\r\nb\r\nc\n"), 0644)
+
+	if res := ExecuteEditFile(path, "a", "x", false); !res.Success {
+		t.Fatalf("edit failed: %v", res.Error)
+	}
+	if got := readTestFile(t, path); got != "x\r\nb\r\nc\n" {
+		t.Fatalf("content after edit = %q, want LF line kept", got)
+	}
+
+	if res := ExecuteWriteFile(path, "x\r\nb\r\nd\n"); !res.Success {
+		t.Fatalf("write failed: %v", res.Error)
+	}
+	if got := readTestFile(t, path); got != "x\r\nb\r\nd\n" {
+		t.Fatalf("content after write = %q, want it written as given", got)
+	}
+}
+
+func TestWriteFileAtomicNewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "b", "new.txt")
+	if res := ExecuteWriteFile(path, "x\n"); !res.Success {
+		t.Fatalf("write failed: %v", res.Error)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Fatalf("mode = %v, want 0644", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileSymlinks(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+
+	workspace := t.TempDir()
+	outside := t.TempDir()
+	t.Chdir(workspace)
+
+	secret := filepath.Join(outside, "secret")
+	os.WriteFile(secret, []byte("keep"), 0600)
+	os.Symlink(secret, filepath.Join(workspace, "escape"))
+	os.Symlink(outside, filepath.Join(workspace, "outdir"))
+	os.WriteFile(filepath.Join(workspace, "real.txt"), []byte("old"), 0644)
+	os.Symlink("real.txt", filepath.Join(workspace, "inside"))
+
+	InitSandbox(SandboxConfig{})
+	for _, path := range []string{"escape", "outdir/new.txt"} {
+		res := ExecuteWriteFile(path, "pwned")
+		if res.Success || !errors.Is(res.Error, ErrSymlinkEscape) {
+			t.Fatalf("%s: expected ErrSymlinkEscape, got %v", path, res.Error)
+		}
+	}
+	if got := readTestFile(t, secret); got != "keep" {
+		t.Fatalf("secret modified: %q", got)
+	}
+
+	// Links inside the workspace are written through, keeping the link.
+	if res := ExecuteWriteFile("inside", "new"); !res.Success {
+		t.Fatalf("write through in-workspace link failed: %v", res.Error)
+	}
+	if got := readTestFile(t, "real.txt"); got != "new" {
+		t.Fatalf("real.txt = %q", got)
+	}
+	if info, _ := os.Lstat("inside"); info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink replaced by a regular file")
+	}
+
+	InitSandbox(SandboxConfig{AllowSymlinkEscape: true})
+	if res := ExecuteWriteFile("escape", "allowed"); !res.Success {
+		t.Fatalf("write with AllowSymlinkEscape failed: %v", res.Error)
+	}
+	if got := readTestFile(t, secret); got != "allowed" {
+		t.Fatalf("secret = %q", got)
+	}
+}
@@ -3,7 +3,7 @@ module github.com/webforspeed/bono-core
 go 1.25.0
 
 require (
-	github.com/asg017/sqlite-vec-go-bindings v0.1.6 // indirect
-	github.com/mattn/go-sqlite3 v1.14.34 // indirect
-	github.com/smacker/go-tree-sitter v0.0.0-20240827094217-dd81d9e9be82 // indirect
+	github.com/asg017/sqlite-vec-go-bindings v0.1.6
+	github.com/mattn/go-sqlite3 v1.14.34
+	github.com/smacker/go-tree-sitter v0.0.0-20240827094217-dd81d9e9be82
 )
@@ -3,7 +3,6 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -121,13 +120,15 @@ type Usage struct {
 
 // Config holds configuration for creating a Provider.
 type Config struct {
-	APIKey      string        // Required for remote providers. Can be empty for local providers like Ollama.
-	BaseURL     string        // Base URL (defaults to https://openrouter.ai/api/v1).
-	HTTPTimeout time.Duration // HTTP client timeout (defaults to 120s).
-	HTTPReferer string        // Optional HTTP-Referer header.
-	AppTitle    string        // Optional X-OpenRouter-Title header.
-	Categories  string        // Optional X-OpenRouter-Categories header (comma-separated).
-	HTTPClient  *http.Client  // If non-nil, used instead of creating a default client.
+	APIKey      string             // Required for remote providers. Can be empty for local providers like Ollama.
+	BaseURL     string             // Base URL (defaults to https://openrouter.ai/api/v1).
+	HTTPTimeout time.Duration      // HTTP client timeout (defaults to 120s).
+	HTTPReferer string             // Optional HTTP-Referer header.
+	AppTitle    string             // Optional X-OpenRouter-Title header.
+	Categories  string             // Optional X-OpenRouter-Categories header (comma-separated).
+	HTTPClient  *http.Client       // If non-nil, used instead of creating a default client.
+	Retry       RetryPolicy        // Retry policy for 429/5xx and transport errors (zero value uses defaults).
+	OnRetry     func(RetryAttempt) // Optional; called before waiting to retry a failed attempt.
 	// SkipAuth disables the Authorization header. Use for local providers like Ollama.
 	SkipAuth bool
 }
@@ -174,7 +175,7 @@ type MessagesClient struct {
 
 // NewMessagesClient creates a new MessagesClient.
 func NewMessagesClient(cfg Config) (*MessagesClient, error) {
-	if cfg.APIKey == "" {
+	if cfg.APIKey == "" && !cfg.SkipAuth {
 		return nil, ErrMissingAPIKey
 	}
 	cfg.defaults()
@@ -197,26 +198,9 @@ func (c *MessagesClient) SendMessage(ctx context.Context, req *Request) (*Respon
 		return nil, fmt.Errorf("llm: marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.BaseURL+"/messages", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("llm: create request: %w", err)
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+c.config.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-	if c.config.HTTPReferer != "" {
-		httpReq.Header.Set("HTTP-Referer", c.config.HTTPReferer)
-	}
-	if c.config.AppTitle != "" {
-		httpReq.Header.Set("X-OpenRouter-Title", c.config.AppTitle)
-	}
-	if c.config.Categories != "" {
-		httpReq.Header.Set("X-OpenRouter-Categories", c.config.Categories)
-	}
-
-	httpResp, err := c.httpClient.Do(httpReq)
+	httpResp, err := postWithRetry(ctx, c.httpClient, c.config, "/messages", body, false)
 	if err != nil {
-		return nil, fmt.Errorf("llm: http request: %w", err)
+		return nil, err
 	}
 	defer httpResp.Body.Close()
 
@@ -225,10 +209,6 @@ func (c *MessagesClient) SendMessage(ctx context.Context, req *Request) (*Respon
 		return nil, fmt.Errorf("llm: read response: %w", err)
 	}
 
-	if httpResp.StatusCode != http.StatusOK {
-		return nil, parseAPIError(httpResp.StatusCode, respBody)
-	}
-
 	var wireResp messagesResponse
 	if err := json.Unmarshal(respBody, &wireResp); err != nil {
 		return nil, fmt.Errorf("llm: decode response: %w", err)
@@ -485,26 +465,9 @@ func (c *CompletionsClient) SendMessage(ctx context.Context, req *Request) (*Res
 		return nil, fmt.Errorf("llm: marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.BaseURL+"/chat/completions", bytes.NewReader(body))
+	httpResp, err := postWithRetry(ctx, c.httpClient, c.config, "/chat/completions", body, false)
 	if err != nil {
-		return nil, fmt.Errorf("llm: create request: %w", err)
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+c.config.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-	if c.config.HTTPReferer != "" {
-		httpReq.Header.Set("HTTP-Referer", c.config.HTTPReferer)
-	}
-	if c.config.AppTitle != "" {
-		httpReq.Header.Set("X-OpenRouter-Title", c.config.AppTitle)
-	}
-	if c.config.Categories != "" {
-		httpReq.Header.Set("X-OpenRouter-Categories", c.config.Categories)
-	}
-
-	httpResp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("llm: http request: %w", err)
+		return nil, err
 	}
 	defer httpResp.Body.Close()
 
@@ -513,10 +476,6 @@ func (c *CompletionsClient) SendMessage(ctx context.Context, req *Request) (*Res
 		return nil, fmt.Errorf("llm: read response: %w", err)
 	}
 
-	if httpResp.StatusCode != http.StatusOK {
-		return nil, parseAPIError(httpResp.StatusCode, respBody)
-	}
-
 	var wireResp completionsResponse
 	if err := json.Unmarshal(respBody, &wireResp); err != nil {
 		return nil, fmt.Errorf("llm: decode response: %w", err)
@@ -783,7 +742,7 @@ type ResponsesClient struct {
 
 // NewResponsesClient creates a new ResponsesClient.
 func NewResponsesClient(cfg Config) (*ResponsesClient, error) {
-	if cfg.APIKey == "" {
+	if cfg.APIKey == "" && !cfg.SkipAuth {
 		return nil, ErrMissingAPIKey
 	}
 	cfg.defaults()
@@ -806,26 +765,9 @@ func (c *ResponsesClient) SendMessage(ctx context.Context, req *Request) (*Respo
 		return nil, fmt.Errorf("llm: marshal request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.BaseURL+"/responses", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("llm: create request: %w", err)
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+c.config.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-	if c.config.HTTPReferer != "" {
-		httpReq.Header.Set("HTTP-Referer", c.config.HTTPReferer)
-	}
-	if c.config.AppTitle != "" {
-		httpReq.Header.Set("X-OpenRouter-Title", c.config.AppTitle)
-	}
-	if c.config.Categories != "" {
-		httpReq.Header.Set("X-OpenRouter-Categories", c.config.Categories)
-	}
-
-	httpResp, err := c.httpClient.Do(httpReq)
+	httpResp, err := postWithRetry(ctx, c.httpClient, c.config, "/responses", body, false)
 	if err != nil {
-		return nil, fmt.Errorf("llm: http request: %w", err)
+		return nil, err
 	}
 	defer httpResp.Body.Close()
 
@@ -834,10 +776,6 @@ func (c *ResponsesClient) SendMessage(ctx context.Context, req *Request) (*Respo
 		return nil, fmt.Errorf("llm: read response: %w", err)
 	}
 
-	if httpResp.StatusCode != http.StatusOK {
-		return nil, parseAPIError(httpResp.StatusCode, respBody)
-	}
-
 	var wireResp responsesResponse
 	if err := json.Unmarshal(respBody, &wireResp); err != nil {
 		return nil, fmt.Errorf("llm: decode response: %w", err)
@@ -431,7 +431,7 @@ func TestSendMessage_APIErrorUnparseable(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	client, _ := NewMessagesClient(Config{APIKey: "key", BaseURL: srv.URL})
+	client, _ := NewMessagesClient(Config{APIKey: "key", BaseURL: srv.URL, Retry: RetryPolicy{MaxRetries: -1}})
 
 	_, err := client.SendMessage(context.Background(), &Request{
 		Model:     "test",
@@ -1035,7 +1035,7 @@ func TestCompletions_APIError(t *testing.T) {
 	}))
 	defer srv.Close()
 
-	client, _ := NewCompletionsClient(Config{APIKey: "key", BaseURL: srv.URL})
+	client, _ := NewCompletionsClient(Config{APIKey: "key", BaseURL: srv.URL, Retry: RetryPolicy{MaxRetries: -1}})
 
 	_, err := client.SendMessage(context.Background(), &Request{
 		Model:     "test",
@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry defaults applied when RetryPolicy fields are zero.
+const (
+	DefaultMaxRetries     = 2
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	DefaultRetryMaxDelay  = 30 * time.Second
+)
+
+// RetryPolicy controls how providers retry failed HTTP attempts.
+// Rate limits (429), timeouts (408), conflicts (409), server errors (5xx)
+// and transport errors are retried; other statuses fail immediately.
+type RetryPolicy struct {
+	MaxRetries int           // Retries after the first attempt; 0 uses DefaultMaxRetries, <0 disables retries.
+	BaseDelay  time.Duration // Backoff before the first retry, doubled per attempt with jitter (default 500ms).
+	MaxDelay   time.Duration // Cap on any single wait, including Retry-After (default 30s).
+}
+
+// RetryAttempt describes a failed attempt that is about to be retried.
+type RetryAttempt struct {
+	Attempt    int           // 1-based number of the attempt that failed.
+	MaxRetries int           // Retries allowed by the policy.
+	Err        error         // *APIError for HTTP failures, the transport error otherwise.
+	Delay      time.Duration // Wait before the next attempt.
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = DefaultMaxRetries
+	}
+	if p.MaxRetries < 0 {
+		p.MaxRetries = 0
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryMaxDelay
+	}
+	return p
+}
+
+// backoff returns the jittered exponential delay before retry n (1-based):
+// a random duration in [d/2, d] where d = BaseDelay * 2^(n-1), capped at MaxDelay.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay << (n - 1)
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isRetryableStatus reports whether an HTTP status is worth retrying.
+func isRetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return code >= 500
+}
+
+// retryAfter parses Retry-After (seconds or HTTP date) and the millisecond
+// variant some providers send. Returns false when neither header is usable.
+func retryAfter(h http.Header) (time.Duration, bool) {
+	if v := h.Get("Retry-After-Ms"); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms >= 0 {
+			return time.Duration(ms * float64(time.Millisecond)), true
+		}
+	}
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// postWithRetry POSTs body to cfg.BaseURL+path and returns the 200 response
+// with its body unread. Retryable failures are retried under cfg.Retry, with
+// cfg.OnRetry told about each failed attempt. A final non-200 status is
+// returned as *APIError. Waits end early when ctx is cancelled.
+func postWithRetry(ctx context.Context, httpClient *http.Client, cfg Config, path string, body []byte, stream bool) (*http.Response, error) {
+	policy := cfg.Retry.withDefaults()
+
+	for attempt := 1; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.BaseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("llm: create request: %w", err)
+		}
+		setRequestHeaders(httpReq, cfg, stream)
+
+		var (
+			attemptErr error
+			delay      time.Duration
+			hinted     bool
+		)
+		httpResp, err := httpClient.Do(httpReq)
+		switch {
+		case err != nil:
+			attemptErr = fmt.Errorf("llm: http request: %w", err)
+			if ctx.Err() != nil {
+				return nil, attemptErr
+			}
+		case httpResp.StatusCode == http.StatusOK:
+			return httpResp, nil
+		default:
+			respBody, _ := io.ReadAll(httpResp.Body)
+			httpResp.Body.Close()
+			apiErr := parseAPIError(httpResp.StatusCode, respBody)
+			if !isRetryableStatus(httpResp.StatusCode) {
+				return nil, apiErr
+			}
+			attemptErr = apiErr
+			delay, hinted = retryAfter(httpResp.Header)
+		}
+
+		if attempt > policy.MaxRetries {
+			return nil, attemptErr
+		}
+		if !hinted {
+			delay = policy.backoff(attempt)
+		}
+		if delay > policy.MaxDelay {
+			delay = policy.MaxDelay
+		}
+		if cfg.OnRetry != nil {
+			cfg.OnRetry(RetryAttempt{
+				Attempt:    attempt,
+				MaxRetries: policy.MaxRetries,
+				Err:        attemptErr,
+				Delay:      delay,
+			})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(ctx.Err(), attemptErr)
+		case <-timer.C:
+		}
+	}
+}
+
+// setRequestHeaders applies auth, content type and OpenRouter attribution headers.
+func setRequestHeaders(httpReq *http.Request, cfg Config, stream bool) {
+	httpReq.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if cfg.HTTPReferer != "" {
+		httpReq.Header.Set("HTTP-Referer", cfg.HTTPReferer)
+	}
+	if cfg.AppTitle != "" {
+		httpReq.Header.Set("X-OpenRouter-Title", cfg.AppTitle)
+	}
+	if cfg.Categories != "" {
+		httpReq.Header.Set("X-OpenRouter-Categories", cfg.Categories)
+	}
+}
@@ -0,0 +1,178 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetry keeps test retries quick.
+var fastRetry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+func TestRetry_SucceedsAfterServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":{"code":503,"message":"upstream unavailable"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"c1","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	var attempts []RetryAttempt
+	cfg := Config{APIKey: "key", BaseURL: srv.URL, Retry: fastRetry}
+	cfg.OnRetry = func(a RetryAttempt) { attempts = append(attempts, a) }
+	client, _ := NewCompletionsClient(cfg)
+
+	resp, err := client.SendMessage(context.Background(), &Request{Model: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "ok" {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+	if len(attempts) != 2 || attempts[0].Attempt != 1 || attempts[1].Attempt != 2 {
+		t.Fatalf("OnRetry attempts = %+v", attempts)
+	}
+	var apiErr *APIError
+	if !errors.As(attempts[0].Err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("attempt error = %v", attempts[0].Err)
+	}
+}
+
+func TestRetry_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewMessagesClient(Config{APIKey: "key", BaseURL: srv.URL, Retry: fastRetry})
+	_, err := client.SendMessage(context.Background(), &Request{Model: "m", MaxTokens: 10})
+
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("err = %v, want 429 *APIError", err)
+	}
+	if calls != 4 {
+		t.Errorf("calls = %d, want 1 attempt + 3 retries", calls)
+	}
+}
+
+func TestRetry_NonRetryableStatus(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":"bad","message":"nope"}}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewResponsesClient(Config{APIKey: "key", BaseURL: srv.URL, Retry: fastRetry})
+	if _, err := client.SendMessage(context.Background(), &Request{Model: "m"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestRetry_HonoursRetryAfter(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id":"c1","model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	var delays []time.Duration
+	cfg := Config{APIKey: "key", BaseURL: srv.URL}
+	cfg.Retry = RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 20 * time.Millisecond}
+	cfg.OnRetry = func(a RetryAttempt) { delays = append(delays, a.Delay) }
+	client, _ := NewCompletionsClient(cfg)
+
+	if _, err := client.SendMessage(context.Background(), &Request{Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	// Retry-After: 2s is capped by MaxDelay rather than replaced by backoff.
+	if len(delays) != 1 || delays[0] != 20*time.Millisecond {
+		t.Fatalf("delays = %v, want [20ms]", delays)
+	}
+}
+
+func TestRetry_ContextCancelledDuringBackoff(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := Config{APIKey: "key", BaseURL: srv.URL}
+	cfg.Retry = RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	cfg.OnRetry = func(RetryAttempt) { cancel() }
+	client, _ := NewCompletionsClient(cfg)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.SendMessage(ctx, &Request{Model: "m"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SendMessage did not stop waiting after cancellation")
+	}
+}
+
+func TestRetryAfterParsing(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{"seconds", http.Header{"Retry-After": {"3"}}, 3 * time.Second, true},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}}, 250 * time.Millisecond, true},
+		{"past date", http.Header{"Retry-After": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, 0, true},
+		{"missing", http.Header{}, 0, false},
+		{"garbage", http.Header{"Retry-After": {"soon"}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.header)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("retryAfter = %v, %v; want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRetryBackoffJitterBounds(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+	for i := 0; i < 50; i++ {
+		if d := p.backoff(2); d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("backoff(2) = %v, want within [100ms, 200ms]", d)
+		}
+		if d := p.backoff(10); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("backoff(10) = %v, want capped within [500ms, 1s]", d)
+		}
+	}
+}
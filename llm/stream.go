@@ -2,7 +2,6 @@ package llm
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -23,6 +22,8 @@ type StreamEvent struct {
 	ContentDelta   string    // text content fragment
 	ReasoningDelta string    // reasoning text fragment
 	Response       *Response // set on final event with fully assembled response
+
+	err error // set on a terminal event when the stream failed
 }
 
 // Stream is an iterator over streaming events.
@@ -37,6 +38,10 @@ func (s *Stream) Next() (StreamEvent, bool) {
 	if !ok {
 		return StreamEvent{}, false
 	}
+	if evt.err != nil {
+		s.err = evt.err
+		return StreamEvent{}, false
+	}
 	if evt.Response == nil && evt.ContentDelta == "" && evt.ReasoningDelta == "" {
 		// error-only event
 		s.err = fmt.Errorf("llm: stream ended unexpectedly")
@@ -105,45 +110,54 @@ func (c *CompletionsClient) SendMessageStream(ctx context.Context, req *Request)
 		StreamOptions:      streamOptions{IncludeUsage: true},
 	}
 
-	body, err := json.Marshal(sr)
+	body, err := openSSEStream(ctx, c.httpClient, c.config, "/chat/completions", sr)
 	if err != nil {
-		return nil, fmt.Errorf("llm: marshal request: %w", err)
+		return nil, err
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.BaseURL+"/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("llm: create request: %w", err)
-	}
+	ch := make(chan StreamEvent, 32)
+	stream := &Stream{ch: ch}
 
-	httpReq.Header.Set("Authorization", "Bearer "+c.config.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-	if c.config.HTTPReferer != "" {
-		httpReq.Header.Set("HTTP-Referer", c.config.HTTPReferer)
-	}
-	if c.config.AppTitle != "" {
-		httpReq.Header.Set("X-OpenRouter-Title", c.config.AppTitle)
-	}
-	if c.config.Categories != "" {
-		httpReq.Header.Set("X-OpenRouter-Categories", c.config.Categories)
-	}
+	go readSSEStream(body, ch)
 
-	httpResp, err := c.httpClient.Do(httpReq)
+	return stream, nil
+}
+
+// openSSEStream POSTs payload to path with stream headers and returns the
+// response body for SSE parsing. Non-200 responses are returned as *APIError.
+// Retries only cover establishing the stream, never a stream in progress.
+func openSSEStream(ctx context.Context, httpClient *http.Client, cfg Config, path string, payload any) (io.ReadCloser, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		return nil, fmt.Errorf("llm: http request: %w", err)
+		return nil, fmt.Errorf("llm: marshal request: %w", err)
 	}
 
-	if httpResp.StatusCode != http.StatusOK {
-		defer httpResp.Body.Close()
-		respBody, _ := io.ReadAll(httpResp.Body)
-		return nil, parseAPIError(httpResp.StatusCode, respBody)
+	httpResp, err := postWithRetry(ctx, httpClient, cfg, path, body, true)
+	if err != nil {
+		return nil, err
 	}
+	return httpResp.Body, nil
+}
 
-	ch := make(chan StreamEvent, 32)
-	stream := &Stream{ch: ch}
-
-	go readSSEStream(httpResp.Body, ch)
+// scanSSEData calls fn with the payload of each SSE data line until fn returns
+// false or the body is exhausted. Comments (keepalives), event names and blank
+// lines are skipped; every provider repeats the event type inside the JSON.
+func scanSSEData(r io.Reader, fn func(data string) bool) error {
+	scanner := bufio.NewScanner(r)
+	// Increase buffer for potentially large SSE lines.
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 
-	return stream, nil
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		if !fn(data) {
+			return nil
+		}
+	}
+	return scanner.Err()
 }
 
 // readSSEStream reads SSE lines from the body, parses chunks, and sends StreamEvents.
@@ -165,38 +179,15 @@ func readSSEStream(body io.ReadCloser, ch chan<- StreamEvent) {
 		finished     bool            // set when finish_reason is received
 	)
 
-	scanner := bufio.NewScanner(body)
-	// Increase buffer for potentially large SSE lines.
-	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// SSE comment (keepalive) — skip.
-		if strings.HasPrefix(line, ":") {
-			continue
-		}
-
-		// Empty line — SSE event boundary, skip.
-		if line == "" {
-			continue
-		}
-
-		// We only care about data lines.
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
-
+	scanSSEData(body, func(data string) bool {
 		// Stream termination.
 		if data == "[DONE]" {
-			break
+			return false
 		}
 
 		var chunk completionsStreamChunk
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
-			continue // skip malformed chunks
+			return true // skip malformed chunks
 		}
 
 		if chunk.Model != "" {
@@ -218,12 +209,12 @@ func readSSEStream(body io.ReadCloser, ch chan<- StreamEvent) {
 			}
 			// If we already saw finish_reason, usage is the last thing we need.
 			if finished {
-				break
+				return false
 			}
 		}
 
 		if len(chunk.Choices) == 0 {
-			continue
+			return true
 		}
 
 		choice := chunk.Choices[0]
@@ -231,7 +222,7 @@ func readSSEStream(body io.ReadCloser, ch chan<- StreamEvent) {
 
 		// After finish_reason, don't process more deltas.
 		if finished {
-			continue
+			return true
 		}
 
 		// Content delta.
@@ -268,7 +259,8 @@ func readSSEStream(body io.ReadCloser, ch chan<- StreamEvent) {
 			stopReason = finishReasonToStopReason(*choice.FinishReason)
 			finished = true
 		}
-	}
+		return true
+	})
 
 	// Build final assembled Response.
 	resp := &Response{
@@ -301,3 +293,343 @@ type toolCallAccum struct {
 	name string
 	args strings.Builder
 }
+
+// --- SSE streaming wire types for the Anthropic Messages API ---
+
+type messagesStreamEvent struct {
+	Type         string               `json:"type"`
+	Index        int                  `json:"index"`
+	Message      *messagesResponse    `json:"message,omitempty"`       // message_start
+	ContentBlock *messagesStreamBlock `json:"content_block,omitempty"` // content_block_start
+	Delta        messagesStreamDelta  `json:"delta"`                   // content_block_delta, message_delta
+	Usage        *messagesUsage       `json:"usage,omitempty"`         // message_delta
+}
+
+type messagesStreamBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Thinking string `json:"thinking"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+}
+
+type messagesStreamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`         // text_delta
+	Thinking    string `json:"thinking"`     // thinking_delta
+	PartialJSON string `json:"partial_json"` // input_json_delta
+	StopReason  string `json:"stop_reason"`  // message_delta
+}
+
+// --- SendMessageStream on MessagesClient ---
+
+// SendMessageStream implements StreamProvider for the Anthropic Messages API.
+func (c *MessagesClient) SendMessageStream(ctx context.Context, req *Request) (*Stream, error) {
+	type streamReq struct {
+		messagesRequest
+		Stream bool `json:"stream"`
+	}
+	sr := streamReq{messagesRequest: c.buildWireRequest(req), Stream: true}
+
+	body, err := openSSEStream(ctx, c.httpClient, c.config, "/messages", sr)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan StreamEvent, 32)
+	stream := &Stream{ch: ch}
+
+	go readMessagesSSEStream(body, ch)
+
+	return stream, nil
+}
+
+// readMessagesSSEStream parses Messages API events (message_start,
+// content_block_*, message_delta, message_stop) into StreamEvents.
+// Closes the body and channel when done.
+func readMessagesSSEStream(body io.ReadCloser, ch chan<- StreamEvent) {
+	defer body.Close()
+	defer close(ch)
+
+	var (
+		resp         Response
+		contentBuf   strings.Builder
+		reasoningBuf strings.Builder
+		toolCalls    []*toolCallAccum
+		blockTool    = map[int]*toolCallAccum{} // content block index -> tool call
+		textBlocks   int
+		streamErr    error
+		stopped      bool // set when message_stop is received
+	)
+
+	emitContent := func(text string) {
+		if text == "" {
+			return
+		}
+		contentBuf.WriteString(text)
+		ch <- StreamEvent{ContentDelta: text}
+	}
+	emitReasoning := func(text string) {
+		if text == "" {
+			return
+		}
+		reasoningBuf.WriteString(text)
+		ch <- StreamEvent{ReasoningDelta: text}
+	}
+
+	err := scanSSEData(body, func(data string) bool {
+		var evt messagesStreamEvent
+		if err := json.Unmarshal([]byte(data), &evt); err != nil {
+			return true // skip malformed events
+		}
+
+		switch evt.Type {
+		case "message_start":
+			if evt.Message != nil {
+				resp.ID = evt.Message.ID
+				resp.Model = evt.Message.Model
+				resp.Usage = Usage{
+					InputTokens:  evt.Message.Usage.InputTokens,
+					OutputTokens: evt.Message.Usage.OutputTokens,
+				}
+			}
+
+		case "content_block_start":
+			if evt.ContentBlock == nil {
+				return true
+			}
+			switch evt.ContentBlock.Type {
+			case "text":
+				// Match SendMessage, which joins text blocks with a newline.
+				if textBlocks > 0 {
+					emitContent("\n")
+				}
+				textBlocks++
+				emitContent(evt.ContentBlock.Text)
+			case "thinking":
+				emitReasoning(evt.ContentBlock.Thinking)
+			case "tool_use":
+				tc := &toolCallAccum{id: evt.ContentBlock.ID, name: evt.ContentBlock.Name}
+				toolCalls = append(toolCalls, tc)
+				blockTool[evt.Index] = tc
+			}
+
+		case "content_block_delta":
+			switch evt.Delta.Type {
+			case "text_delta":
+				emitContent(evt.Delta.Text)
+			case "thinking_delta":
+				emitReasoning(evt.Delta.Thinking)
+			case "input_json_delta":
+				if tc := blockTool[evt.Index]; tc != nil {
+					tc.args.WriteString(evt.Delta.PartialJSON)
+				}
+			}
+
+		case "message_delta":
+			if evt.Delta.StopReason != "" {
+				resp.StopReason = StopReason(evt.Delta.StopReason)
+			}
+			if evt.Usage != nil {
+				resp.Usage.OutputTokens = evt.Usage.OutputTokens
+				if evt.Usage.InputTokens > 0 {
+					resp.Usage.InputTokens = evt.Usage.InputTokens
+				}
+			}
+
+		case "message_stop":
+			stopped = true
+			return false
+
+		case "error":
+			streamErr = parseAPIError(http.StatusOK, []byte(data))
+			return false
+		}
+		return true
+	})
+
+	switch {
+	case streamErr != nil:
+	case err != nil:
+		streamErr = fmt.Errorf("llm: read stream: %w", err)
+	case !stopped:
+		streamErr = fmt.Errorf("llm: stream ended before message_stop")
+	}
+	if streamErr != nil {
+		ch <- StreamEvent{err: streamErr}
+		return
+	}
+
+	resp.Content = contentBuf.String()
+	resp.Reasoning = reasoningBuf.String()
+	resp.ToolCalls = assembleToolCalls(toolCalls)
+
+	ch <- StreamEvent{Response: &resp}
+}
+
+// --- SSE streaming wire types for the Responses API ---
+
+type responsesStreamEvent struct {
+	Type        string               `json:"type"`
+	OutputIndex int                  `json:"output_index"`
+	Delta       string               `json:"delta"`              // *.delta events
+	Item        *responsesStreamItem `json:"item,omitempty"`     // response.output_item.*
+	Response    *responsesResponse   `json:"response,omitempty"` // response.created/completed/...
+	Code        string               `json:"code"`               // error
+	Message     string               `json:"message"`            // error
+}
+
+type responsesStreamItem struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// --- SendMessageStream on ResponsesClient ---
+
+// SendMessageStream implements StreamProvider for the Responses API.
+func (c *ResponsesClient) SendMessageStream(ctx context.Context, req *Request) (*Stream, error) {
+	type streamReq struct {
+		responsesRequest
+		Stream bool `json:"stream"`
+	}
+	sr := streamReq{responsesRequest: c.buildWireRequest(req), Stream: true}
+
+	body, err := openSSEStream(ctx, c.httpClient, c.config, "/responses", sr)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan StreamEvent, 32)
+	stream := &Stream{ch: ch}
+
+	go readResponsesSSEStream(body, ch)
+
+	return stream, nil
+}
+
+// readResponsesSSEStream parses Responses API events (response.output_text.delta,
+// reasoning deltas, function call items, response.completed) into StreamEvents.
+// Closes the body and channel when done.
+func readResponsesSSEStream(body io.ReadCloser, ch chan<- StreamEvent) {
+	defer body.Close()
+	defer close(ch)
+
+	var (
+		contentBuf   strings.Builder
+		reasoningBuf strings.Builder
+		toolCalls    []*toolCallAccum
+		itemTool     = map[int]*toolCallAccum{} // output index -> tool call
+		final        *responsesResponse
+		streamErr    error
+	)
+
+	err := scanSSEData(body, func(data string) bool {
+		var evt responsesStreamEvent
+		if err := json.Unmarshal([]byte(data), &evt); err != nil {
+			return true // skip malformed events
+		}
+
+		switch evt.Type {
+		case "response.output_text.delta":
+			if evt.Delta != "" {
+				contentBuf.WriteString(evt.Delta)
+				ch <- StreamEvent{ContentDelta: evt.Delta}
+			}
+
+		case "response.reasoning_text.delta", "response.reasoning_summary_text.delta":
+			if evt.Delta != "" {
+				reasoningBuf.WriteString(evt.Delta)
+				ch <- StreamEvent{ReasoningDelta: evt.Delta}
+			}
+
+		case "response.output_item.added":
+			if evt.Item != nil && evt.Item.Type == "function_call" {
+				tc := &toolCallAccum{id: evt.Item.CallID, name: evt.Item.Name}
+				tc.args.WriteString(evt.Item.Arguments)
+				toolCalls = append(toolCalls, tc)
+				itemTool[evt.OutputIndex] = tc
+			}
+
+		case "response.function_call_arguments.delta":
+			if tc := itemTool[evt.OutputIndex]; tc != nil {
+				tc.args.WriteString(evt.Delta)
+			}
+
+		case "response.output_item.done":
+			// The finished item carries the complete arguments; prefer them
+			// over the accumulated deltas.
+			if tc := itemTool[evt.OutputIndex]; tc != nil && evt.Item != nil && evt.Item.Arguments != "" {
+				tc.args.Reset()
+				tc.args.WriteString(evt.Item.Arguments)
+			}
+
+		case "response.completed", "response.incomplete":
+			final = evt.Response
+			return false
+
+		case "response.failed":
+			streamErr = &APIError{StatusCode: http.StatusOK, Message: "response failed"}
+			if evt.Response != nil && evt.Response.Error != nil {
+				streamErr = &APIError{
+					StatusCode: http.StatusOK,
+					Type:       evt.Response.Error.Code,
+					Message:    evt.Response.Error.Message,
+				}
+			}
+			return false
+
+		case "error":
+			streamErr = &APIError{StatusCode: http.StatusOK, Type: evt.Code, Message: evt.Message, RawBody: data}
+			return false
+		}
+		return true
+	})
+
+	switch {
+	case streamErr != nil:
+	case err != nil:
+		streamErr = fmt.Errorf("llm: read stream: %w", err)
+	case final == nil:
+		streamErr = fmt.Errorf("llm: stream ended before response.completed")
+	}
+	if streamErr != nil {
+		ch <- StreamEvent{err: streamErr}
+		return
+	}
+
+	resp := &Response{
+		ID:        final.ID,
+		Model:     final.Model,
+		Content:   contentBuf.String(),
+		Reasoning: reasoningBuf.String(),
+		ToolCalls: assembleToolCalls(toolCalls),
+	}
+	resp.StopReason = responsesStatusToStopReason(final.Status, len(resp.ToolCalls) > 0)
+	if final.Usage != nil {
+		resp.Usage = Usage{
+			InputTokens:  final.Usage.InputTokens,
+			OutputTokens: final.Usage.OutputTokens,
+		}
+	}
+
+	ch <- StreamEvent{Response: resp}
+}
+
+// assembleToolCalls parses accumulated tool call fragments into ToolCalls.
+func assembleToolCalls(accums []*toolCallAccum) []ToolCall {
+	var calls []ToolCall
+	for _, tc := range accums {
+		parsed := ToolCall{
+			ID:   tc.id,
+			Name: tc.name,
+		}
+		if args := tc.args.String(); args != "" {
+			_ = json.Unmarshal([]byte(args), &parsed.Input)
+		}
+		calls = append(calls, parsed)
+	}
+	return calls
+}
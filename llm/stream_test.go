@@ -0,0 +1,278 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamProviders_Implemented(t *testing.T) {
+	var _ StreamProvider = (*MessagesClient)(nil)
+	var _ StreamProvider = (*CompletionsClient)(nil)
+	var _ StreamProvider = (*ResponsesClient)(nil)
+}
+
+// sseFixtureServer serves a recorded SSE fixture from testdata at path and
+// fails the test unless the request asks for streaming.
+func sseFixtureServer(t *testing.T, path, fixture string) *httptest.Server {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("path = %s, want %s", r.URL.Path, path)
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		json.Unmarshal(body, &req)
+		if !req.Stream {
+			t.Errorf("request missing stream: true: %s", body)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(data)
+	}))
+}
+
+// drainStream collects all deltas and the final response from a stream.
+func drainStream(s *Stream) (content, reasoning []string, resp *Response) {
+	for {
+		evt, ok := s.Next()
+		if !ok {
+			return content, reasoning, resp
+		}
+		if evt.ContentDelta != "" {
+			content = append(content, evt.ContentDelta)
+		}
+		if evt.ReasoningDelta != "" {
+			reasoning = append(reasoning, evt.ReasoningDelta)
+		}
+		if evt.Response != nil {
+			resp = evt.Response
+		}
+	}
+}
+
+func TestMessagesStream_ToolUseWithThinking(t *testing.T) {
+	srv := sseFixtureServer(t, "/messages", "messages_stream_tool_use.sse")
+	defer srv.Close()
+
+	client, _ := NewMessagesClient(Config{APIKey: "test-key", BaseURL: srv.URL})
+	stream, err := client.SendMessageStream(context.Background(), &Request{
+		Model:     "anthropic/claude-sonnet-4",
+		MaxTokens: 1024,
+		Messages:  []Message{{Role: RoleUser, Content: "Weather in SF?"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, reasoning, resp := drainStream(stream)
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if got := strings.Join(content, "|"); got != "Let me check |the weather." {
+		t.Errorf("content deltas = %q", got)
+	}
+	if got := strings.Join(reasoning, ""); got != "The user wants the weather, so I should call the tool." {
+		t.Errorf("reasoning deltas = %q", got)
+	}
+	if resp == nil {
+		t.Fatal("no final response")
+	}
+	if resp.ID != "msg_01XFDUDYJgAACzvnptvVoYEL" || resp.Model != "anthropic/claude-sonnet-4" {
+		t.Errorf("ID/Model = %q/%q", resp.ID, resp.Model)
+	}
+	if resp.Content != "Let me check the weather." {
+		t.Errorf("Content = %q", resp.Content)
+	}
+	if resp.Reasoning != "The user wants the weather, so I should call the tool." {
+		t.Errorf("Reasoning = %q", resp.Reasoning)
+	}
+	if resp.StopReason != StopReasonToolUse {
+		t.Errorf("StopReason = %q", resp.StopReason)
+	}
+	if resp.Usage.InputTokens != 472 || resp.Usage.OutputTokens != 89 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("ToolCalls len = %d", len(resp.ToolCalls))
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID != "toolu_01T1x1fJ34qAmk2tNTrN7Up6" || tc.Name != "get_weather" {
+		t.Errorf("ToolCall = %+v", tc)
+	}
+	if tc.Input["location"] != "San Francisco, CA" || tc.Input["unit"] != "celsius" {
+		t.Errorf("ToolCall.Input = %v", tc.Input)
+	}
+}
+
+func TestMessagesStream_ErrorEvent(t *testing.T) {
+	srv := sseFixtureServer(t, "/messages", "messages_stream_error.sse")
+	defer srv.Close()
+
+	client, _ := NewMessagesClient(Config{APIKey: "test-key", BaseURL: srv.URL})
+	stream, err := client.SendMessageStream(context.Background(), &Request{Model: "m", MaxTokens: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _, resp := drainStream(stream)
+	if resp != nil {
+		t.Errorf("unexpected final response: %+v", resp)
+	}
+	if len(content) != 1 || content[0] != "Hel" {
+		t.Errorf("content deltas before error = %q", content)
+	}
+	var apiErr *APIError
+	if !errors.As(stream.Err(), &apiErr) {
+		t.Fatalf("Err = %v, want *APIError", stream.Err())
+	}
+	if apiErr.Type != "overloaded_error" || apiErr.Message != "Overloaded" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestMessagesStream_Truncated(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n")
+	}))
+	defer srv.Close()
+
+	client, _ := NewMessagesClient(Config{APIKey: "test-key", BaseURL: srv.URL})
+	stream, err := client.SendMessageStream(context.Background(), &Request{Model: "m", MaxTokens: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, resp := drainStream(stream); resp != nil {
+		t.Errorf("unexpected final response: %+v", resp)
+	}
+	if stream.Err() == nil {
+		t.Fatal("expected error for stream without message_stop")
+	}
+}
+
+func TestMessagesStream_APIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"Slow down"}}`))
+	}))
+	defer srv.Close()
+
+	client, _ := NewMessagesClient(Config{APIKey: "test-key", BaseURL: srv.URL, Retry: RetryPolicy{MaxRetries: -1}})
+	_, err := client.SendMessageStream(context.Background(), &Request{Model: "m", MaxTokens: 10})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Type != "rate_limit_error" {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestResponsesStream_TextReasoningAndToolCall(t *testing.T) {
+	srv := sseFixtureServer(t, "/responses", "responses_stream_tool_call.sse")
+	defer srv.Close()
+
+	client, _ := NewResponsesClient(Config{APIKey: "test-key", BaseURL: srv.URL})
+	stream, err := client.SendMessageStream(context.Background(), &Request{
+		Model:    "openai/gpt-4o",
+		Messages: []Message{{Role: RoleUser, Content: "Weather in Paris?"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, reasoning, resp := drainStream(stream)
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if got := strings.Join(content, "|"); got != "Checking |now." {
+		t.Errorf("content deltas = %q", got)
+	}
+	if got := strings.Join(reasoning, ""); got != "Need the forecast first." {
+		t.Errorf("reasoning deltas = %q", got)
+	}
+	if resp == nil {
+		t.Fatal("no final response")
+	}
+	if resp.ID != "resp_67c9fdcecf488190bdd9a0409de3a1ec" || resp.Model != "openai/gpt-4o" {
+		t.Errorf("ID/Model = %q/%q", resp.ID, resp.Model)
+	}
+	if resp.Content != "Checking now." || resp.Reasoning != "Need the forecast first." {
+		t.Errorf("Content/Reasoning = %q/%q", resp.Content, resp.Reasoning)
+	}
+	if resp.StopReason != StopReasonToolUse {
+		t.Errorf("StopReason = %q", resp.StopReason)
+	}
+	if resp.Usage.InputTokens != 291 || resp.Usage.OutputTokens != 23 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+	if len(resp.ToolCalls) != 1 {
+		t.Fatalf("ToolCalls len = %d", len(resp.ToolCalls))
+	}
+	tc := resp.ToolCalls[0]
+	if tc.ID != "call_WeAz1gBbRVPx4BHuKhy1pYxq" || tc.Name != "get_weather" || tc.Input["location"] != "Paris" {
+		t.Errorf("ToolCall = %+v", tc)
+	}
+}
+
+func TestResponsesStream_Failed(t *testing.T) {
+	srv := sseFixtureServer(t, "/responses", "responses_stream_failed.sse")
+	defer srv.Close()
+
+	client, _ := NewResponsesClient(Config{APIKey: "test-key", BaseURL: srv.URL})
+	stream, err := client.SendMessageStream(context.Background(), &Request{Model: "openai/gpt-4o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, resp := drainStream(stream); resp != nil {
+		t.Errorf("unexpected final response: %+v", resp)
+	}
+	var apiErr *APIError
+	if !errors.As(stream.Err(), &apiErr) {
+		t.Fatalf("Err = %v, want *APIError", stream.Err())
+	}
+	if apiErr.Type != "server_error" || apiErr.Message != "The model failed to generate a response." {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+}
+
+func TestCompletionsStream_Text(t *testing.T) {
+	srv := sseFixtureServer(t, "/chat/completions", "completions_stream_text.sse")
+	defer srv.Close()
+
+	client, _ := NewCompletionsClient(Config{APIKey: "test-key", BaseURL: srv.URL})
+	stream, err := client.SendMessageStream(context.Background(), &Request{Model: "openai/gpt-4o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _, resp := drainStream(stream)
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if got := strings.Join(content, "|"); got != "Hello| there" {
+		t.Errorf("content deltas = %q", got)
+	}
+	if resp == nil || resp.Content != "Hello there" || resp.StopReason != StopReasonEndTurn {
+		t.Fatalf("final response = %+v", resp)
+	}
+	if resp.Usage.InputTokens != 9 || resp.Usage.OutputTokens != 2 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultToolOutputBudget is the tool output size kept in history when
+// Config.ToolOutputBudget is 0.
+const defaultToolOutputBudget = 32 * 1024
+
+// applyOutputBudget shortens an oversized tool result to its head and tail.
+// The full output is written to a temp file the model can page through with
+// read_file; read_file output itself is only shortened, since the model can
+// already ask for a smaller range.
+// ProgressiveDisclosure
+func (a *Agent) applyOutputBudget(name string, res ToolResult) ToolResult {
+	budget := a.config.ToolOutputBudget
+	if budget == 0 {
+		budget = defaultToolOutputBudget
+	}
+	if budget < 0 || len(res.Output) <= budget {
+		return res
+	}
+
+	head, tail := headAndTail(res.Output, budget)
+	total := lineCount(res.Output)
+	headLines, tailLines := strings.Count(head, "\n"), lineCount(tail)
+	shown := fmt.Sprintf("showing the first %d and last %d of %d lines", headLines, tailLines, total)
+
+	// A cut inside a line, as with one huge line, leaves no whole lines to
+	// page through, so the omitted part is given as a byte range instead.
+	omitted := len(res.Output) - len(head) - len(tail)
+	byLine := (head == "" || strings.HasSuffix(head, "\n")) &&
+		strings.HasSuffix(res.Output[:len(head)+omitted], "\n") && headLines < total-tailLines
+	if !byLine {
+		shown = fmt.Sprintf("showing the first %d and last %d of %d bytes", len(head), len(tail), len(res.Output))
+	}
+
+	var notice string
+	if name == "read_file" {
+		notice = fmt.Sprintf("[Truncated: %s. Use line_start/line_end to read a smaller range.]", shown)
+	} else if path, err := a.spillOutput(name, res.Output); err != nil {
+		notice = fmt.Sprintf("[Truncated: %s; the full output could not be saved: %v]", shown, err)
+	} else if byLine {
+		notice = fmt.Sprintf("[Truncated: %s. Full output saved to %s; use read_file with line_start=%d and line_end=%d to read the omitted lines.]",
+			shown, path, headLines+1, total-tailLines)
+	} else {
+		notice = fmt.Sprintf("[Truncated: %s. Full output saved to %s; the omitted %d bytes start at byte %d, inside a long line, so slice them with run_shell (tail -c +%d %s | head -c 4096) or python_runtime.]",
+			shown, path, omitted, len(head)+1, len(head)+1, path)
+	}
+	res.Output = head + "\n\n" + notice + "\n\n" + tail
+	return res
+}
+
+// spillOutput saves a tool's full output to a temp file removed by Close.
+func (a *Agent) spillOutput(name, output string) (string, error) {
+	f, err := os.CreateTemp("", "bono-"+name+"-*.txt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(output)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	a.spillMu.Lock()
+	a.spills = append(a.spills, f.Name())
+	a.spillMu.Unlock()
+	return f.Name(), nil
+}
+
+// removeSpills deletes the temp files written by spillOutput.
+func (a *Agent) removeSpills() {
+	a.spillMu.Lock()
+	defer a.spillMu.Unlock()
+	for _, path := range a.spills {
+		os.Remove(path)
+	}
+	a.spills = nil
+}
+
+// headAndTail returns about budget/2 bytes from each end of s, cut at line
+// boundaries when a line fits and never inside a UTF-8 sequence.
+func headAndTail(s string, budget int) (head, tail string) {
+	half := budget / 2
+	cut := half
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	head = s[:cut]
+	if i := strings.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+
+	cut = len(s) - half
+	for cut < len(s) && !utf8.RuneStart(s[cut]) {
+		cut++
+	}
+	tail = s[cut:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return head, tail
+}
+
+// lineCount counts lines, including a final line without a newline.
+func lineCount(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+	return n
+}
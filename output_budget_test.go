@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestApplyOutputBudgetSpillsFullOutput(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 1000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	full := strings.Join(lines, "\n") + "\n"
+
+	a := &Agent{config: Config{ToolOutputBudget: 200}}
+	res := a.applyOutputBudget("run_shell", ToolResult{Success: true, Output: full})
+
+	if !strings.HasPrefix(res.Output, "line 1\n") || !strings.HasSuffix(res.Output, "line 1000\n") {
+		t.Fatalf("head/tail not kept:\n%s", res.Output)
+	}
+	m := regexp.MustCompile(`first (\d+) and last (\d+) of 1000 lines\. Full output saved to (\S+); use read_file with line_start=(\d+) and line_end=(\d+)`).FindStringSubmatch(res.Output)
+	if m == nil {
+		t.Fatalf("missing truncation notice:\n%s", res.Output)
+	}
+	if m[4] != fmt.Sprint(atoi(m[1])+1) || m[5] != fmt.Sprint(1000-atoi(m[2])) {
+		t.Fatalf("omitted range %s-%s doesn't match head %s / tail %s", m[4], m[5], m[1], m[2])
+	}
+	if !strings.HasSuffix(strings.Split(res.Output, "\n\n[")[0], fmt.Sprintf("line %s\n", m[1])) {
+		t.Fatalf("head doesn't end at line %s", m[1])
+	}
+
+	path := m[3]
+	if got := readTestFile(t, path); got != full {
+		t.Fatal("spilled file doesn't hold the full output")
+	}
+	read := ExecuteReadFile(path, atoi(m[4]), atoi(m[4]), 0, false)
+	if read.Output != fmt.Sprintf("line %s", m[4]) {
+		t.Fatalf("read_file of first omitted line = %q", read.Output)
+	}
+
+	a.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("spilled file not removed by Close")
+	}
+}
+
+func TestApplyOutputBudgetSingleLongLine(t *testing.T) {
+	full := strings.Repeat("0123456789", 100)
+
+	a := &Agent{config: Config{ToolOutputBudget: 200}}
+	defer a.Close()
+	res := a.applyOutputBudget("run_shell", ToolResult{Success: true, Output: full})
+
+	if strings.Contains(res.Output, "line_start") {
+		t.Fatalf("notice points at a line range for a single line:\n%s", res.Output)
+	}
+	m := regexp.MustCompile(`first (\d+) and last (\d+) of 1000 bytes\. Full output saved to (\S+); the omitted (\d+) bytes start at byte (\d+)`).FindStringSubmatch(res.Output)
+	if m == nil {
+		t.Fatalf("missing byte-range notice:\n%s", res.Output)
+	}
+	head, tail, omitted, start := atoi(m[1]), atoi(m[2]), atoi(m[4]), atoi(m[5])
+	if head+tail+omitted != len(full) || start != head+1 {
+		t.Fatalf("byte range doesn't cover the omitted middle: head %d, tail %d, omitted %d from %d", head, tail, omitted, start)
+	}
+	if got := readTestFile(t, m[3]); got != full {
+		t.Fatal("spilled file doesn't hold the full output")
+	}
+}
+
+func TestApplyOutputBudgetLimits(t *testing.T) {
+	long := ToolResult{Output: strings.Repeat("é", 500)}
+
+	a := &Agent{config: Config{ToolOutputBudget: -1}}
+	if res := a.applyOutputBudget("run_shell", long); res.Output != long.Output {
+		t.Fatal("negative budget should disable truncation")
+	}
+
+	a = &Agent{config: Config{ToolOutputBudget: 101}}
+	res := a.applyOutputBudget("read_file", long)
+	if strings.Contains(res.Output, "saved to") || !strings.Contains(res.Output, "Use line_start/line_end") {
+		t.Fatalf("read_file output should be truncated without spilling:\n%s", res.Output)
+	}
+	if !strings.HasPrefix(res.Output, "é") || !strings.HasSuffix(res.Output, "é") || strings.ContainsRune(res.Output, '�') {
+		t.Fatal("truncation split a UTF-8 sequence")
+	}
+	if len(a.spills) != 0 {
+		t.Fatal("read_file output was spilled")
+	}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
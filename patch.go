@@ -0,0 +1,574 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type patchOp int
+
+const (
+	patchUpdate patchOp = iota
+	patchAdd
+	patchDelete
+)
+
+// filePatch is one file's change, parsed from a unified diff or a
+// "*** Begin Patch" block.
+type filePatch struct {
+	op      patchOp
+	path    string // file to modify, create or delete
+	newPath string // rename target; empty keeps path
+	hunks   []patchHunk
+}
+
+// patchHunk is one contiguous change. lines carry no line terminators.
+type patchHunk struct {
+	header   string // "@@" line as written, for error reports
+	oldStart int    // 1-based line hint from a unified header; 0 when absent
+	anchor   string // "*** Begin Patch" @@ text: a line to seek past before matching
+	lines    []diffOp
+	oldNoEOL bool // "\ No newline at end of file" after an old-side line
+	newNoEOL bool // "\ No newline at end of file" after a new-side line
+	atEOF    bool // hunk must match at the end of the file
+}
+
+// sides returns the lines the hunk expects and the lines it produces.
+func (h patchHunk) sides() (old, new []string) {
+	for _, op := range h.lines {
+		if op.kind != '+' {
+			old = append(old, op.line)
+		}
+		if op.kind != '-' {
+			new = append(new, op.line)
+		}
+	}
+	return old, new
+}
+
+// label names the hunk in error reports.
+func (h patchHunk) label(i, n int) string {
+	s := fmt.Sprintf("hunk %d/%d", i+1, n)
+	if h.header != "" {
+		s += " (" + h.header + ")"
+	}
+	return s
+}
+
+// parsePatch accepts a unified diff (plain or git-style) or a "*** Begin Patch" block.
+func parsePatch(text string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		if strings.TrimSpace(l) == "*** Begin Patch" {
+			return parseStructuredPatch(lines[i+1:])
+		}
+		break
+	}
+	return parseUnifiedDiff(lines)
+}
+
+func parseUnifiedDiff(lines []string) ([]filePatch, error) {
+	var patches []filePatch
+	var cur *filePatch
+	flush := func() {
+		if cur != nil {
+			patches = append(patches, *cur)
+			cur = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			cur = &filePatch{}
+			if a, b, ok := splitGitDiffNames(line[len("diff --git "):]); ok {
+				cur.path = a
+				if b != a {
+					cur.newPath = b
+				}
+			}
+		case cur != nil && strings.HasPrefix(line, "rename from "):
+			cur.path = strings.TrimPrefix(line, "rename from ")
+		case cur != nil && strings.HasPrefix(line, "rename to "):
+			cur.newPath = strings.TrimPrefix(line, "rename to ")
+		case cur != nil && strings.HasPrefix(line, "new file mode"):
+			cur.op = patchAdd
+		case cur != nil && strings.HasPrefix(line, "deleted file mode"):
+			cur.op = patchDelete
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// A header pair without "diff --git", or a second pair, starts a new file.
+			if cur == nil || len(cur.hunks) > 0 {
+				flush()
+				cur = &filePatch{}
+			}
+			oldName := patchFileName(line[4:], "a/")
+			newName := patchFileName(lines[i+1][4:], "b/")
+			switch {
+			case oldName == "/dev/null":
+				cur.op, cur.path, cur.newPath = patchAdd, newName, ""
+			case newName == "/dev/null":
+				cur.op, cur.path, cur.newPath = patchDelete, oldName, ""
+			default:
+				cur.path, cur.newPath = oldName, ""
+				if newName != oldName {
+					cur.newPath = newName
+				}
+			}
+			i += 2
+			continue
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk before any file header", i+1)
+			}
+			h := patchHunk{header: strings.TrimSpace(line)}
+			h.oldStart = parseHunkOldStart(line)
+			var n int
+			n, h = readHunkLines(lines[i+1:], h, false)
+			cur.hunks = append(cur.hunks, h)
+			i += 1 + n
+			continue
+		}
+		i++
+	}
+	flush()
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	for _, p := range patches {
+		if p.path == "" {
+			return nil, fmt.Errorf("patch is missing a file name")
+		}
+		if p.op == patchUpdate && len(p.hunks) == 0 && p.newPath == "" {
+			return nil, fmt.Errorf("%s: no hunks", p.path)
+		}
+	}
+	return patches, nil
+}
+
+// readHunkLines consumes hunk body lines until the next header and returns
+// how many input lines were used. Blank lines are context lines whose leading
+// space was stripped; trailing blank lines are dropped.
+func readHunkLines(lines []string, h patchHunk, structured bool) (int, patchHunk) {
+	n := 0
+	blanks := 0
+	for n < len(lines) {
+		line := lines[n]
+		if isHunkBoundary(lines, n, structured) {
+			break
+		}
+		if line == "" {
+			blanks++
+			h.lines = append(h.lines, diffOp{' ', ""})
+			n++
+			continue
+		}
+		switch line[0] {
+		case ' ', '-', '+':
+			blanks = 0
+			h.lines = append(h.lines, diffOp{line[0], line[1:]})
+		case '\\':
+			if len(h.lines) > 0 {
+				if h.lines[len(h.lines)-1].kind == '-' {
+					h.oldNoEOL = true
+				} else {
+					h.newNoEOL = true
+					if h.lines[len(h.lines)-1].kind == ' ' {
+						h.oldNoEOL = true
+					}
+				}
+			}
+		default:
+			if structured && strings.TrimSpace(line) == "*** End of File" {
+				h.atEOF = true
+				n++
+				return n, trimBlankContext(h, blanks)
+			}
+			return n, trimBlankContext(h, blanks)
+		}
+		n++
+	}
+	return n, trimBlankContext(h, blanks)
+}
+
+func trimBlankContext(h patchHunk, blanks int) patchHunk {
+	h.lines = h.lines[:len(h.lines)-blanks]
+	return h
+}
+
+func isHunkBoundary(lines []string, n int, structured bool) bool {
+	line := lines[n]
+	if strings.HasPrefix(line, "@@") {
+		return true
+	}
+	if structured {
+		return strings.HasPrefix(line, "*** ") && strings.TrimSpace(line) != "*** End of File"
+	}
+	if strings.HasPrefix(line, "diff --git ") {
+		return true
+	}
+	return strings.HasPrefix(line, "--- ") && n+1 < len(lines) && strings.HasPrefix(lines[n+1], "+++ ")
+}
+
+// parseHunkOldStart reads the old-file start line from "@@ -l,s +l,s @@".
+func parseHunkOldStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "-") {
+		return 0
+	}
+	start, _, _ := strings.Cut(fields[1][1:], ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// patchFileName strips timestamps and the git a/ or b/ prefix from a ---/+++ name.
+func patchFileName(s, prefix string) string {
+	if name, _, ok := strings.Cut(s, "\t"); ok {
+		s = name
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return s
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// splitGitDiffNames splits "a/x b/y" from a "diff --git" line.
+func splitGitDiffNames(s string) (a, b string, ok bool) {
+	i := strings.LastIndex(s, " b/")
+	if i < 0 || !strings.HasPrefix(s, "a/") {
+		return "", "", false
+	}
+	return s[2:i], s[i+3:], true
+}
+
+func parseStructuredPatch(lines []string) ([]filePatch, error) {
+	var patches []filePatch
+	ended := false
+	for i := 0; i < len(lines) && !ended; {
+		line := strings.TrimRight(lines[i], " \t")
+		switch {
+		case line == "":
+			i++
+		case line == "*** End Patch":
+			ended = true
+		case strings.HasPrefix(line, "*** Add File: "):
+			p := filePatch{op: patchAdd, path: strings.TrimSpace(strings.TrimPrefix(line, "*** Add File: "))}
+			n, h := readHunkLines(lines[i+1:], patchHunk{}, true)
+			for j, op := range h.lines {
+				if op == (diffOp{' ', ""}) {
+					h.lines[j].kind = '+' // blank line written without its '+'
+					continue
+				}
+				if op.kind != '+' {
+					return nil, fmt.Errorf("add %s: every line must start with '+'", p.path)
+				}
+			}
+			p.hunks = []patchHunk{h}
+			patches = append(patches, p)
+			i += 1 + n
+		case strings.HasPrefix(line, "*** Delete File: "):
+			patches = append(patches, filePatch{op: patchDelete, path: strings.TrimSpace(strings.TrimPrefix(line, "*** Delete File: "))})
+			i++
+		case strings.HasPrefix(line, "*** Update File: "):
+			p := filePatch{op: patchUpdate, path: strings.TrimSpace(strings.TrimPrefix(line, "*** Update File: "))}
+			i++
+			if i < len(lines) && strings.HasPrefix(lines[i], "*** Move to: ") {
+				p.newPath = strings.TrimSpace(strings.TrimPrefix(lines[i], "*** Move to: "))
+				i++
+			}
+			for i < len(lines) {
+				h := patchHunk{}
+				if strings.HasPrefix(lines[i], "@@") {
+					h.header = strings.TrimSpace(lines[i])
+					if strings.HasPrefix(h.header, "@@ -") {
+						h.oldStart = parseHunkOldStart(h.header)
+					} else {
+						h.anchor = strings.TrimSpace(strings.TrimPrefix(h.header, "@@"))
+					}
+					i++
+				} else if strings.HasPrefix(lines[i], "*** ") {
+					break
+				}
+				n, parsed := readHunkLines(lines[i:], h, true)
+				i += n
+				if len(parsed.lines) == 0 && parsed.anchor == "" {
+					if n == 0 {
+						break
+					}
+					continue
+				}
+				p.hunks = append(p.hunks, parsed)
+			}
+			if len(p.hunks) == 0 && p.newPath == "" {
+				return nil, fmt.Errorf("update %s: no hunks", p.path)
+			}
+			patches = append(patches, p)
+		default:
+			return nil, fmt.Errorf("unexpected line in patch: %q", line)
+		}
+	}
+	if !ended {
+		return nil, fmt.Errorf("patch is missing \"*** End Patch\"")
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+	return patches, nil
+}
+
+// lineMatchers compare a file line with a patch line, strictest first.
+var lineMatchers = []struct {
+	name  string
+	equal func(a, b string) bool
+}{
+	{"exact", func(a, b string) bool { return a == b }},
+	{"ignoring trailing whitespace", func(a, b string) bool {
+		return strings.TrimRight(a, " \t\r") == strings.TrimRight(b, " \t\r")
+	}},
+	{"ignoring whitespace", func(a, b string) bool { return strings.TrimSpace(a) == strings.TrimSpace(b) }},
+}
+
+// maxPatchFuzz is how many leading/trailing context lines a hunk may drop to match.
+const maxPatchFuzz = 2
+
+// hunkMatch is where a hunk applies: lines[pos:pos+len(old)] with head and
+// tail context lines of the hunk ignored.
+type hunkMatch struct {
+	pos, head, tail int
+	how             string
+}
+
+// locateHunk finds old in lines at or after from, nearest to hint. Exact
+// matches are tried first, then whitespace-insensitive ones, then matches
+// that drop up to maxPatchFuzz context lines at either end.
+func locateHunk(lines []string, h patchHunk, old []string, from, hint int) (hunkMatch, bool) {
+	for fuzz := 0; fuzz <= maxPatchFuzz; fuzz++ {
+		head := min(fuzz, leadingContext(h.lines))
+		tail := min(fuzz, trailingContext(h.lines))
+		if fuzz > 0 && head+tail < fuzz {
+			continue // nothing more to drop at this level
+		}
+		if head+tail >= len(old) && len(old) > 0 {
+			break
+		}
+		core := old[head : len(old)-tail]
+		for _, m := range lineMatchers {
+			if pos, ok := nearestMatch(lines, core, from, hint+head, h.atEOF && tail == 0, m.equal); ok {
+				how := m.name
+				if fuzz > 0 {
+					how += fmt.Sprintf(", fuzz %d", fuzz)
+				}
+				return hunkMatch{pos: pos, head: head, tail: tail, how: how}, true
+			}
+		}
+	}
+	return hunkMatch{}, false
+}
+
+func nearestMatch(lines, want []string, from, hint int, atEOF bool, equal func(a, b string) bool) (int, bool) {
+	last := len(lines) - len(want)
+	if atEOF {
+		if last >= from && linesEqual(lines[last:], want, equal) {
+			return last, true
+		}
+		return 0, false
+	}
+	hint = max(from, min(hint, last))
+	for d := 0; hint-d >= from || hint+d <= last; d++ {
+		if p := hint - d; p >= from && p <= last && linesEqual(lines[p:p+len(want)], want, equal) {
+			return p, true
+		}
+		if p := hint + d; d > 0 && p >= from && p <= last && linesEqual(lines[p:p+len(want)], want, equal) {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+func linesEqual(a, b []string, equal func(a, b string) bool) bool {
+	for i := range b {
+		if !equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func leadingContext(ops []diffOp) int {
+	n := 0
+	for n < len(ops) && ops[n].kind == ' ' {
+		n++
+	}
+	return n
+}
+
+func trailingContext(ops []diffOp) int {
+	n := 0
+	for n < len(ops) && ops[len(ops)-1-n].kind == ' ' {
+		n++
+	}
+	return n
+}
+
+// hunkMismatch explains why old was not found: the candidate position
+// sharing the longest whitespace-insensitive prefix with old, and the first
+// line that differs there.
+func hunkMismatch(lines, old []string, from int) string {
+	if len(old) == 0 {
+		return "nothing to match"
+	}
+	bestPos, bestLen := -1, -1
+	for p := from; p < len(lines); p++ {
+		n := 0
+		for n < len(old) && p+n < len(lines) && strings.TrimSpace(lines[p+n]) == strings.TrimSpace(old[n]) {
+			n++
+		}
+		if n > bestLen {
+			bestPos, bestLen = p, n
+		}
+	}
+	if bestLen <= 0 {
+		return fmt.Sprintf("context not found: no line matches %q", old[0])
+	}
+	at := bestPos + bestLen
+	got := "end of file"
+	if at < len(lines) {
+		got = strconv.Quote(lines[at])
+	}
+	return fmt.Sprintf("context not found: closest match starts at line %d, but line %d is %s where the patch expects %q",
+		bestPos+1, at+1, got, old[bestLen])
+}
+
+// fileLines is a text file split into lines with its line ending style.
+type fileLines struct {
+	lines   []string
+	eol     string
+	finalNL bool
+}
+
+func splitFileLines(content string) fileLines {
+	f := fileLines{eol: "\n"}
+	if strings.Contains(content, "\r\n") {
+		f.eol = "\r\n"
+	}
+	if content == "" {
+		return f
+	}
+	f.finalNL = strings.HasSuffix(content, "\n")
+	body := strings.TrimSuffix(content, "\n")
+	if f.eol == "\r\n" {
+		body = strings.TrimSuffix(body, "\r")
+	}
+	f.lines = strings.Split(body, f.eol)
+	return f
+}
+
+func (f fileLines) String() string {
+	if len(f.lines) == 0 {
+		return ""
+	}
+	s := strings.Join(f.lines, f.eol)
+	if f.finalNL {
+		s += f.eol
+	}
+	return s
+}
+
+// applyHunks applies hunks to content in order. notes describe hunks that
+// needed an offset or fuzzy matching.
+func applyHunks(content string, hunks []patchHunk) (string, []string, error) {
+	f := splitFileLines(content)
+	if content == "" {
+		f.finalNL = true
+	}
+	var notes []string
+	from, adjust := 0, 0
+	for i, h := range hunks {
+		old, new := h.sides()
+		hint := from
+		if h.oldStart > 0 {
+			hint = h.oldStart - 1 + adjust
+		}
+		insertAt := len(f.lines) // where a hunk without old lines goes
+		if h.anchor != "" {
+			anchored := false
+			for p := from; p < len(f.lines); p++ {
+				if strings.TrimSpace(f.lines[p]) == h.anchor {
+					// Not p+1: models often repeat the anchor as the hunk's first context line.
+					from, hint, insertAt, anchored = p, p, p+1, true
+					break
+				}
+			}
+			if !anchored {
+				notes = append(notes, fmt.Sprintf("%s: anchor line not found, matched by context only", h.label(i, len(hunks))))
+			}
+		} else if h.oldStart > 0 && !h.atEOF {
+			// A zero-length old range "-l,0" inserts after line l.
+			insertAt = hint + 1
+		}
+
+		if len(old) == 0 {
+			pos := max(from, min(insertAt, len(f.lines)))
+			if pos == len(f.lines) && h.newNoEOL {
+				f.finalNL = false
+			}
+			f.lines = append(f.lines[:pos], append(append([]string(nil), new...), f.lines[pos:]...)...)
+			from = pos + len(new)
+			continue
+		}
+
+		m, ok := locateHunk(f.lines, h, old, from, hint)
+		if !ok {
+			return "", nil, fmt.Errorf("%s: %s", h.label(i, len(hunks)), hunkMismatch(f.lines, old, from))
+		}
+		old = old[m.head : len(old)-m.tail]
+		reachesEOF := m.pos+len(old) == len(f.lines)
+
+		// Context lines keep the file's text, which may differ in whitespace.
+		new = new[:0:0]
+		k := m.pos
+		for _, op := range h.lines[m.head : len(h.lines)-m.tail] {
+			switch op.kind {
+			case ' ':
+				new = append(new, f.lines[k])
+				k++
+			case '-':
+				k++
+			case '+':
+				new = append(new, op.line)
+			}
+		}
+
+		f.lines = append(f.lines[:m.pos], append(new, f.lines[m.pos+len(old):]...)...)
+		from = m.pos + len(new)
+
+		if reachesEOF {
+			if h.newNoEOL {
+				f.finalNL = false
+			} else if h.oldNoEOL {
+				f.finalNL = true
+			}
+		}
+
+		start := m.pos - m.head
+		if h.oldStart > 0 && start != hint {
+			notes = append(notes, fmt.Sprintf("%s applied at line %d (offset %+d lines, %s)", h.label(i, len(hunks)), start+1, start-hint, m.how))
+		} else if m.how != "exact" {
+			notes = append(notes, fmt.Sprintf("%s matched %s", h.label(i, len(hunks)), m.how))
+		}
+		if h.oldStart > 0 {
+			adjust = start - (h.oldStart - 1) + len(new) - len(old)
+		}
+	}
+	return f.String(), notes, nil
+}
@@ -0,0 +1,253 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Permission is the outcome of a permission check.
+type Permission int
+
+const (
+	// PermissionAsk runs the call only if OnToolCall approves it.
+	PermissionAsk Permission = iota
+	// PermissionAllow runs the call without consulting OnToolCall.
+	PermissionAllow
+	// PermissionDeny skips the call; the reason is returned to the model.
+	PermissionDeny
+)
+
+func (p Permission) String() string {
+	switch p {
+	case PermissionAllow:
+		return "allow"
+	case PermissionDeny:
+		return "deny"
+	}
+	return "ask"
+}
+
+// PermissionDecision is a permission with the reason for it.
+type PermissionDecision struct {
+	Permission Permission
+	Reason     string
+}
+
+// PermissionRequest is what permission rules see of a tool call.
+type PermissionRequest struct {
+	Tool      string         // tool name, normalized to snake case
+	Args      map[string]any // parsed arguments
+	Paths     []string       // absolute, symlink-resolved paths the call reads or writes
+	Writes    bool           // the call modifies files
+	Safety    string         // safety class of run_shell and python_runtime calls
+	Commands  []ShellCommand // simple commands of a run_shell call
+	Shell     ShellDecision  // shell policy decision for run_shell and python_runtime calls
+	Sandboxed bool           // the call runs inside the sandbox
+}
+
+// PermissionRule matches a call and optionally returns a decision.
+type PermissionRule func(PermissionRequest) (PermissionDecision, bool)
+
+// PermissionEngine decides whether tool calls run, asking its rules in
+// order; the first match decides. Calls no rule matches are asked about.
+type PermissionEngine struct {
+	rules []PermissionRule
+}
+
+// NewPermissionEngine returns an engine with ordered rules.
+func NewPermissionEngine(rules ...PermissionRule) *PermissionEngine {
+	return &PermissionEngine{rules: rules}
+}
+
+// Decide returns the decision of the first matching rule, or ask.
+func (e *PermissionEngine) Decide(req PermissionRequest) PermissionDecision {
+	for _, rule := range e.rules {
+		if decision, ok := rule(req); ok {
+			return decision
+		}
+	}
+	return PermissionDecision{Permission: PermissionAsk, Reason: "no permission rule matched"}
+}
+
+// ToolPermissionRule returns decision for calls to any of tools.
+func ToolPermissionRule(decision PermissionDecision, tools ...string) PermissionRule {
+	names := make([]string, len(tools))
+	for i, t := range tools {
+		names[i] = normalizeToolName(t)
+	}
+	return func(req PermissionRequest) (PermissionDecision, bool) {
+		return decision, slices.Contains(names, req.Tool)
+	}
+}
+
+// SafetyPermissionRule returns decision for shell calls with any of the
+// safety classes.
+func SafetyPermissionRule(decision PermissionDecision, safety ...string) PermissionRule {
+	return func(req PermissionRequest) (PermissionDecision, bool) {
+		return decision, slices.ContainsFunc(safety, func(s string) bool {
+			return strings.EqualFold(s, strings.TrimSpace(req.Safety))
+		})
+	}
+}
+
+// PathPermissionRule returns decision for calls touching a path that
+// matches one of patterns. Patterns are filepath.Match globs, where a
+// trailing /** matches a directory and everything under it; ~/ and relative
+// patterns are resolved when the rule is built. writes limits the rule to
+// calls that modify files.
+func PathPermissionRule(decision PermissionDecision, writes bool, patterns ...string) PermissionRule {
+	resolved := make([]string, len(patterns))
+	for i, p := range patterns {
+		p = expandDirTemplate(p, "")
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		resolved[i] = p
+	}
+	return func(req PermissionRequest) (PermissionDecision, bool) {
+		if writes && !req.Writes {
+			return PermissionDecision{}, false
+		}
+		for _, path := range req.Paths {
+			for _, p := range resolved {
+				if matchPathPattern(p, path) {
+					return decision, true
+				}
+			}
+		}
+		return PermissionDecision{}, false
+	}
+}
+
+// CommandPermissionRule returns decision for run_shell calls where a simple
+// command matches one of patterns.
+func CommandPermissionRule(decision PermissionDecision, patterns ...CommandPattern) PermissionRule {
+	return func(req PermissionRequest) (PermissionDecision, bool) {
+		for _, cmd := range req.Commands {
+			for _, p := range patterns {
+				if p.Match(cmd) {
+					return decision, true
+				}
+			}
+		}
+		return PermissionDecision{}, false
+	}
+}
+
+// allowPermission is a ToolDef.Permission that always allows.
+func allowPermission(reason string) func(PermissionRequest) PermissionDecision {
+	return func(PermissionRequest) PermissionDecision {
+		return PermissionDecision{Permission: PermissionAllow, Reason: reason}
+	}
+}
+
+// askPermission is a ToolDef.Permission that always asks.
+func askPermission(reason string) func(PermissionRequest) PermissionDecision {
+	return func(PermissionRequest) PermissionDecision {
+		return PermissionDecision{Permission: PermissionAsk, Reason: reason}
+	}
+}
+
+// sandboxedPermission allows calls that run inside the sandbox and asks
+// about the rest.
+func sandboxedPermission(req PermissionRequest) PermissionDecision {
+	if req.Sandboxed {
+		return PermissionDecision{Permission: PermissionAllow, Reason: "runs inside the sandbox"}
+	}
+	return PermissionDecision{Permission: PermissionAsk, Reason: "runs outside the sandbox"}
+}
+
+// shellDenyPermissionRule denies shell calls the shell policy denies.
+func shellDenyPermissionRule(req PermissionRequest) (PermissionDecision, bool) {
+	if req.Shell.Route != ShellRouteDeny {
+		return PermissionDecision{}, false
+	}
+	return PermissionDecision{Permission: PermissionDeny, Reason: req.Shell.Reason}, true
+}
+
+// permissionRequest describes a call for permission rules.
+func (a *Agent) permissionRequest(name string, args map[string]any) PermissionRequest {
+	req := PermissionRequest{Tool: normalizeToolName(name), Args: args, Sandboxed: IsSandboxEnabled()}
+	tool, _ := a.registry.Get(name)
+
+	var paths []string
+	switch req.Tool {
+	case "run_shell", "python_runtime":
+		shellReq := ShellRequestFromToolArgs(req.Tool, args)
+		decision := DecideShellRequest(a.shellPolicy, shellReq)
+		req.Safety, req.Shell = shellReq.Safety, decision
+		req.Sandboxed = req.Sandboxed && decision.Route == ShellRouteSandboxFirst
+		if req.Tool == "python_runtime" && isPythonKernelCall(args) && a.python != nil && a.python.runsOnHost() {
+			req.Sandboxed = false // the live kernel may handle the call outside the sandbox
+		}
+		if req.Tool == "run_shell" {
+			req.Commands = shellReq.Commands()
+			for _, cmd := range req.Commands {
+				paths = append(paths, cmd.OutputFiles()...)
+			}
+			req.Writes = len(paths) > 0
+		}
+	case "read_file":
+		paths = pathArg(args)
+	}
+	if tool != nil && tool.WritesFiles != nil {
+		paths = tool.WritesFiles(args)
+		req.Writes = true
+	}
+
+	for _, p := range paths {
+		abs, err := filepath.Abs(expandDirTemplate(p, ""))
+		if err != nil {
+			continue
+		}
+		if real, err := resolvePath(abs); err == nil {
+			abs = real
+		}
+		req.Paths = append(req.Paths, abs)
+	}
+	return req
+}
+
+// CheckPermission decides whether a tool call may run, without running it.
+// A shell policy deny comes first, then Config.PermissionRules in order,
+// then the tool's own Permission. An ask is turned into an allow when the
+// approval store has a matching approval.
+func (a *Agent) CheckPermission(name string, args map[string]any) PermissionDecision {
+	return a.checkPermission(name, args, false)
+}
+
+// checkPermission is CheckPermission; consume uses up a matching once-scope
+// approval, as when the call is about to run.
+func (a *Agent) checkPermission(name string, args map[string]any, consume bool) PermissionDecision {
+	tool, ok := a.registry.Get(name)
+	if !ok {
+		return PermissionDecision{Permission: PermissionAsk, Reason: "unknown tool"}
+	}
+	req := a.permissionRequest(name, args)
+
+	rules := append([]PermissionRule{shellDenyPermissionRule}, a.config.PermissionRules...)
+	if tool.Permission != nil {
+		rules = append(rules, func(req PermissionRequest) (PermissionDecision, bool) {
+			return tool.Permission(req), true
+		})
+	}
+	decision := NewPermissionEngine(rules...).Decide(req)
+
+	if decision.Permission == PermissionAsk && a.approvals != nil && a.approvals.approved(name, args, consume) {
+		return PermissionDecision{Permission: PermissionAllow, Reason: "approved earlier"}
+	}
+	return decision
+}
+
+// permissionDeniedResult reports a call the permission engine denied.
+func permissionDeniedResult(decision PermissionDecision) ToolResult {
+	err := fmt.Errorf("%w: %s", ErrPermissionDenied, decision.Reason)
+	return ToolResult{
+		Success: false,
+		Output:  "Permission denied: " + decision.Reason + ". Do not retry this call in another form; ask the user if it is needed.",
+		Error:   err,
+		Status:  "denied",
+	}
+}
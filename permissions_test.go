@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPermissionEngineRules(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	dir := t.TempDir()
+	a := newToolCallTestAgent(1, ReadFileTool(), WriteFileTool(), RunShellTool(nil), WebFetchTool(nil))
+	a.shellPolicy = RuleBasedShellPolicy(CommandPatternRule(
+		ShellDecision{Route: ShellRouteDeny, Reason: "no deploys"},
+		CommandPattern{Argv: []string{"make", "deploy"}},
+	))
+	a.config.PermissionRules = []PermissionRule{
+		PathPermissionRule(PermissionDecision{Permission: PermissionDeny, Reason: "secrets are off limits"}, false, filepath.Join(dir, "secrets/**")),
+		PathPermissionRule(PermissionDecision{Permission: PermissionAllow, Reason: "scratch space"}, true, filepath.Join(dir, "tmp/**")),
+		SafetyPermissionRule(PermissionDecision{Permission: PermissionDeny, Reason: "no destructive commands"}, "destructive"),
+		CommandPermissionRule(PermissionDecision{Permission: PermissionAsk, Reason: "git changes"}, CommandPattern{Argv: []string{"git", "commit"}}),
+		ToolPermissionRule(PermissionDecision{Permission: PermissionAsk, Reason: "review fetches"}, "web_fetch"),
+	}
+
+	tests := []struct {
+		tool   string
+		args   map[string]any
+		want   Permission
+		reason string
+	}{
+		{"read_file", map[string]any{"path": filepath.Join(dir, "secrets", "key")}, PermissionDeny, "secrets are off limits"},
+		{"run_shell", map[string]any{"command": "cat x > " + filepath.Join(dir, "secrets", "copy")}, PermissionDeny, "secrets are off limits"},
+		{"read_file", map[string]any{"path": filepath.Join(dir, "README")}, PermissionAllow, "reads files"},
+		{"write_file", map[string]any{"path": filepath.Join(dir, "tmp", "a")}, PermissionAllow, "scratch space"},
+		{"read_file", map[string]any{"path": filepath.Join(dir, "tmp", "a")}, PermissionAllow, "reads files"},
+		{"write_file", map[string]any{"path": filepath.Join(dir, "main.go")}, PermissionAsk, "modifies files"},
+		{"run_shell", map[string]any{"command": "rm -rf build", "safety": "destructive"}, PermissionDeny, "no destructive commands"},
+		{"run_shell", map[string]any{"command": "git add . && git commit -m x", "safety": "modify"}, PermissionAsk, "git changes"},
+		{"run_shell", map[string]any{"command": "ls", "safety": "read-only"}, PermissionAsk, "runs outside the sandbox"},
+		{"run_shell", map[string]any{"command": "cd app && make deploy"}, PermissionDeny, "no deploys"},
+		{"WebFetch", map[string]any{"url": "https://example.com"}, PermissionAsk, "review fetches"},
+	}
+	for _, tt := range tests {
+		d := a.CheckPermission(tt.tool, tt.args)
+		if d.Permission != tt.want || d.Reason != tt.reason {
+			t.Errorf("%s %v = %v %q, want %v %q", tt.tool, tt.args, d.Permission, d.Reason, tt.want, tt.reason)
+		}
+	}
+}
+
+func TestRunToolCallsAppliesPermissions(t *testing.T) {
+	var ran []string
+	tool := func(name string, decision PermissionDecision) *ToolDef {
+		return &ToolDef{
+			Name:       name,
+			Permission: func(PermissionRequest) PermissionDecision { return decision },
+			Execute: func(args map[string]any) ToolResult {
+				ran = append(ran, name)
+				return ToolResult{Success: true, Output: "ran " + name}
+			},
+		}
+	}
+	a := newToolCallTestAgent(1,
+		tool("allowed", PermissionDecision{Permission: PermissionAllow}),
+		tool("denied", PermissionDecision{Permission: PermissionDeny, Reason: "not today"}),
+		tool("asked", PermissionDecision{Permission: PermissionAsk}),
+	)
+	var started, asked []string
+	var done []ToolResult
+	a.OnToolStart = func(name string, args map[string]any) {
+		started = append(started, name)
+	}
+	a.OnToolCall = func(name string, args map[string]any) bool {
+		asked = append(asked, name)
+		return true
+	}
+	a.OnToolDone = func(name string, args map[string]any, result ToolResult) {
+		done = append(done, result)
+	}
+
+	calls := []ToolCall{toolCall("1", "allowed"), toolCall("2", "denied"), toolCall("3", "asked")}
+	results, cancelled := a.runToolCalls(context.Background(), calls, nil)
+	if cancelled || len(results) != 3 {
+		t.Fatalf("cancelled = %v, results = %+v", cancelled, results)
+	}
+	if strings.Join(ran, ",") != "allowed,asked" || strings.Join(asked, ",") != "asked" {
+		t.Fatalf("ran %v, asked %v", ran, asked)
+	}
+	if strings.Join(started, ",") != "allowed,denied,asked" {
+		t.Fatalf("OnToolStart saw %v", started)
+	}
+	if content, _ := results[1].Content.(string); !strings.Contains(content, "Permission denied: not today") {
+		t.Fatalf("denied result = %q", content)
+	}
+	if len(done) != 3 || !errors.Is(done[1].Error, ErrPermissionDenied) {
+		t.Fatalf("OnToolDone results = %+v", done)
+	}
+}
+
+func TestPythonKernelPermissionFollowsLiveKernel(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	defaultExecutor = &LinuxSandboxExecutor{}
+
+	a := newToolCallTestAgent(1, PythonRuntimeTool(nil))
+	a.python = &pythonKernel{}
+	fresh := map[string]any{"code": "print(1)", "safety": "read_only"}
+	persistent := map[string]any{"code": "print(1)", "safety": "read_only", "persistent": true}
+	inspect := map[string]any{"action": "inspect"}
+
+	for _, args := range []map[string]any{fresh, persistent, inspect} {
+		if d := a.CheckPermission("python_runtime", args); d.Permission != PermissionAllow {
+			t.Fatalf("%v without a host kernel = %+v", args, d)
+		}
+	}
+
+	a.python.setProc(&kernelProcess{meta: ExecMeta{Sandboxed: false}})
+	if d := a.CheckPermission("python_runtime", fresh); d.Permission != PermissionAllow {
+		t.Fatalf("fresh-process call with a host kernel = %+v", d)
+	}
+	for _, args := range []map[string]any{persistent, inspect} {
+		if d := a.CheckPermission("python_runtime", args); d.Permission != PermissionAsk {
+			t.Fatalf("%v with a host kernel = %+v, want ask", args, d)
+		}
+	}
+}
@@ -0,0 +1,460 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pythonInterruptGrace is how long an interrupted kernel call may take to
+// unwind before the kernel is killed.
+const pythonInterruptGrace = 5 * time.Second
+
+// pythonKernelDriver runs in the kernel process. It reads one JSON request
+// per line from stdin, runs it in a namespace kept across requests, and ends
+// each reply with a line holding the session token and a JSON status. Code
+// output goes to the process's own stdout and stderr, so subprocess output
+// is captured too. SIGINT interrupts the running request only.
+const pythonKernelDriver = `import ast, json, sys, traceback, types
+_token = sys.argv[1]
+_ns = {"__name__": "__main__", "__builtins__": __builtins__}
+
+def _reply(ok, error=""):
+    sys.stdout.flush()
+    sys.stderr.flush()
+    sys.stdout.write("\n" + _token + json.dumps({"ok": ok, "error": error}) + "\n")
+    sys.stdout.flush()
+
+def _run(code):
+    tree = ast.parse(code, "<bono>", "exec")
+    last = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, "<bono>", "exec"), _ns)
+    if last is not None:
+        value = eval(compile(last, "<bono>", "eval"), _ns)
+        if value is not None:
+            print(repr(value))
+
+def _short(value, limit):
+    text = repr(value)
+    return text if len(text) <= limit else text[:limit] + "..."
+
+def _inspect(name):
+    if name:
+        value = eval(name, _ns)
+        print("%s: %s" % (name, type(value).__name__))
+        for attr in ("shape", "dtype", "columns"):
+            if hasattr(value, attr):
+                print("%s: %s" % (attr, _short(getattr(value, attr), 500)))
+        if hasattr(value, "__len__"):
+            try:
+                print("len: %d" % len(value))
+            except Exception:
+                pass
+        print(_short(value, 2000))
+        return
+    names = [k for k, v in _ns.items() if not k.startswith("_") and not isinstance(v, types.ModuleType)]
+    modules = [k for k, v in _ns.items() if not k.startswith("_") and isinstance(v, types.ModuleType)]
+    if not names and not modules:
+        print("(no variables)")
+    for k in names:
+        print("%s: %s = %s" % (k, type(_ns[k]).__name__, _short(_ns[k], 80)))
+    if modules:
+        print("modules: " + ", ".join(modules))
+
+while True:
+    try:
+        line = sys.stdin.readline()
+    except KeyboardInterrupt:
+        continue
+    if not line:
+        break
+    try:
+        req = json.loads(line)
+        if req.get("op") == "inspect":
+            _inspect(req.get("name", ""))
+        else:
+            _run(req.get("code", ""))
+        _reply(True)
+    except BaseException as e:
+        tb = e.__traceback__
+        while tb is not None and tb.tb_frame.f_code.co_filename != "<bono>":
+            tb = tb.tb_next
+        traceback.print_exception(type(e), e, tb)
+        _reply(False, "".join(traceback.format_exception_only(type(e), e)).strip())
+`
+
+// PythonKernelRequest is a python_runtime call in persistent mode.
+type PythonKernelRequest struct {
+	Action string // "run" (default), "inspect" or "restart"
+	Code   string // code to run
+	Name   string // variable or expression to inspect; empty lists all variables
+	Safety string // safety level, used to route the call through the shell policy
+}
+
+// pythonKernel is an agent's long-lived Python process for persistent
+// python_runtime calls. It is spawned on first use, routed through the
+// shell policy and sandbox like any shell command, and keeps its variables
+// until restarted, killed after an unanswered interrupt, or closed. Each
+// call is routed by its own safety level; a call routed differently from
+// the running kernel restarts it.
+type pythonKernel struct {
+	policy     ShellPolicy
+	onFallback func(cmd, reason string) bool
+
+	mu   sync.Mutex // serializes calls
+	proc *kernelProcess
+
+	// onHost is set while a kernel runs outside the sandbox. It is kept
+	// apart from mu so permission checks don't wait for a running call.
+	onHost atomic.Bool
+}
+
+// kernelProcess is one running kernel.
+type kernelProcess struct {
+	route   ShellRoute // route the kernel was spawned for: sandbox-first or host
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	stdin   *os.File
+	token   string
+	out     kernelOutput
+	meta    ExecMeta
+	done    chan struct{}
+	waitErr error // set before done is closed
+}
+
+// kernelOutput buffers kernel output until a reply marker arrives.
+type kernelOutput struct {
+	mu     sync.Mutex
+	buf    []byte
+	notify chan struct{} // closed on the next write
+}
+
+func (o *kernelOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	if o.notify != nil {
+		close(o.notify)
+		o.notify = nil
+	}
+	return len(p), nil
+}
+
+// take removes the output before a complete reply line starting with token
+// and returns it with the reply's JSON. Without a complete reply, ok is
+// false and changed is closed at the next write.
+func (o *kernelOutput) take(token string) (output string, reply []byte, ok bool, changed <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if i := bytes.Index(o.buf, []byte(token)); i >= 0 {
+		if n := bytes.IndexByte(o.buf[i:], '\n'); n >= 0 {
+			output = strings.TrimRight(string(o.buf[:i]), "\n")
+			reply = append([]byte(nil), o.buf[i+len(token):i+n]...)
+			o.buf = append(o.buf[:0], o.buf[i+n+1:]...)
+			return output, reply, true, nil
+		}
+	}
+	if o.notify == nil {
+		o.notify = make(chan struct{})
+	}
+	return "", nil, false, o.notify
+}
+
+// rest drains whatever output is buffered.
+func (o *kernelOutput) rest() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	out := string(o.buf)
+	o.buf = nil
+	return out
+}
+
+func (p *kernelProcess) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// stop kills the kernel and waits for it to exit.
+func (p *kernelProcess) stop() {
+	p.stdin.Close()
+	p.cancel()
+	<-p.done
+}
+
+// run handles a persistent python_runtime call, bounded by the sandbox
+// command timeout.
+func (k *pythonKernel) run(ctx context.Context, req PythonKernelRequest) ToolResult {
+	return k.call(ctx, req, normalizeSandboxConfig(activeSandboxConfig()).CommandTimeout)
+}
+
+// call handles one persistent python_runtime request. timeout bounds a run
+// or inspect; <= 0 disables it.
+func (k *pythonKernel) call(ctx context.Context, req PythonKernelRequest, timeout time.Duration) ToolResult {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var msg map[string]string
+	switch req.Action {
+	case "restart":
+		k.stopLocked()
+		return ToolResult{Success: true, Output: "Python kernel restarted; all variables were cleared.", Status: "restarted"}
+	case "inspect":
+		msg = map[string]string{"op": "inspect", "name": req.Name}
+	case "", "run":
+		msg = map[string]string{"op": "exec", "code": req.Code}
+	default:
+		return kernelFailure(fmt.Errorf("unknown python_runtime action %q (want run, inspect or restart)", req.Action), "")
+	}
+
+	decision := k.route(req.Safety)
+	if decision.Route == ShellRouteDeny {
+		return deniedResult(decision)
+	}
+
+	var note string
+	switch {
+	case k.proc != nil && !k.proc.running():
+		note = "[The previous Python kernel had exited; started a new one, so earlier variables are gone.]\n"
+		k.setProc(nil)
+	case k.proc != nil && k.proc.route != decision.Route:
+		note = "[This call is routed " + routeName(decision.Route) + " but the Python kernel ran " + routeName(k.proc.route) + "; started a new one, so earlier variables are gone.]\n"
+		k.stopLocked()
+	}
+	if k.proc == nil {
+		proc, res := k.spawn(decision.Route)
+		if proc == nil {
+			return res
+		}
+		k.setProc(proc)
+	}
+
+	res := k.proc.send(ctx, msg, timeout)
+	if !k.proc.running() {
+		k.setProc(nil)
+	}
+	res.Output = note + res.Output
+	return res
+}
+
+// close kills the kernel, if running.
+func (k *pythonKernel) close() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.stopLocked()
+}
+
+func (k *pythonKernel) stopLocked() {
+	if k.proc != nil {
+		k.proc.stop()
+		k.setProc(nil)
+	}
+}
+
+// setProc records the running kernel. Called with k.mu held.
+func (k *pythonKernel) setProc(proc *kernelProcess) {
+	k.proc = proc
+	k.onHost.Store(proc != nil && !proc.meta.Sandboxed)
+}
+
+// runsOnHost reports whether a kernel is running outside the sandbox, so
+// calls it would handle are not sandboxed whatever their route.
+func (k *pythonKernel) runsOnHost() bool {
+	return k.onHost.Load()
+}
+
+// route decides how a kernel call at safety is run: sandbox-first, on the
+// host (also when no sandbox is available) or denied.
+func (k *pythonKernel) route(safety string) ShellDecision {
+	shellReq := ShellRequest{ToolName: "python_runtime", Command: kernelCommand("bono-kernel"), Safety: safety}
+	decision := DecideShellRequest(k.policy, shellReq)
+	if decision.Route == ShellRouteSandboxFirst && !IsSandboxEnabled() {
+		decision.Route = ShellRouteHostDirect
+	}
+	return decision
+}
+
+// routeName describes a kernel route in notes to the model.
+func routeName(route ShellRoute) string {
+	if route == ShellRouteHostDirect {
+		return "on the host"
+	}
+	return "in the sandbox"
+}
+
+// kernelCommand is the shell command that starts a kernel answering with
+// marker.
+func kernelCommand(marker string) string {
+	script := base64.StdEncoding.EncodeToString([]byte(pythonKernelDriver))
+	return fmt.Sprintf(`exec python3 -u -c "import base64; exec(base64.b64decode('%s'))" %s`, script, marker)
+}
+
+// spawn starts a kernel for route, sandboxed unless route is host-direct.
+// If the sandbox can't start it and fallback is enabled, onFallback may
+// approve starting it on the host; it keeps the sandbox-first route, and
+// its ExecMeta records that it is not sandboxed.
+func (k *pythonKernel) spawn(route ShellRoute) (*kernelProcess, ToolResult) {
+	token := make([]byte, 16)
+	rand.Read(token)
+	marker := "bono-kernel-" + hex.EncodeToString(token)
+	command := kernelCommand(marker)
+
+	executor := GetExecutor()
+	if route == ShellRouteHostDirect {
+		executor = &PassthroughExecutor{}
+	}
+
+	proc, err := startKernelProcess(executor, command, marker)
+	if err != nil {
+		res := kernelFailure(fmt.Errorf("start python kernel: %w", err), "")
+		res.ExecMeta = &proc.meta
+		if !shouldOfferSandboxFallback(res) || k.onFallback == nil || !k.onFallback("python3 (persistent kernel)", sandboxFallbackReason(res)) {
+			return nil, res
+		}
+		if proc, err = startKernelProcess(&PassthroughExecutor{}, command, marker); err != nil {
+			return nil, kernelFailure(fmt.Errorf("start python kernel: %w", err), "")
+		}
+	}
+	proc.route = route
+	return proc, ToolResult{}
+}
+
+// startKernelProcess starts the kernel command. On error the returned
+// process carries only the ExecMeta describing the failure.
+func startKernelProcess(executor ShellExecutor, command, token string) (*kernelProcess, error) {
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return &kernelProcess{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &kernelProcess{cancel: cancel, stdin: stdinW, token: token, done: make(chan struct{})}
+	cmd, meta, err := startShellCommand(ctx, executor, command, stdinR, &p.out)
+	stdinR.Close()
+	p.meta = meta
+	if err != nil {
+		cancel()
+		stdinW.Close()
+		return p, err
+	}
+	p.cmd = cmd
+	go func() {
+		p.waitErr = cmd.Wait()
+		cancel()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// send writes one request and waits for its reply. On timeout or ctx
+// cancellation the kernel is interrupted, which keeps its variables; if it
+// doesn't answer within pythonInterruptGrace it is killed.
+func (p *kernelProcess) send(ctx context.Context, msg map[string]string, timeout time.Duration) ToolResult {
+	line, _ := json.Marshal(msg)
+	start := time.Now()
+	meta := p.meta
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		p.stop()
+		return kernelFailure(fmt.Errorf("python kernel: %w", err), p.out.rest())
+	}
+
+	var deadline, grace <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		deadline = t.C
+	}
+	cancelled := ctx.Done()
+	var interrupted string
+
+	for {
+		output, reply, ok, changed := p.out.take(p.token)
+		if ok {
+			var status struct {
+				OK    bool   `json:"ok"`
+				Error string `json:"error"`
+			}
+			json.Unmarshal(reply, &status)
+			meta.Duration = time.Since(start)
+			elapsed := meta.Duration.Seconds()
+			if interrupted != "" {
+				output += fmt.Sprintf("\n[Interrupted: %s. Variables set before the interrupt are kept.]", interrupted)
+			}
+			if output == "" {
+				output = "(no output)"
+			}
+			if !status.OK {
+				return ToolResult{
+					Success:  false,
+					Output:   output,
+					Error:    fmt.Errorf("python: %s", status.Error),
+					Status:   fmt.Sprintf("fail (%.1fs)", elapsed),
+					ExecMeta: &meta,
+				}
+			}
+			return ToolResult{
+				Success:  true,
+				Output:   output,
+				Status:   fmt.Sprintf("ok (%.1fs)", elapsed),
+				ExecMeta: &meta,
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-p.done:
+			err := fmt.Errorf("python kernel exited: %v", p.waitErr)
+			return kernelFailure(err, p.out.rest()+"\n[The Python kernel exited; its variables are gone. The next call starts a new one.]")
+		case <-deadline:
+			deadline = nil
+			meta.TimedOut = true
+			interrupted = fmt.Sprintf("timed out after %s", timeout)
+			grace = p.interrupt()
+		case <-cancelled:
+			cancelled = nil
+			interrupted = "cancelled"
+			grace = p.interrupt()
+		case <-grace:
+			p.stop()
+			err := fmt.Errorf("python kernel %s and did not respond to interrupt", interrupted)
+			return kernelFailure(err, p.out.rest()+"\n[The Python kernel was killed; its variables are gone. The next call starts a new one.]")
+		}
+	}
+}
+
+// interrupt sends SIGINT to the kernel and returns the deadline for it to
+// answer. Without SIGINT support, the grace period is skipped.
+func (p *kernelProcess) interrupt() <-chan time.Time {
+	if err := interruptProcessGroup(p.cmd); err != nil {
+		return time.After(0)
+	}
+	return time.After(pythonInterruptGrace)
+}
+
+// kernelFailure reports a failed kernel call; Output carries the reason.
+func kernelFailure(err error, output string) ToolResult {
+	out := err.Error()
+	if output = strings.TrimSpace(output); output != "" {
+		out = output + "\n" + out
+	}
+	return ToolResult{
+		Success: false,
+		Output:  out,
+		Error:   err,
+		Status:  "fail: " + err.Error(),
+	}
+}
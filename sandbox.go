@@ -1,13 +1,16 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,22 +20,123 @@ type ShellExecutor interface {
 	Run(command string) (ToolResult, ExecMeta)
 }
 
+// ContextShellExecutor is a ShellExecutor that can stop a running command.
+type ContextShellExecutor interface {
+	ShellExecutor
+	// RunContext is like Run but kills the command's process group when ctx is done.
+	RunContext(ctx context.Context, command string) (ToolResult, ExecMeta)
+}
+
+// StreamingShellExecutor is a ShellExecutor that reports output as it arrives.
+type StreamingShellExecutor interface {
+	ContextShellExecutor
+	// RunStream is like RunContext but also passes output to onOutput as the
+	// command produces it. The result still carries the full output.
+	RunStream(ctx context.Context, command string, onOutput ShellOutputFunc) (ToolResult, ExecMeta)
+}
+
+// runShellExecutor runs command with the most capable method executor has.
+// Executors without RunStream don't stream output, and those with only Run
+// aren't stopped by ctx, though a command isn't started once ctx is done.
+func runShellExecutor(ctx context.Context, executor ShellExecutor, command string, onOutput ShellOutputFunc) (ToolResult, ExecMeta) {
+	switch e := executor.(type) {
+	case StreamingShellExecutor:
+		return e.RunStream(ctx, command, onOutput)
+	case ContextShellExecutor:
+		return e.RunContext(ctx, command)
+	}
+	if err := ctx.Err(); err != nil {
+		var meta ExecMeta
+		return commandCancelledResult("", err, 0, &meta), meta
+	}
+	return executor.Run(command)
+}
+
+// ShellOutputFunc receives a chunk of a running command's output. stream is
+// "stdout" or "stderr". Calls for one command are never concurrent.
+type ShellOutputFunc func(stream, chunk string)
+
+// shellOutput collects a command's output, both combined and per stream,
+// forwarding each chunk to onOutput as it arrives.
+type shellOutput struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	stdout   bytes.Buffer
+	stderr   bytes.Buffer
+	onOutput ShellOutputFunc
+}
+
+// writer returns the io.Writer for one of the command's streams.
+func (o *shellOutput) writer(stream string) io.Writer {
+	return shellStreamWriter{out: o, stream: stream}
+}
+
+func (o *shellOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// recordExit fills in how a finished command ended: exit code, terminating
+// signal, wall time and its separate output streams. A nil state means the
+// command never started.
+func (m *ExecMeta) recordExit(state *os.ProcessState, took time.Duration, out *shellOutput) {
+	m.Duration = took
+	m.ExitCode = -1
+	if state != nil {
+		m.ExitCode = state.ExitCode()
+		m.Signal = exitSignal(state)
+	}
+	out.mu.Lock()
+	m.Stdout, m.Stderr = out.stdout.String(), out.stderr.String()
+	out.mu.Unlock()
+}
+
+type shellStreamWriter struct {
+	out    *shellOutput
+	stream string
+}
+
+func (w shellStreamWriter) Write(p []byte) (int, error) {
+	w.out.mu.Lock()
+	defer w.out.mu.Unlock()
+	w.out.buf.Write(p)
+	if w.stream == "stderr" {
+		w.out.stderr.Write(p)
+	} else {
+		w.out.stdout.Write(p)
+	}
+	if w.out.onOutput != nil {
+		w.out.onOutput(w.stream, string(p))
+	}
+	return len(p), nil
+}
+
 const defaultCommandTimeout = 30 * time.Second
 
-// sandboxAvailable checks if macOS sandbox-exec is available.
+// sandboxAvailable checks if a platform sandbox is available:
+// sandbox-exec on macOS, Landlock on Linux.
 func sandboxAvailable() bool {
-	if runtime.GOOS != "darwin" {
+	switch runtime.GOOS {
+	case "darwin":
+		_, err := exec.LookPath("sandbox-exec")
+		return err == nil
+	case "linux":
+		return landlockABI() > 0
+	default:
 		return false
 	}
-	_, err := exec.LookPath("sandbox-exec")
-	return err == nil
 }
 
 // NewShellExecutor creates the appropriate executor based on config and platform.
-// Returns a SandboxedExecutor on macOS with sandbox enabled, otherwise PassthroughExecutor.
+// Returns a SandboxedExecutor on macOS or a LinuxSandboxExecutor on Linux when
+// sandboxing is enabled, otherwise PassthroughExecutor.
 func NewShellExecutor(cfg SandboxConfig) ShellExecutor {
 	cfg = normalizeSandboxConfig(cfg)
 	if cfg.Enabled && sandboxAvailable() {
+		if runtime.GOOS == "linux" {
+			return &LinuxSandboxExecutor{config: cfg}
+		}
 		return &SandboxedExecutor{config: cfg}
 	}
 	return &PassthroughExecutor{config: cfg}
@@ -80,26 +184,46 @@ type SandboxedExecutor struct {
 
 // Run executes a command inside the sandbox.
 func (s *SandboxedExecutor) Run(command string) (ToolResult, ExecMeta) {
+	return s.RunContext(context.Background(), command)
+}
+
+// RunContext executes a command inside the sandbox, stopping it when ctx is done.
+func (s *SandboxedExecutor) RunContext(ctx context.Context, command string) (ToolResult, ExecMeta) {
+	return s.RunStream(ctx, command, nil)
+}
+
+// RunStream is like RunContext but streams output to onOutput as it arrives.
+func (s *SandboxedExecutor) RunStream(parent context.Context, command string, onOutput ShellOutputFunc) (ToolResult, ExecMeta) {
 	profile := s.generateProfile()
 
 	start := time.Now()
-	ctx := context.Background()
+	ctx := parent
 	cancel := func() {}
 	if s.config.CommandTimeout > 0 {
 		ctx, cancel = context.WithTimeout(ctx, s.config.CommandTimeout)
 	}
 	defer cancel()
 
+	out := &shellOutput{onOutput: onOutput}
 	cmd := exec.CommandContext(ctx, "sandbox-exec", "-p", profile, "sh", "-c", command)
-	out, err := cmd.CombinedOutput()
-	elapsed := time.Since(start).Seconds()
+	cmd.Stdout = out.writer("stdout")
+	cmd.Stderr = out.writer("stderr")
+	configureProcessGroup(cmd)
+	err := cmd.Run()
+	took := time.Since(start)
+	elapsed := took.Seconds()
 
 	meta := ExecMeta{Sandboxed: true}
+	meta.recordExit(cmd.ProcessState, took, out)
 
 	if err != nil {
 		// Check if this is a sandbox denial vs regular command failure
-		output := string(out)
+		output := out.String()
+		if parent.Err() != nil {
+			return commandCancelledResult(output, parent.Err(), elapsed, &meta), meta
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			meta.TimedOut = true
 			meta.SandboxReason = fmt.Sprintf("timed out inside sandbox after %s", s.config.CommandTimeout)
 			return ToolResult{
 				Success:  false,
@@ -133,7 +257,7 @@ func (s *SandboxedExecutor) Run(command string) (ToolResult, ExecMeta) {
 
 	return ToolResult{
 		Success:  true,
-		Output:   string(out),
+		Output:   out.String(),
 		Status:   fmt.Sprintf("ok (%.1fs)", elapsed),
 		ExecMeta: &meta,
 	}, meta
@@ -234,6 +358,12 @@ func extractSandboxReason(output string) string {
 	return "sandbox policy violation"
 }
 
+// LinuxSandboxExecutor executes commands under Landlock filesystem rules and,
+// when network is disallowed, inside a private network namespace.
+type LinuxSandboxExecutor struct {
+	config SandboxConfig
+}
+
 // PassthroughExecutor executes commands directly without sandboxing.
 type PassthroughExecutor struct {
 	config SandboxConfig
@@ -241,25 +371,47 @@ type PassthroughExecutor struct {
 
 // Run executes a command directly (no sandbox).
 func (p *PassthroughExecutor) Run(command string) (ToolResult, ExecMeta) {
+	return p.RunContext(context.Background(), command)
+}
+
+// RunContext executes a command directly, stopping it when ctx is done.
+func (p *PassthroughExecutor) RunContext(ctx context.Context, command string) (ToolResult, ExecMeta) {
+	return p.RunStream(ctx, command, nil)
+}
+
+// RunStream is like RunContext but streams output to onOutput as it arrives.
+func (p *PassthroughExecutor) RunStream(parent context.Context, command string, onOutput ShellOutputFunc) (ToolResult, ExecMeta) {
 	meta := ExecMeta{Sandboxed: false}
 
 	start := time.Now()
 	cfg := normalizeSandboxConfig(p.config)
-	ctx := context.Background()
+	ctx := parent
 	cancel := func() {}
 	if cfg.CommandTimeout > 0 {
 		ctx, cancel = context.WithTimeout(ctx, cfg.CommandTimeout)
 	}
 	defer cancel()
 
-	out, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
-	elapsed := time.Since(start).Seconds()
+	out := &shellOutput{onOutput: onOutput}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = out.writer("stdout")
+	cmd.Stderr = out.writer("stderr")
+	configureProcessGroup(cmd)
+	err := cmd.Run()
+	took := time.Since(start)
+	elapsed := took.Seconds()
+	meta.recordExit(cmd.ProcessState, took, out)
+	output := out.String()
 
 	if err != nil {
+		if parent.Err() != nil {
+			return commandCancelledResult(output, parent.Err(), elapsed, &meta), meta
+		}
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			meta.TimedOut = true
 			return ToolResult{
 				Success:  false,
-				Output:   string(out),
+				Output:   output,
 				Error:    ctx.Err(),
 				Status:   fmt.Sprintf("timeout (%.1fs)", elapsed),
 				ExecMeta: &meta,
@@ -267,7 +419,7 @@ func (p *PassthroughExecutor) Run(command string) (ToolResult, ExecMeta) {
 		}
 		return ToolResult{
 			Success:  false,
-			Output:   string(out),
+			Output:   output,
 			Error:    err,
 			Status:   fmt.Sprintf("fail (%.1fs)", elapsed),
 			ExecMeta: &meta,
@@ -276,12 +428,51 @@ func (p *PassthroughExecutor) Run(command string) (ToolResult, ExecMeta) {
 
 	return ToolResult{
 		Success:  true,
-		Output:   string(out),
+		Output:   output,
 		Status:   fmt.Sprintf("ok (%.1fs)", elapsed),
 		ExecMeta: &meta,
 	}, meta
 }
 
+// commandCancelledResult reports a command killed because the caller's context ended.
+func commandCancelledResult(output string, err error, elapsed float64, meta *ExecMeta) ToolResult {
+	return ToolResult{
+		Success:  false,
+		Output:   output,
+		Error:    err,
+		Status:   fmt.Sprintf("cancelled (%.1fs)", elapsed),
+		ExecMeta: meta,
+	}
+}
+
+// startShellCommand starts command under executor's confinement without
+// waiting for it, reading stdin (which may be nil) and with combined output
+// written to out. Cancelling ctx kills the command's process group.
+// Commands for executors other than the built-in sandboxes run on the host.
+func startShellCommand(ctx context.Context, executor ShellExecutor, command string, stdin io.Reader, out io.Writer) (*exec.Cmd, ExecMeta, error) {
+	var cmd *exec.Cmd
+	meta := ExecMeta{Sandboxed: true}
+	switch e := executor.(type) {
+	case *LinuxSandboxExecutor:
+		cmd, err := e.startCommand(ctx, command, stdin, out, out)
+		if err != nil {
+			meta.SandboxError = true
+			meta.SandboxReason = "sandbox setup failed: " + err.Error()
+		}
+		return cmd, meta, err
+	case *SandboxedExecutor:
+		cmd = exec.CommandContext(ctx, "sandbox-exec", "-p", e.generateProfile(), "sh", "-c", command)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		meta.Sandboxed = false
+	}
+	cmd.Stdin = stdin
+	cmd.Stdout = out
+	cmd.Stderr = out
+	configureProcessGroup(cmd)
+	return cmd, meta, cmd.Start()
+}
+
 // Global executor instance, initialized by InitSandbox or on first use.
 var defaultExecutor ShellExecutor
 
@@ -299,20 +490,44 @@ func GetExecutor() ShellExecutor {
 	return defaultExecutor
 }
 
+// activeSandboxConfig returns the configuration the current executor was built with.
+func activeSandboxConfig() SandboxConfig {
+	switch e := GetExecutor().(type) {
+	case *SandboxedExecutor:
+		return e.config
+	case *LinuxSandboxExecutor:
+		return e.config
+	case *PassthroughExecutor:
+		return e.config
+	}
+	return SandboxConfig{}
+}
+
 // IsSandboxEnabled returns true if the current executor is sandboxed.
 func IsSandboxEnabled() bool {
-	exec := GetExecutor()
-	_, isSandboxed := exec.(*SandboxedExecutor)
+	_, isSandboxed := sandboxExecutorConfig(GetExecutor())
 	return isSandboxed
 }
 
 // SandboxFallbackEnabled returns true when the active sandbox executor is
 // configured to allow approval-based retries outside the sandbox.
 func SandboxFallbackEnabled() bool {
-	exec := GetExecutor()
-	sandboxed, ok := exec.(*SandboxedExecutor)
+	cfg, ok := sandboxExecutorConfig(GetExecutor())
 	if !ok {
 		return false
 	}
-	return sandboxed.config.FallbackOutsideSandbox
+	return cfg.FallbackOutsideSandbox
+}
+
+// sandboxExecutorConfig returns the config of a sandboxing executor.
+// The boolean is false for executors that run commands unconfined.
+func sandboxExecutorConfig(exec ShellExecutor) (SandboxConfig, bool) {
+	switch e := exec.(type) {
+	case *SandboxedExecutor:
+		return e.config, true
+	case *LinuxSandboxExecutor:
+		return e.config, true
+	default:
+		return SandboxConfig{}, false
+	}
 }
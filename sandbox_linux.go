@@ -0,0 +1,414 @@
+//go:build linux
+
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Landlock syscalls share the same numbers on every architecture.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+
+	prSetNoNewPrivs = 38
+)
+
+// Landlock access rights from include/uapi/linux/landlock.h.
+const (
+	landlockFSExecute    = 1 << 0
+	landlockFSWriteFile  = 1 << 1
+	landlockFSReadFile   = 1 << 2
+	landlockFSReadDir    = 1 << 3
+	landlockFSRemoveDir  = 1 << 4
+	landlockFSRemoveFile = 1 << 5
+	landlockFSMakeChar   = 1 << 6
+	landlockFSMakeDir    = 1 << 7
+	landlockFSMakeReg    = 1 << 8
+	landlockFSMakeSock   = 1 << 9
+	landlockFSMakeFifo   = 1 << 10
+	landlockFSMakeBlock  = 1 << 11
+	landlockFSMakeSym    = 1 << 12
+	landlockFSRefer      = 1 << 13 // ABI 2
+	landlockFSTruncate   = 1 << 14 // ABI 3
+	landlockFSIoctlDev   = 1 << 15 // ABI 5
+
+	landlockNetBindTCP    = 1 << 0 // ABI 4
+	landlockNetConnectTCP = 1 << 1 // ABI 4
+
+	landlockFSRead = landlockFSReadFile | landlockFSReadDir | landlockFSExecute
+	// landlockFSWrite covers every mutating right, including those newer ABIs add.
+	landlockFSWrite = landlockFSWriteFile | landlockFSRemoveDir | landlockFSRemoveFile |
+		landlockFSMakeChar | landlockFSMakeDir | landlockFSMakeReg | landlockFSMakeSock |
+		landlockFSMakeFifo | landlockFSMakeBlock | landlockFSMakeSym |
+		landlockFSRefer | landlockFSTruncate | landlockFSIoctlDev
+	// landlockFSFileOnly are the rights the kernel accepts on a non-directory rule.
+	landlockFSFileOnly = landlockFSExecute | landlockFSWriteFile | landlockFSReadFile |
+		landlockFSTruncate | landlockFSIoctlDev
+)
+
+var (
+	landlockABIOnce  sync.Once
+	landlockABIValue int
+
+	netNamespaceOnce      sync.Once
+	netNamespaceAttrValue *syscall.SysProcAttr
+)
+
+// landlockABI returns the kernel's Landlock ABI version, or 0 if unsupported.
+func landlockABI() int {
+	landlockABIOnce.Do(func() {
+		v, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+		if errno == 0 {
+			landlockABIValue = int(v)
+		}
+	})
+	return landlockABIValue
+}
+
+// Run executes a command inside the Landlock sandbox.
+func (s *LinuxSandboxExecutor) Run(command string) (ToolResult, ExecMeta) {
+	return s.RunContext(context.Background(), command)
+}
+
+// RunContext executes a command inside the Landlock sandbox, stopping it when ctx is done.
+func (s *LinuxSandboxExecutor) RunContext(ctx context.Context, command string) (ToolResult, ExecMeta) {
+	return s.RunStream(ctx, command, nil)
+}
+
+// RunStream is like RunContext but streams output to onOutput as it arrives.
+func (s *LinuxSandboxExecutor) RunStream(parent context.Context, command string, onOutput ShellOutputFunc) (ToolResult, ExecMeta) {
+	start := time.Now()
+	ctx := parent
+	cancel := func() {}
+	if s.config.CommandTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.config.CommandTimeout)
+	}
+	defer cancel()
+
+	out := &shellOutput{onOutput: onOutput}
+	meta := ExecMeta{Sandboxed: true}
+
+	cmd, err := s.startCommand(ctx, command, nil, out.writer("stdout"), out.writer("stderr"))
+	if err != nil {
+		meta.SandboxError = true
+		meta.SandboxReason = "sandbox setup failed: " + err.Error()
+		meta.recordExit(nil, time.Since(start), out)
+		return ToolResult{
+			Success:  false,
+			Error:    err,
+			Status:   fmt.Sprintf("sandbox blocked (%.1fs)", time.Since(start).Seconds()),
+			ExecMeta: &meta,
+		}, meta
+	}
+	err = cmd.Wait()
+	took := time.Since(start)
+	elapsed := took.Seconds()
+	meta.recordExit(cmd.ProcessState, took, out)
+	output := out.String()
+
+	if err != nil {
+		if parent.Err() != nil {
+			return commandCancelledResult(output, parent.Err(), elapsed, &meta), meta
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			meta.TimedOut = true
+			meta.SandboxReason = fmt.Sprintf("timed out inside sandbox after %s", s.config.CommandTimeout)
+			return ToolResult{
+				Success:  false,
+				Output:   output,
+				Error:    ctx.Err(),
+				Status:   fmt.Sprintf("timeout (%.1fs)", elapsed),
+				ExecMeta: &meta,
+			}, meta
+		}
+		if isLinuxSandboxDenial(output, s.config) {
+			meta.SandboxError = true
+			meta.SandboxReason = extractLinuxSandboxReason(output)
+			return ToolResult{
+				Success:  false,
+				Output:   output,
+				Error:    err,
+				Status:   fmt.Sprintf("sandbox blocked (%.1fs)", elapsed),
+				ExecMeta: &meta,
+			}, meta
+		}
+
+		// Regular command failure (not sandbox related)
+		return ToolResult{
+			Success:  false,
+			Output:   output,
+			Error:    err,
+			Status:   fmt.Sprintf("fail (%.1fs)", elapsed),
+			ExecMeta: &meta,
+		}, meta
+	}
+
+	return ToolResult{
+		Success:  true,
+		Output:   output,
+		Status:   fmt.Sprintf("ok (%.1fs)", elapsed),
+		ExecMeta: &meta,
+	}, meta
+}
+
+// startCommand starts command under the sandbox, reading stdin (which may be
+// nil) and with output written to stdout and stderr. Cancelling ctx kills
+// the command's process group.
+func (s *LinuxSandboxExecutor) startCommand(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// Prefer a private network namespace; fall back to Landlock's TCP rules
+	// when namespaces are unavailable (for example, unprivileged userns disabled).
+	denyTCP := false
+	if !s.config.AllowNetwork {
+		if attr := netNamespaceAttr(); attr != nil {
+			cmd.SysProcAttr = attr
+		} else {
+			denyTCP = true
+		}
+	}
+	configureProcessGroup(cmd)
+	return cmd, startLandlocked(cmd, s.config, denyTCP)
+}
+
+// startLandlocked starts cmd from an OS thread restricted by the Landlock ruleset.
+// Landlock domains apply per thread and are inherited by children, so the thread is
+// locked and never unlocked: the runtime destroys it when the goroutine exits
+// instead of handing the restricted thread to other goroutines.
+func startLandlocked(cmd *exec.Cmd, cfg SandboxConfig, denyTCP bool) error {
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := restrictThread(cfg, denyTCP); err != nil {
+			errc <- err
+			return
+		}
+		errc <- cmd.Start()
+	}()
+	return <-errc
+}
+
+// restrictThread applies a Landlock ruleset built from cfg to the calling thread.
+// Like the macOS profile, which allows process-exec globally, anything readable may
+// be executed; ExecPaths are granted explicitly so they work outside ReadPaths too.
+func restrictThread(cfg SandboxConfig, denyTCP bool) error {
+	abi := landlockABI()
+	if abi == 0 {
+		return errors.New("landlock not supported by kernel")
+	}
+
+	handledFS := uint64(landlockFSExecute | landlockFSWriteFile | landlockFSReadFile | landlockFSReadDir |
+		landlockFSRemoveDir | landlockFSRemoveFile | landlockFSMakeChar | landlockFSMakeDir |
+		landlockFSMakeReg | landlockFSMakeSock | landlockFSMakeFifo | landlockFSMakeBlock | landlockFSMakeSym)
+	if abi >= 2 {
+		handledFS |= landlockFSRefer
+	}
+	if abi >= 3 {
+		handledFS |= landlockFSTruncate
+	}
+	if abi >= 5 {
+		handledFS |= landlockFSIoctlDev
+	}
+
+	// struct landlock_ruleset_attr { __u64 handled_access_fs; __u64 handled_access_net; }
+	attr := [2]uint64{handledFS, 0}
+	attrSize := uintptr(8)
+	if denyTCP && abi >= 4 {
+		attr[1] = landlockNetBindTCP | landlockNetConnectTCP
+		attrSize = 16
+	}
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr[0])), attrSize, 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock_create_ruleset: %w", errno)
+	}
+	rulesetFD := int(fd)
+	defer syscall.Close(rulesetFD)
+
+	rules := []struct {
+		paths  []string
+		access uint64
+	}{
+		{cfg.ReadPaths, landlockFSRead},
+		{cfg.WritePaths, landlockFSWrite},
+		{cfg.ExecPaths, landlockFSRead},
+		// Pseudo-terminals and /dev/null, matching the macOS profile.
+		{[]string{"/dev"}, landlockFSRead | landlockFSWrite},
+	}
+	for _, rule := range rules {
+		for _, path := range rule.paths {
+			if err := addLandlockPathRule(rulesetFD, path, rule.access&handledFS); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	if _, _, errno := syscall.Syscall(sysLandlockRestrictSelf, uintptr(rulesetFD), 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_restrict_self: %w", errno)
+	}
+	return nil
+}
+
+// addLandlockPathRule grants access beneath path. Missing paths are skipped so
+// defaults like /opt/homebrew/bin can be shared across platforms.
+func addLandlockPathRule(rulesetFD int, path string, access uint64) error {
+	if path == "" || access == 0 {
+		return nil
+	}
+	pathFD, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+			return nil
+		}
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	defer syscall.Close(pathFD)
+
+	var st syscall.Stat_t
+	if err := syscall.Fstat(pathFD, &st); err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		access &= landlockFSFileOnly
+	}
+
+	// struct landlock_path_beneath_attr { __u64 allowed_access; __s32 parent_fd; } __attribute__((packed))
+	var attr [12]byte
+	binary.NativeEndian.PutUint64(attr[0:8], access)
+	binary.NativeEndian.PutUint32(attr[8:12], uint32(pathFD))
+	if _, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(rulesetFD), landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&attr[0])), 0, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock_add_rule %s: %w", path, errno)
+	}
+	return nil
+}
+
+// netNamespaceAttr returns process attributes that place a command in a fresh
+// network namespace, or nil if the host does not allow it. Root can unshare the
+// network namespace directly; other users need a user namespace mapping their IDs.
+func netNamespaceAttr() *syscall.SysProcAttr {
+	netNamespaceOnce.Do(func() {
+		var attr *syscall.SysProcAttr
+		if os.Geteuid() == 0 {
+			attr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNET}
+		} else {
+			attr = &syscall.SysProcAttr{
+				Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+				UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+				GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+			}
+		}
+		probe := exec.Command("sh", "-c", "exit 0")
+		probe.SysProcAttr = attr
+		if probe.Run() == nil {
+			netNamespaceAttrValue = attr
+		}
+	})
+	if netNamespaceAttrValue == nil {
+		return nil
+	}
+	attr := *netNamespaceAttrValue
+	return &attr
+}
+
+// isLinuxSandboxDenial checks if a failure is due to Landlock or the network namespace.
+// Landlock denials surface as EACCES ("Permission denied") from the failing syscall,
+// which only counts when it names a path the sandbox restricts; network errors only
+// count when cfg blocks the network.
+func isLinuxSandboxDenial(output string, cfg SandboxConfig) bool {
+	lower := strings.ToLower(output)
+	if !cfg.AllowNetwork {
+		for _, marker := range []string{
+			"network is unreachable",
+			"could not resolve host",
+			"temporary failure in name resolution",
+			"name or service not known",
+		} {
+			if strings.Contains(lower, marker) {
+				return true
+			}
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		lowerLine := strings.ToLower(line)
+		if !strings.Contains(lowerLine, "permission denied") && !strings.Contains(lowerLine, "operation not permitted") {
+			continue
+		}
+		paths := deniedPaths(line)
+		if len(paths) == 0 {
+			return true
+		}
+		for _, path := range paths {
+			if !landlockGrantsAll(cfg, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deniedPaths returns the path-like words of an error line, such as
+// "/etc/hosts" in "touch: cannot touch '/etc/hosts': Permission denied".
+func deniedPaths(line string) []string {
+	var paths []string
+	for _, word := range strings.Fields(line) {
+		word = strings.Trim(word, "'\"`:,;()[]")
+		if strings.Contains(word, "/") || strings.HasPrefix(word, ".") {
+			paths = append(paths, word)
+		}
+	}
+	return paths
+}
+
+// landlockGrantsAll reports whether the ruleset built from cfg allows every
+// access beneath path, so a denial there came from the host, not the sandbox.
+func landlockGrantsAll(cfg SandboxConfig, path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if real, err := resolveExistingPrefix(abs); err == nil {
+		abs = real
+	}
+	for _, dir := range append([]string{"/dev"}, cfg.WritePaths...) {
+		if real, err := resolveExistingPrefix(dir); err == nil {
+			dir = real
+		}
+		if dir != "" && pathWithin(dir, abs) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractLinuxSandboxReason maps Linux sandbox failures to a human-readable reason.
+func extractLinuxSandboxReason(output string) string {
+	lower := strings.ToLower(output)
+	if strings.Contains(lower, "resolve") || strings.Contains(lower, "name or service") {
+		return "network access denied"
+	}
+	return extractSandboxReason(output)
+}
@@ -0,0 +1,134 @@
+//go:build linux
+
+package core
+
+import (
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinuxSandboxExecutorWriteAllowedPath(t *testing.T) {
+	if !sandboxAvailable() {
+		t.Skip("landlock not available")
+	}
+
+	dir := t.TempDir()
+	cfg := DefaultSandboxConfig()
+	cfg.WritePaths = []string{dir}
+	exec := NewShellExecutor(cfg)
+	if _, ok := exec.(*LinuxSandboxExecutor); !ok {
+		t.Fatalf("expected LinuxSandboxExecutor, got %T", exec)
+	}
+
+	target := filepath.Join(dir, "allowed.txt")
+	result, meta := exec.Run("echo ok > " + target + " && cat " + target)
+
+	if !result.Success {
+		t.Fatalf("expected success, got: %v, output: %s", result.Error, result.Output)
+	}
+	if !meta.Sandboxed {
+		t.Error("expected meta.Sandboxed to be true")
+	}
+	if !strings.Contains(result.Output, "ok") {
+		t.Errorf("expected output to contain 'ok', got: %s", result.Output)
+	}
+}
+
+func TestLinuxSandboxExecutorWriteOutsideBlocked(t *testing.T) {
+	if !sandboxAvailable() {
+		t.Skip("landlock not available")
+	}
+
+	allowed := t.TempDir()
+	blocked := t.TempDir()
+	cfg := DefaultSandboxConfig()
+	cfg.WritePaths = []string{allowed}
+	exec := NewShellExecutor(cfg)
+
+	target := filepath.Join(blocked, "blocked.txt")
+	result, meta := exec.Run("touch " + target)
+
+	if result.Success {
+		t.Fatal("expected write outside WritePaths to fail")
+	}
+	if !meta.SandboxError {
+		t.Errorf("expected SandboxError, got meta %+v, output: %s", meta, result.Output)
+	}
+	if meta.SandboxReason == "" {
+		t.Error("expected SandboxReason to be set")
+	}
+	if _, err := os.Stat(target); err == nil {
+		t.Fatal("expected file not to be created")
+	}
+}
+
+func TestLinuxSandboxExecutorBlocksNetwork(t *testing.T) {
+	if !sandboxAvailable() {
+		t.Skip("landlock not available")
+	}
+	if netNamespaceAttr() == nil && landlockABI() < 4 {
+		t.Skip("no network isolation available")
+	}
+	if _, err := osexec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found in PATH")
+	}
+
+	cfg := DefaultSandboxConfig()
+	cfg.AllowNetwork = false
+	exec := NewShellExecutor(cfg)
+
+	result, meta := exec.Run(`python3 -c "import socket; socket.create_connection(('1.1.1.1', 80), timeout=2)" 2>&1`)
+
+	if result.Success {
+		t.Fatalf("expected network connection to fail, output: %s", result.Output)
+	}
+	if !meta.Sandboxed {
+		t.Error("expected meta.Sandboxed to be true")
+	}
+}
+
+func TestSandboxExecutorConfigRecognizesLinuxExecutor(t *testing.T) {
+	cfg := SandboxConfig{FallbackOutsideSandbox: true}
+	got, ok := sandboxExecutorConfig(&LinuxSandboxExecutor{config: cfg})
+	if !ok {
+		t.Fatal("expected LinuxSandboxExecutor to count as sandboxed")
+	}
+	if !got.FallbackOutsideSandbox {
+		t.Error("expected config to be returned")
+	}
+	if _, ok := sandboxExecutorConfig(&PassthroughExecutor{}); ok {
+		t.Error("expected PassthroughExecutor not to count as sandboxed")
+	}
+}
+
+func TestIsLinuxSandboxDenial(t *testing.T) {
+	writable := t.TempDir()
+	blocked := t.TempDir()
+	cfg := SandboxConfig{WritePaths: []string{writable}}
+	online := cfg
+	online.AllowNetwork = true
+
+	tests := []struct {
+		name   string
+		output string
+		cfg    SandboxConfig
+		want   bool
+	}{
+		{"write outside write paths", "touch: cannot touch '" + blocked + "/x': Permission denied", cfg, true},
+		{"write inside write paths", "sh: 1: cannot create " + writable + "/x: Permission denied", cfg, false},
+		{"denial without a path", "Permission denied", cfg, true},
+		{"network blocked", "curl: (6) Could not resolve host: example.com", cfg, true},
+		{"network allowed", "curl: (6) Could not resolve host: example.com", online, false},
+		{"ordinary failure", "grep: no match", cfg, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLinuxSandboxDenial(tt.output, tt.cfg); got != tt.want {
+				t.Errorf("isLinuxSandboxDenial(%q) = %v, want %v", tt.output, got, tt.want)
+			}
+		})
+	}
+}
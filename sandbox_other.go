@@ -0,0 +1,46 @@
+//go:build !linux
+
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"runtime"
+)
+
+// landlockABI reports no Landlock support outside Linux.
+func landlockABI() int {
+	return 0
+}
+
+// Run reports a sandbox error; LinuxSandboxExecutor is only usable on Linux.
+func (s *LinuxSandboxExecutor) Run(command string) (ToolResult, ExecMeta) {
+	return s.RunContext(context.Background(), command)
+}
+
+// RunContext reports a sandbox error; LinuxSandboxExecutor is only usable on Linux.
+func (s *LinuxSandboxExecutor) RunContext(ctx context.Context, command string) (ToolResult, ExecMeta) {
+	return s.RunStream(ctx, command, nil)
+}
+
+// RunStream reports a sandbox error; LinuxSandboxExecutor is only usable on Linux.
+func (s *LinuxSandboxExecutor) RunStream(ctx context.Context, command string, onOutput ShellOutputFunc) (ToolResult, ExecMeta) {
+	meta := ExecMeta{
+		Sandboxed:     true,
+		SandboxError:  true,
+		SandboxReason: "linux sandbox unavailable on " + runtime.GOOS,
+	}
+	return ToolResult{
+		Success:  false,
+		Error:    errors.New(meta.SandboxReason),
+		Status:   "sandbox blocked",
+		ExecMeta: &meta,
+	}, meta
+}
+
+// startCommand reports an error; LinuxSandboxExecutor is only usable on Linux.
+func (s *LinuxSandboxExecutor) startCommand(ctx context.Context, command string, stdin io.Reader, stdout, stderr io.Writer) (*exec.Cmd, error) {
+	return nil, errors.New("linux sandbox unavailable on " + runtime.GOOS)
+}
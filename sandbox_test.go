@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -139,6 +140,35 @@ func TestPassthroughExecutor(t *testing.T) {
 	}
 }
 
+// runOnlyExecutor implements only the required ShellExecutor method.
+type runOnlyExecutor struct{ commands []string }
+
+func (e *runOnlyExecutor) Run(command string) (ToolResult, ExecMeta) {
+	e.commands = append(e.commands, command)
+	return ToolResult{Success: true, Output: "ran " + command}, ExecMeta{}
+}
+
+func TestExecuteShellContextRunOnlyExecutor(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	exec := &runOnlyExecutor{}
+	defaultExecutor = exec
+
+	result := ExecuteShellContext(context.Background(), "echo hi")
+	if !result.Success || result.Output != "ran echo hi" {
+		t.Fatalf("result = %+v, want the executor's Run result", result)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := ExecuteShellContext(ctx, "echo late"); result.Success {
+		t.Fatalf("expected a cancelled context to skip the command, got %+v", result)
+	}
+	if len(exec.commands) != 1 {
+		t.Errorf("commands run = %q, want only the first", exec.commands)
+	}
+}
+
 func TestSandboxedExecutorTimeout(t *testing.T) {
 	if !sandboxAvailable() {
 		t.Skip("sandbox-exec not available")
@@ -184,6 +214,31 @@ func TestPassthroughExecutorTimeout(t *testing.T) {
 	if meta.Sandboxed {
 		t.Fatal("expected non-sandboxed meta on passthrough timeout")
 	}
+	if !meta.TimedOut || meta.Signal != "killed" || meta.ExitCode != -1 {
+		t.Fatalf("expected timed-out SIGKILL meta, got %+v", meta)
+	}
+}
+
+func TestPassthroughExecutorExitMeta(t *testing.T) {
+	exec := &PassthroughExecutor{}
+
+	result, meta := exec.Run("echo out; echo err >&2; exit 3")
+
+	if result.Success {
+		t.Fatal("expected non-zero exit to fail")
+	}
+	if meta.ExitCode != 3 || meta.Signal != "" || meta.TimedOut {
+		t.Fatalf("unexpected exit meta: %+v", meta)
+	}
+	if meta.Stdout != "out\n" || meta.Stderr != "err\n" {
+		t.Fatalf("stdout = %q, stderr = %q", meta.Stdout, meta.Stderr)
+	}
+	if meta.Duration <= 0 {
+		t.Fatalf("expected positive duration, got %v", meta.Duration)
+	}
+	if result.ExecMeta == nil || result.ExecMeta.ExitCode != 3 {
+		t.Fatalf("result meta = %+v", result.ExecMeta)
+	}
 }
 
 func TestIsSandboxEnabled(t *testing.T) {
@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultSessionDir is the directory template used by NewFileSessionStore when
+// none is given. It supports the same ~ and {cwd} expansion as PersistHook.
+const DefaultSessionDir = "~/.bono/{cwd}/sessions"
+
+// ErrSessionNotFound is returned when a session ID has no stored session.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is a snapshot of an agent conversation that can be saved and resumed.
+type Session struct {
+	ID                  string            `json:"id"`
+	CWD                 string            `json:"cwd,omitempty"`
+	Model               string            `json:"model,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+	TotalCost           float64           `json:"total_cost"`
+	PreTasksDone        bool              `json:"pretasks_done"`
+	SubAgentOutputPaths map[string]string `json:"subagent_output_paths,omitempty"`
+	Messages            []Message         `json:"-"` // stored one per line after the header
+}
+
+// SessionInfo summarizes a stored session for listing.
+type SessionInfo struct {
+	ID           string
+	UpdatedAt    time.Time
+	MessageCount int
+	FirstInput   string // first user message, for display
+}
+
+// SessionStore saves and loads agent sessions.
+type SessionStore interface {
+	Save(s *Session) error
+	Load(id string) (*Session, error)
+	List() ([]SessionInfo, error) // most recently updated first
+}
+
+// FileSessionStore stores each session as <dir>/<id>.jsonl: a header line with
+// session metadata followed by one line per message.
+type FileSessionStore struct {
+	dir string
+}
+
+// NewFileSessionStore returns a JSONL session store rooted at dirTemplate.
+// Empty uses DefaultSessionDir. ~ and {cwd} are expanded against the current directory.
+func NewFileSessionStore(dirTemplate string) *FileSessionStore {
+	if dirTemplate == "" {
+		dirTemplate = DefaultSessionDir
+	}
+	cwd, _ := os.Getwd()
+	return &FileSessionStore{dir: expandDirTemplate(dirTemplate, cwd)}
+}
+
+// Dir returns the resolved directory sessions are stored in.
+func (s *FileSessionStore) Dir() string {
+	return s.dir
+}
+
+type sessionLine struct {
+	Type    string   `json:"type"` // "session" or "message"
+	Session *Session `json:"session,omitempty"`
+	Message *Message `json:"message,omitempty"`
+}
+
+// Save writes the session, replacing any previous copy atomically.
+func (s *FileSessionStore) Save(sess *Session) error {
+	if sess == nil || sess.ID == "" {
+		return fmt.Errorf("session store: missing session ID")
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("session store: mkdir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, "."+sess.ID+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("session store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(sessionLine{Type: "session", Session: sess}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("session store: encode: %w", err)
+	}
+	for i := range sess.Messages {
+		if err := enc.Encode(sessionLine{Type: "message", Message: &sess.Messages[i]}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("session store: encode: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("session store: write: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("session store: write: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(sess.ID)); err != nil {
+		return fmt.Errorf("session store: rename: %w", err)
+	}
+	return nil
+}
+
+// Load reads a session by ID.
+func (s *FileSessionStore) Load(id string) (*Session, error) {
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+		}
+		return nil, fmt.Errorf("session store: %w", err)
+	}
+	defer f.Close()
+
+	var sess *Session
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		var line sessionLine
+		if err := dec.Decode(&line); err != nil {
+			return nil, fmt.Errorf("session store: decode %s: %w", id, err)
+		}
+		switch line.Type {
+		case "session":
+			sess = line.Session
+		case "message":
+			if sess != nil && line.Message != nil {
+				sess.Messages = append(sess.Messages, *line.Message)
+			}
+		}
+	}
+	if sess == nil {
+		return nil, fmt.Errorf("session store: %s: missing header", id)
+	}
+	return sess, nil
+}
+
+// List returns stored sessions, most recently updated first.
+func (s *FileSessionStore) List() ([]SessionInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("session store: %w", err)
+	}
+
+	var infos []SessionInfo
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		sess, err := s.Load(strings.TrimSuffix(name, ".jsonl"))
+		if err != nil {
+			continue
+		}
+		info := SessionInfo{ID: sess.ID, UpdatedAt: sess.UpdatedAt, MessageCount: len(sess.Messages)}
+		for i := range sess.Messages {
+			if sess.Messages[i].Role == "user" {
+				info.FirstInput = messageContent(&sess.Messages[i])
+				break
+			}
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].UpdatedAt.After(infos[j].UpdatedAt) })
+	return infos, nil
+}
+
+func (s *FileSessionStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".jsonl")
+}
+
+// expandDirTemplate resolves a leading ~/ to the home directory and replaces
+// {cwd} with cwd (leading slash stripped so it nests under the base dir).
+func expandDirTemplate(dir, cwd string) string {
+	// Expand ~ to home directory.
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+
+	// Expand {cwd} placeholder.
+	if strings.Contains(dir, "{cwd}") {
+		// Strip leading slash so path nests cleanly under the base dir.
+		cwdClean := strings.TrimPrefix(cwd, "/")
+		dir = strings.ReplaceAll(dir, "{cwd}", cwdClean)
+	}
+
+	return dir
+}
+
+// newSessionID returns a sortable, collision-resistant session identifier.
+func newSessionID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(b[:]))
+}
+
+// SessionID returns the identifier of the agent's current session.
+func (a *Agent) SessionID() string {
+	return a.sessionID
+}
+
+// Session returns a snapshot of the current conversation for persistence.
+func (a *Agent) Session() *Session {
+	cwd, _ := os.Getwd()
+	sess := &Session{
+		ID:           a.sessionID,
+		CWD:          cwd,
+		Model:        a.config.Model,
+		CreatedAt:    a.sessionCreatedAt,
+		UpdatedAt:    time.Now(),
+		TotalCost:    a.client.TotalCost(),
+		PreTasksDone: a.preTasksDone,
+		Messages:     append([]Message(nil), a.msgs...),
+	}
+	if len(a.subAgentLastOutputPath) > 0 {
+		sess.SubAgentOutputPaths = make(map[string]string, len(a.subAgentLastOutputPath))
+		for k, v := range a.subAgentLastOutputPath {
+			sess.SubAgentOutputPaths[k] = v
+		}
+	}
+	return sess
+}
+
+// SaveSession writes the current conversation to the configured SessionStore.
+func (a *Agent) SaveSession() error {
+	if a.config.SessionStore == nil {
+		return fmt.Errorf("no session store configured")
+	}
+	return a.config.SessionStore.Save(a.Session())
+}
+
+// autoSaveSession saves after each round when a store is configured.
+// Failures are logged rather than interrupting the conversation.
+func (a *Agent) autoSaveSession() {
+	if a.config.SessionStore == nil {
+		return
+	}
+	if err := a.SaveSession(); err != nil {
+		log.Printf("session %s save error: %v", a.sessionID, err)
+	}
+}
+
+// newSession starts a fresh session identity.
+func (a *Agent) newSession() {
+	a.sessionID = newSessionID()
+	a.sessionCreatedAt = time.Now()
+}
+
+// restoreSession loads a saved conversation into the agent.
+func (a *Agent) restoreSession(sess *Session) {
+	a.sessionID = sess.ID
+	if a.sessionID == "" {
+		a.sessionID = newSessionID()
+	}
+	a.sessionCreatedAt = sess.CreatedAt
+	a.msgs = append([]Message(nil), sess.Messages...)
+	a.preTasksDone = sess.PreTasksDone
+	a.client.SetTotalCost(sess.TotalCost)
+	if len(sess.SubAgentOutputPaths) > 0 {
+		a.subAgentLastOutputPath = make(map[string]string, len(sess.SubAgentOutputPaths))
+		for k, v := range sess.SubAgentOutputPaths {
+			a.subAgentLastOutputPath[k] = v
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileSessionStoreRoundTrip(t *testing.T) {
+	store := NewFileSessionStore(t.TempDir())
+
+	sess := &Session{
+		ID:                  "s1",
+		Model:               "test/model",
+		CreatedAt:           time.Now().Add(-time.Hour).UTC(),
+		UpdatedAt:           time.Now().UTC(),
+		TotalCost:           1.25,
+		PreTasksDone:        true,
+		SubAgentOutputPaths: map[string]string{"plan": "/tmp/plan.md"},
+		Messages: []Message{
+			{Role: "system", Content: "sys"},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "c1", Type: "function", Function: FunctionCall{Name: "read_file", Arguments: `{"path":"a"}`}}}},
+			{Role: "tool", ToolCallID: "c1", Content: "contents"},
+		},
+	}
+	if err := store.Save(sess); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := store.Load("s1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got.TotalCost != 1.25 || !got.PreTasksDone || got.Model != "test/model" {
+		t.Fatalf("metadata not restored: %+v", got)
+	}
+	if got.SubAgentOutputPaths["plan"] != "/tmp/plan.md" {
+		t.Fatalf("subagent output paths not restored: %v", got.SubAgentOutputPaths)
+	}
+	if len(got.Messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(got.Messages))
+	}
+	if got.Messages[2].ToolCalls[0].Function.Name != "read_file" || got.Messages[3].ToolCallID != "c1" {
+		t.Fatalf("tool call messages not restored: %+v", got.Messages[2:])
+	}
+}
+
+func TestFileSessionStoreLoadMissing(t *testing.T) {
+	store := NewFileSessionStore(t.TempDir())
+	if _, err := store.Load("nope"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestFileSessionStoreListNewestFirst(t *testing.T) {
+	store := NewFileSessionStore(t.TempDir())
+	now := time.Now()
+	store.Save(&Session{ID: "old", UpdatedAt: now.Add(-time.Hour), Messages: []Message{{Role: "user", Content: "first"}}})
+	store.Save(&Session{ID: "new", UpdatedAt: now, Messages: []Message{{Role: "user", Content: "second"}}})
+
+	infos, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(infos) != 2 || infos[0].ID != "new" || infos[1].ID != "old" {
+		t.Fatalf("unexpected order: %+v", infos)
+	}
+	if infos[0].FirstInput != "second" || infos[0].MessageCount != 1 {
+		t.Fatalf("unexpected info: %+v", infos[0])
+	}
+}
+
+func TestNewFileSessionStoreExpandsCwd(t *testing.T) {
+	base := t.TempDir()
+	store := NewFileSessionStore(filepath.Join(base, "{cwd}", "sessions"))
+	if store.Dir() == filepath.Join(base, "{cwd}", "sessions") {
+		t.Fatalf("expected {cwd} to be expanded, got %s", store.Dir())
+	}
+}
+
+func TestAgentSessionSnapshotAndRestore(t *testing.T) {
+	src := &Agent{client: &Client{}}
+	src.newSession()
+	src.msgs = []Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "hi"}}
+	src.preTasksDone = true
+	src.client.SetTotalCost(0.5)
+	src.subAgentLastOutputPath = map[string]string{"plan": "/tmp/p.md"}
+
+	snap := src.Session()
+
+	dst := &Agent{client: &Client{}}
+	dst.restoreSession(snap)
+
+	if dst.SessionID() != src.SessionID() {
+		t.Fatalf("session ID = %q, want %q", dst.SessionID(), src.SessionID())
+	}
+	if len(dst.Messages()) != 2 || !dst.preTasksDone {
+		t.Fatalf("conversation state not restored: %+v", dst.Messages())
+	}
+	if dst.client.TotalCost() != 0.5 {
+		t.Fatalf("total cost = %v, want 0.5", dst.client.TotalCost())
+	}
+	if dst.subAgentLastOutputPath["plan"] != "/tmp/p.md" {
+		t.Fatalf("subagent output paths not restored: %v", dst.subAgentLastOutputPath)
+	}
+
+	// Snapshot must not alias the live history.
+	src.msgs[1].Content = "changed"
+	if snap.Messages[1].Content != "hi" {
+		t.Fatal("snapshot shares message storage with agent")
+	}
+}
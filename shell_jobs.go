@@ -0,0 +1,295 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxJobOutput is how much of a background job's output is kept for reading;
+// older output is dropped once the buffer reaches twice this size.
+const maxJobOutput = 1 << 20
+
+// maxJobWait caps how long shell_job_output waits for new output.
+const maxJobWait = 30 * time.Second
+
+// jobKillTimeout is how long kill waits for a job to exit after SIGKILL.
+const jobKillTimeout = 5 * time.Second
+
+// jobOutput buffers a job's combined output and remembers how far it has
+// been read, so each read returns only what is new.
+type jobOutput struct {
+	mu      sync.Mutex
+	buf     []byte
+	dropped int64         // bytes discarded from the front of buf
+	read    int64         // offset of the first unread byte, counting dropped bytes
+	notify  chan struct{} // closed on the next write
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > 2*maxJobOutput {
+		over := len(o.buf) - maxJobOutput
+		o.buf = append([]byte(nil), o.buf[over:]...)
+		o.dropped += int64(over)
+	}
+	if o.notify != nil {
+		close(o.notify)
+		o.notify = nil
+	}
+	return len(p), nil
+}
+
+// next returns the output not read yet and how many unread bytes were
+// dropped before it could be read.
+func (o *jobOutput) next() (text string, skipped int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	start := max(o.read, o.dropped)
+	skipped = start - o.read
+	text = string(o.buf[start-o.dropped:])
+	o.read = o.dropped + int64(len(o.buf))
+	return text, skipped
+}
+
+// pending reports whether there is unread output. The returned channel is
+// closed at the next write.
+func (o *jobOutput) pending() (bool, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.notify == nil {
+		o.notify = make(chan struct{})
+	}
+	return o.dropped+int64(len(o.buf)) > o.read, o.notify
+}
+
+// shellJob is a command started in the background by run_shell.
+type shellJob struct {
+	id      string
+	command string
+	pid     int
+	meta    ExecMeta
+	started time.Time
+	cancel  context.CancelFunc
+	out     jobOutput
+	killed  atomic.Bool
+	done    chan struct{}
+	err     error     // result of Wait; set before done is closed
+	ended   time.Time // set before done is closed
+}
+
+func (j *shellJob) running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// state describes the job for the model, e.g. "running for 3.2s".
+func (j *shellJob) state() string {
+	if j.running() {
+		return "running for " + time.Since(j.started).Round(100*time.Millisecond).String()
+	}
+	took := j.ended.Sub(j.started).Round(100 * time.Millisecond)
+	var exitErr *exec.ExitError
+	switch {
+	case j.killed.Load():
+		return fmt.Sprintf("killed after %s", took)
+	case j.err == nil:
+		return fmt.Sprintf("exited with code 0 after %s", took)
+	case errors.As(j.err, &exitErr):
+		return fmt.Sprintf("exited with code %d after %s", exitErr.ExitCode(), took)
+	default:
+		return fmt.Sprintf("failed after %s: %v", took, j.err)
+	}
+}
+
+// shellJobs tracks an agent's background shell jobs. Jobs outlive the tool
+// call that started them and are killed by closeAll.
+type shellJobs struct {
+	mu   sync.Mutex
+	jobs []*shellJob
+}
+
+// start launches req.Command without waiting for it, routed like a
+// foreground command: sandboxed unless policy sends it to the host. A job
+// that fails inside the sandbox is not retried on the host.
+func (m *shellJobs) start(req ShellRequest, policy ShellPolicy) ToolResult {
+	executor := GetExecutor()
+	switch decision := DecideShellRequest(policy, req); {
+	case decision.Route == ShellRouteDeny:
+		return deniedResult(decision)
+	case !IsSandboxEnabled() || decision.Route == ShellRouteHostDirect:
+		executor = &PassthroughExecutor{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &shellJob{command: req.Command, started: time.Now(), cancel: cancel, done: make(chan struct{})}
+	cmd, meta, err := startShellCommand(ctx, executor, req.Command, nil, &j.out)
+	if err != nil {
+		cancel()
+		return ToolResult{
+			Success:  false,
+			Output:   "failed to start background job: " + err.Error(),
+			Error:    err,
+			Status:   "fail: " + err.Error(),
+			ExecMeta: &meta,
+		}
+	}
+	j.pid, j.meta = cmd.Process.Pid, meta
+
+	m.mu.Lock()
+	j.id = fmt.Sprintf("job-%d", len(m.jobs)+1)
+	m.jobs = append(m.jobs, j)
+	m.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		j.err, j.ended = err, time.Now()
+		cancel()
+		close(j.done)
+	}()
+
+	return ToolResult{
+		Success:  true,
+		Output:   fmt.Sprintf("Started background job %s (pid %d). Read its output with shell_job_output, check it with shell_job_status and stop it with shell_job_kill.", j.id, j.pid),
+		Status:   "started " + j.id,
+		ExecMeta: &meta,
+	}
+}
+
+func (m *shellJobs) get(id string) (*shellJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.id == id {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrJobNotFound, id)
+}
+
+// output returns the job's output since the previous read. When there is
+// none yet, it waits up to wait for output or for the job to exit.
+func (m *shellJobs) output(ctx context.Context, id string, wait time.Duration) ToolResult {
+	j, err := m.get(id)
+	if err != nil {
+		return jobFailure(err)
+	}
+	if wait > 0 {
+		wait = min(wait, maxJobWait)
+		if ready, changed := j.out.pending(); !ready && j.running() {
+			timer := time.NewTimer(wait)
+			select {
+			case <-changed:
+			case <-j.done:
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			timer.Stop()
+		}
+	}
+
+	text, skipped := j.out.next()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s %s]\n", j.id, j.state())
+	if skipped > 0 {
+		fmt.Fprintf(&sb, "[... %d bytes of output dropped before they were read ...]\n", skipped)
+	}
+	if text == "" {
+		sb.WriteString("(no new output)")
+	} else {
+		sb.WriteString(text)
+	}
+	return ToolResult{
+		Success: true,
+		Output:  sb.String(),
+		Status:  j.id + " " + j.state(),
+	}
+}
+
+// status describes one job, or every job when id is empty.
+func (m *shellJobs) status(id string) ToolResult {
+	var jobs []*shellJob
+	if id == "" {
+		m.mu.Lock()
+		jobs = append(jobs, m.jobs...)
+		m.mu.Unlock()
+	} else {
+		j, err := m.get(id)
+		if err != nil {
+			return jobFailure(err)
+		}
+		jobs = []*shellJob{j}
+	}
+	if len(jobs) == 0 {
+		return ToolResult{Success: true, Output: "No background jobs.", Status: "no jobs"}
+	}
+
+	lines := make([]string, len(jobs))
+	running := 0
+	for i, j := range jobs {
+		if j.running() {
+			running++
+		}
+		lines[i] = fmt.Sprintf("%s (pid %d) %s: %s", j.id, j.pid, j.state(), j.command)
+	}
+	return ToolResult{
+		Success: true,
+		Output:  strings.Join(lines, "\n"),
+		Status:  fmt.Sprintf("%d running", running),
+	}
+}
+
+// kill stops a job's whole process group and waits for it to exit.
+func (m *shellJobs) kill(id string) ToolResult {
+	j, err := m.get(id)
+	if err != nil {
+		return jobFailure(err)
+	}
+	if !j.running() {
+		return ToolResult{Success: true, Output: fmt.Sprintf("%s already %s", j.id, j.state()), Status: "not running"}
+	}
+	j.stop()
+	return ToolResult{Success: true, Output: fmt.Sprintf("%s %s", j.id, j.state()), Status: "killed " + j.id}
+}
+
+// closeAll kills every running job.
+func (m *shellJobs) closeAll() {
+	m.mu.Lock()
+	jobs := append([]*shellJob(nil), m.jobs...)
+	m.mu.Unlock()
+	for _, j := range jobs {
+		if j.running() {
+			j.stop()
+		}
+	}
+}
+
+func (j *shellJob) stop() {
+	j.killed.Store(true)
+	j.cancel()
+	select {
+	case <-j.done:
+	case <-time.After(jobKillTimeout):
+	}
+}
+
+// jobFailure reports a shell job tool error; Output carries it for the model.
+func jobFailure(err error) ToolResult {
+	return ToolResult{
+		Success: false,
+		Output:  err.Error(),
+		Error:   err,
+		Status:  "fail: " + err.Error(),
+	}
+}
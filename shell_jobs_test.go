@@ -0,0 +1,80 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellJobsLifecycle(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	jobs := &shellJobs{}
+	t.Cleanup(jobs.closeAll)
+	ctx := context.Background()
+
+	res := jobs.start(ShellRequest{ToolName: "run_shell", Command: "echo ready; sleep 60"}, nil)
+	if !res.Success || !strings.Contains(res.Output, "job-1") {
+		t.Fatalf("start = %+v", res)
+	}
+
+	out := jobs.output(ctx, "job-1", 5*time.Second)
+	if !strings.Contains(out.Output, "ready") || !strings.Contains(out.Output, "running") {
+		t.Fatalf("first read = %q", out.Output)
+	}
+	if out := jobs.output(ctx, "job-1", 0); !strings.Contains(out.Output, "(no new output)") {
+		t.Fatalf("second read = %q", out.Output)
+	}
+
+	jobs.start(ShellRequest{ToolName: "run_shell", Command: "exit 3"}, nil)
+	j, _ := jobs.get("job-2")
+	<-j.done
+	status := jobs.status("")
+	if !strings.Contains(status.Output, "job-1 (pid") || !strings.Contains(status.Output, "job-2") ||
+		!strings.Contains(status.Output, "exited with code 3") || status.Status != "1 running" {
+		t.Fatalf("status = %+v", status)
+	}
+
+	if res := jobs.kill("job-1"); !res.Success || !strings.Contains(res.Output, "killed") {
+		t.Fatalf("kill = %+v", res)
+	}
+	if res := jobs.kill("job-1"); !strings.Contains(res.Output, "already killed") {
+		t.Fatalf("second kill = %q", res.Output)
+	}
+	if res := jobs.output(ctx, "job-9", 0); !errors.Is(res.Error, ErrJobNotFound) {
+		t.Fatalf("expected ErrJobNotFound, got %v", res.Error)
+	}
+}
+
+func TestAgentCloseKillsShellJobs(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	a := &Agent{jobs: &shellJobs{}}
+	a.jobs.start(ShellRequest{ToolName: "run_shell", Command: "sleep 60"}, nil)
+	j, _ := a.jobs.get("job-1")
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if j.running() {
+		t.Fatal("job still running after Close")
+	}
+}
+
+func TestJobOutputDropsUnreadOverflow(t *testing.T) {
+	var o jobOutput
+	o.Write([]byte("abc"))
+	if text, skipped := o.next(); text != "abc" || skipped != 0 {
+		t.Fatalf("next = %q, %d", text, skipped)
+	}
+	o.Write([]byte(strings.Repeat("x", 2*maxJobOutput+1)))
+	text, skipped := o.next()
+	if len(text) != maxJobOutput || skipped != maxJobOutput+1 {
+		t.Fatalf("len(text) = %d, skipped = %d", len(text), skipped)
+	}
+}
@@ -0,0 +1,361 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/bash"
+)
+
+// maxShellParseDepth bounds how deeply sh -c and eval scripts are parsed.
+const maxShellParseDepth = 8
+
+// errShellSyntax reports a command line the parser could only partly read.
+var errShellSyntax = errors.New("shell syntax error")
+
+// ShellCommand is one simple command in a shell command line.
+type ShellCommand struct {
+	Argv      []string          // command name and arguments, with quotes removed
+	Env       map[string]string // VAR=value assignments before the command
+	Redirects []ShellRedirect
+}
+
+// ShellRedirect is a redirection applied to a simple command.
+type ShellRedirect struct {
+	Op     string // operator with its descriptor, e.g. ">", "2>>", "<<", "<<<"
+	Target string // file, descriptor, here-document delimiter or here-string
+}
+
+// Name returns the command's base name, e.g. "curl" for /usr/bin/curl.
+func (c ShellCommand) Name() string {
+	if len(c.Argv) == 0 {
+		return ""
+	}
+	return filepath.Base(c.Argv[0])
+}
+
+// HasPrefix reports whether argv starts with words, ignoring case. The
+// first word is compared with Name, and "*" matches any word.
+func (c ShellCommand) HasPrefix(words ...string) bool {
+	if len(words) == 0 || len(c.Argv) < len(words) {
+		return false
+	}
+	for i, word := range words {
+		arg := c.Argv[i]
+		if i == 0 {
+			arg = c.Name()
+		}
+		if word != "*" && !strings.EqualFold(arg, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// OutputFiles returns the files the command's output is redirected to.
+func (c ShellCommand) OutputFiles() []string {
+	var files []string
+	for _, r := range c.Redirects {
+		if !strings.Contains(r.Op, ">") || (strings.HasSuffix(r.Op, "&") && !strings.HasPrefix(r.Op, "&")) {
+			continue // input, or a descriptor duplication such as 2>&1
+		}
+		files = append(files, r.Target)
+	}
+	return files
+}
+
+// ParseShellCommands returns every simple command in command: those in
+// pipelines, lists, subshells, blocks and command or process substitutions,
+// scripts passed to sh -c and eval, and commands run through wrappers such
+// as env, sudo, nohup, time and xargs. A wrapped command is returned both
+// as written and unwrapped. The parser recovers from syntax errors; the
+// commands it could read are returned along with the error.
+func ParseShellCommands(command string) ([]ShellCommand, error) {
+	return parseShellCommands(command, 0)
+}
+
+func parseShellCommands(command string, depth int) ([]ShellCommand, error) {
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(bash.GetLanguage())
+
+	src := []byte(command)
+	tree, err := parser.ParseCtx(context.Background(), nil, src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	p := &shellParser{src: src, depth: depth}
+	root := tree.RootNode()
+	p.walk(root)
+	if root.HasError() && p.err == nil {
+		p.err = errShellSyntax
+	}
+	return p.cmds, p.err
+}
+
+// shellParser collects simple commands from a bash syntax tree.
+type shellParser struct {
+	src    []byte
+	depth  int
+	cmds   []ShellCommand
+	starts []uint32 // start offset of each command's node; nested scripts use their caller's
+	err    error
+}
+
+func (p *shellParser) walk(n *sitter.Node) {
+	switch n.Type() {
+	case "command":
+		p.command(n)
+		return
+	case "redirected_statement":
+		p.redirected(n)
+		return
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		p.walk(n.NamedChild(i))
+	}
+}
+
+// command records a simple command, then the commands nested in its words.
+func (p *shellParser) command(n *sitter.Node) {
+	var cmd ShellCommand
+	var nested []*sitter.Node
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		switch child.Type() {
+		case "variable_assignment":
+			cmd.setEnv(p.assignment(child))
+		case "command_name":
+			cmd.Argv = append(cmd.Argv, p.word(child.NamedChild(0)))
+		case "file_redirect", "heredoc_redirect", "herestring_redirect":
+			cmd.Redirects = append(cmd.Redirects, p.redirect(child))
+		default:
+			cmd.Argv = append(cmd.Argv, p.word(child))
+		}
+		nested = append(nested, child)
+	}
+	p.add(cmd, n.StartByte())
+	for _, child := range nested {
+		p.walk(child)
+	}
+}
+
+// add records cmd, then whatever it runs through a wrapper or sh -c.
+func (p *shellParser) add(cmd ShellCommand, start uint32) {
+	p.cmds = append(p.cmds, cmd)
+	p.starts = append(p.starts, start)
+
+	if script, ok := shellScriptArg(cmd); ok && p.depth < maxShellParseDepth {
+		inner, err := parseShellCommands(script, p.depth+1)
+		if err != nil && p.err == nil {
+			p.err = err
+		}
+		for _, c := range inner {
+			p.cmds = append(p.cmds, c)
+			p.starts = append(p.starts, start)
+		}
+		return
+	}
+	if inner, ok := unwrapShellCommand(cmd); ok {
+		p.add(inner, start)
+	}
+}
+
+// redirected attaches a statement's redirections to the commands they apply
+// to: the last command of a list or pipeline, or every command in a
+// subshell, block or loop.
+func (p *shellParser) redirected(n *sitter.Node) {
+	first := len(p.cmds)
+	var redirects []ShellRedirect
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		switch child.Type() {
+		case "file_redirect", "heredoc_redirect", "herestring_redirect":
+			redirects = append(redirects, p.redirect(child))
+		}
+		p.walk(child)
+	}
+
+	target := n.ChildByFieldName("body")
+	for target != nil && (target.Type() == "list" || target.Type() == "pipeline") && target.NamedChildCount() > 0 {
+		target = target.NamedChild(int(target.NamedChildCount()) - 1)
+	}
+	if target == nil {
+		return
+	}
+	for i := first; i < len(p.cmds); i++ {
+		start := p.starts[i]
+		if target.Type() == "command" && start != target.StartByte() {
+			continue
+		}
+		if start >= target.StartByte() && start < target.EndByte() {
+			p.cmds[i].Redirects = append(p.cmds[i].Redirects, redirects...)
+		}
+	}
+}
+
+func (p *shellParser) assignment(n *sitter.Node) (name, value string) {
+	if v := n.ChildByFieldName("name"); v != nil {
+		name = v.Content(p.src)
+	}
+	if v := n.ChildByFieldName("value"); v != nil {
+		value = p.word(v)
+	}
+	return name, value
+}
+
+func (p *shellParser) redirect(n *sitter.Node) ShellRedirect {
+	var r ShellRedirect
+	for i := 0; i < int(n.ChildCount()); i++ {
+		child := n.Child(i)
+		switch {
+		case child.Type() == "heredoc_start":
+			r.Target = unquoteShellWord(child.Content(p.src))
+		case child.Type() == "file_descriptor" || !child.IsNamed():
+			r.Op += child.Content(p.src)
+		case r.Target == "" && child.Type() != "heredoc_body" && child.Type() != "heredoc_end":
+			r.Target = p.word(child)
+		}
+	}
+	return r
+}
+
+// word returns a word's text with quotes and escapes removed. Expansions
+// and substitutions are kept as written.
+func (p *shellParser) word(n *sitter.Node) string {
+	if n == nil {
+		return ""
+	}
+	switch n.Type() {
+	case "concatenation":
+		var b strings.Builder
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			b.WriteString(p.word(n.NamedChild(i)))
+		}
+		return b.String()
+	case "word", "raw_string", "string", "ansi_c_string":
+		return unquoteShellWord(n.Content(p.src))
+	}
+	return n.Content(p.src)
+}
+
+// unquoteShellWord removes the quoting from a single word.
+func unquoteShellWord(s string) string {
+	switch {
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1]
+	case len(s) >= 3 && strings.HasPrefix(s, "$'") && s[len(s)-1] == '\'':
+		return s[2 : len(s)-1]
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		var b strings.Builder
+		inner := s[1 : len(s)-1]
+		for i := 0; i < len(inner); i++ {
+			if inner[i] == '\\' && i+1 < len(inner) && strings.IndexByte("\"\\$`", inner[i+1]) >= 0 {
+				i++
+			}
+			b.WriteByte(inner[i])
+		}
+		return b.String()
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// shellScriptArg returns the script run by sh -c (or another shell) or eval.
+func shellScriptArg(cmd ShellCommand) (string, bool) {
+	switch cmd.Name() {
+	case "eval":
+		return strings.Join(cmd.Argv[1:], " "), len(cmd.Argv) > 1
+	case "sh", "bash", "zsh", "dash", "ksh":
+		for i, arg := range cmd.Argv[1:] {
+			if !strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+				return "", false
+			}
+			if strings.Contains(arg, "c") && i+2 < len(cmd.Argv) {
+				return cmd.Argv[i+2], true
+			}
+		}
+	}
+	return "", false
+}
+
+// shellWrapperFlags lists, for each wrapper command, its options that take
+// a value as the next word.
+var shellWrapperFlags = map[string]string{
+	"env":     "uCS",
+	"sudo":    "ugpChUDrtT",
+	"doas":    "uC",
+	"nice":    "n",
+	"timeout": "sk",
+	"xargs":   "IiLlnPdEsa",
+	"stdbuf":  "ioe",
+	"nohup":   "",
+	"time":    "",
+	"command": "",
+	"exec":    "a",
+	"builtin": "",
+}
+
+// unwrapShellCommand returns the command a wrapper such as env or sudo
+// runs, keeping the wrapper's redirections.
+func unwrapShellCommand(cmd ShellCommand) (ShellCommand, bool) {
+	name := cmd.Name()
+	valueFlags, ok := shellWrapperFlags[name]
+	if !ok {
+		return ShellCommand{}, false
+	}
+	inner := ShellCommand{Redirects: cmd.Redirects}
+	for k, v := range cmd.Env {
+		inner.setEnv(k, v)
+	}
+
+	args := cmd.Argv[1:]
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			args = args[1:]
+			break
+		}
+		if name == "env" && !strings.HasPrefix(arg, "-") && strings.Contains(arg, "=") {
+			k, v, _ := strings.Cut(arg, "=")
+			inner.setEnv(k, v)
+			args = args[1:]
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		args = args[1:]
+		// A value flag consumes the next word unless its value is attached,
+		// as in -n10; long options are assumed to use --opt=value.
+		if !strings.HasPrefix(arg, "--") && len(arg) == 2 && strings.ContainsRune(valueFlags, rune(arg[1])) && len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	if name == "timeout" && len(args) > 0 {
+		args = args[1:] // duration
+	}
+	if len(args) == 0 {
+		return ShellCommand{}, false
+	}
+	inner.Argv = args
+	return inner, true
+}
+
+func (c *ShellCommand) setEnv(name, value string) {
+	if c.Env == nil {
+		c.Env = make(map[string]string)
+	}
+	c.Env[name] = value
+}
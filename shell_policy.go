@@ -1,6 +1,10 @@
 package core
 
-import "strings"
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
 
 // ShellRoute describes how a shell-backed tool should execute.
 type ShellRoute int
@@ -10,6 +14,8 @@ const (
 	ShellRouteSandboxFirst ShellRoute = iota
 	// ShellRouteHostDirect skips the sandbox and should ask for approval first.
 	ShellRouteHostDirect
+	// ShellRouteDeny blocks the command; the decision's reason is shown to the model.
+	ShellRouteDeny
 )
 
 // ShellDecision is the policy result for a shell-backed tool call.
@@ -20,9 +26,24 @@ type ShellDecision struct {
 
 // ShellRequest captures the information needed to route shell-backed tools.
 type ShellRequest struct {
-	ToolName string
-	Command  string
-	Safety   string
+	ToolName   string
+	Command    string
+	Safety     string
+	Background bool            // start as a background job instead of waiting for it
+	OnOutput   ShellOutputFunc // receives output while the command runs; may be nil
+
+	commands []ShellCommand // parsed Command, set by RuleBasedShellPolicy
+	parsed   bool
+}
+
+// Commands returns the simple commands in Command, as ParseShellCommands
+// finds them.
+func (r ShellRequest) Commands() []ShellCommand {
+	if r.parsed {
+		return r.commands
+	}
+	cmds, _ := ParseShellCommands(r.Command)
+	return cmds
 }
 
 // ShellPolicy decides whether a shell-backed tool should run sandboxed first
@@ -32,9 +53,70 @@ type ShellPolicy func(ShellRequest) ShellDecision
 // ShellRule matches a request and optionally returns a routing decision.
 type ShellRule func(ShellRequest) (ShellDecision, bool)
 
+// CommandRule matches one simple command of a request and optionally
+// returns a routing decision.
+type CommandRule func(req ShellRequest, cmd ShellCommand) (ShellDecision, bool)
+
+// CommandPattern matches simple commands. Empty fields match any command.
+type CommandPattern struct {
+	Argv       []string // leading words, compared as by ShellCommand.HasPrefix
+	Env        []string // variables that must be assigned before the command
+	OutputFile string   // filepath.Match pattern for a file output is redirected to
+}
+
+// Match reports whether cmd matches the pattern.
+func (p CommandPattern) Match(cmd ShellCommand) bool {
+	if len(p.Argv) > 0 && !cmd.HasPrefix(p.Argv...) {
+		return false
+	}
+	for _, name := range p.Env {
+		if _, ok := cmd.Env[name]; !ok {
+			return false
+		}
+	}
+	if p.OutputFile != "" {
+		return slices.ContainsFunc(cmd.OutputFiles(), func(file string) bool {
+			ok, _ := filepath.Match(p.OutputFile, file)
+			return ok
+		})
+	}
+	return true
+}
+
+// ShellCommandRule builds a ShellRule that applies rule to each simple
+// command in the request, including those inside lists, pipelines,
+// subshells, substitutions and sh -c scripts, and returns the first match.
+func ShellCommandRule(rule CommandRule) ShellRule {
+	return func(req ShellRequest) (ShellDecision, bool) {
+		for _, cmd := range req.Commands() {
+			if decision, ok := rule(req, cmd); ok {
+				return decision, true
+			}
+		}
+		return ShellDecision{}, false
+	}
+}
+
+// CommandPatternRule builds a ShellRule that returns decision when any
+// simple command in the request matches one of patterns.
+func CommandPatternRule(decision ShellDecision, patterns ...CommandPattern) ShellRule {
+	return ShellCommandRule(func(_ ShellRequest, cmd ShellCommand) (ShellDecision, bool) {
+		for _, p := range patterns {
+			if p.Match(cmd) {
+				return decision, true
+			}
+		}
+		return ShellDecision{}, false
+	})
+}
+
 // RuleBasedShellPolicy builds a ShellPolicy from ordered rules.
 func RuleBasedShellPolicy(rules ...ShellRule) ShellPolicy {
 	return func(req ShellRequest) ShellDecision {
+		if !req.parsed {
+			req.commands, _ = ParseShellCommands(req.Command)
+			req.parsed = true
+		}
 		for _, rule := range rules {
 			if decision, ok := rule(req); ok {
 				return decision
@@ -46,13 +128,17 @@ func RuleBasedShellPolicy(rules ...ShellRule) ShellPolicy {
 
 // DefaultShellPolicy returns the default routing policy for shell-backed tools.
 func DefaultShellPolicy() ShellPolicy {
-	return RuleBasedShellPolicy(
+	return RuleBasedShellPolicy(defaultShellRules()...)
+}
+
+func defaultShellRules() []ShellRule {
+	return []ShellRule{
 		shellSafetyRouteRule("network", "safety marked as network"),
 		shellSafetyRouteRule("privileged", "safety marked as privileged"),
 		packageManagerRouteRule(),
 		gitNetworkRouteRule(),
 		networkTransferRouteRule(),
-	)
+	}
 }
 
 // DecideShellRequest applies the configured policy or the default one.
@@ -71,6 +157,7 @@ func ShellRequestFromToolArgs(toolName string, args map[string]any) ShellRequest
 	switch toolName {
 	case "run_shell":
 		req.Command, _ = args["command"].(string)
+		req.Background, _ = args["background"].(bool)
 	case "python_runtime":
 		code, _ := args["code"].(string)
 		req.Command = pythonCommand(code)
@@ -105,10 +192,25 @@ func packageManagerRouteRule() ShellRule {
 }
 
 func gitNetworkRouteRule() ShellRule {
-	prefixes := []string{
-		"git clone", "git fetch", "git pull", "git push",
-	}
-	return shellPrefixRouteRule(prefixes, "known git network command")
+	subcommands := []string{"clone", "fetch", "pull", "push", "ls-remote", "submodule"}
+	decision := ShellDecision{Route: ShellRouteHostDirect, Reason: "known git network command"}
+	return ShellCommandRule(func(_ ShellRequest, cmd ShellCommand) (ShellDecision, bool) {
+		if cmd.Name() != "git" {
+			return ShellDecision{}, false
+		}
+		// Skip global options such as -C dir and -c key=value.
+		args := cmd.Argv[1:]
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			if (args[0] == "-C" || args[0] == "-c") && len(args) > 1 {
+				args = args[1:]
+			}
+			args = args[1:]
+		}
+		if len(args) > 0 && slices.Contains(subcommands, strings.ToLower(args[0])) {
+			return decision, true
+		}
+		return ShellDecision{}, false
+	})
 }
 
 func networkTransferRouteRule() ShellRule {
@@ -118,14 +220,12 @@ func networkTransferRouteRule() ShellRule {
 	return shellPrefixRouteRule(prefixes, "known network transfer command")
 }
 
+// shellPrefixRouteRule routes to the host any simple command whose leading
+// words match one of prefixes.
 func shellPrefixRouteRule(prefixes []string, reason string) ShellRule {
-	return func(req ShellRequest) (ShellDecision, bool) {
-		cmd := strings.ToLower(strings.TrimSpace(req.Command))
-		for _, prefix := range prefixes {
-			if strings.HasPrefix(cmd, prefix) {
-				return ShellDecision{Route: ShellRouteHostDirect, Reason: reason}, true
-			}
-		}
-		return ShellDecision{}, false
+	patterns := make([]CommandPattern, len(prefixes))
+	for i, prefix := range prefixes {
+		patterns[i] = CommandPattern{Argv: strings.Fields(prefix)}
 	}
+	return CommandPatternRule(ShellDecision{Route: ShellRouteHostDirect, Reason: reason}, patterns...)
 }
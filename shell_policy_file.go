@@ -0,0 +1,220 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Default locations of declarative shell policy files. The project file is
+// relative to the project directory. A project file comes with the
+// repository, so it can only deny or sandbox commands; routing commands to
+// the host is left to the user's file.
+const (
+	ProjectShellPolicyPath = ".bono/policy.json"
+	UserShellPolicyPath    = "~/.bono/policy.json"
+)
+
+// ShellPolicyFile is a declarative shell policy, stored as JSON:
+//
+//	{"rules": [
+//	  {"command": "git push", "action": "deny", "reason": "CI pushes releases"},
+//	  {"command": "make deploy", "action": "host"},
+//	  {"safety": ["destructive"], "paths": ["/etc/**"], "action": "deny"}
+//	]}
+type ShellPolicyFile struct {
+	Rules []ShellPolicyRule `json:"rules"`
+}
+
+// ShellPolicyRule is one rule of a ShellPolicyFile. Every condition set must
+// hold for the rule to match; a rule without conditions matches every
+// request. Command, env and path conditions are checked against each simple
+// command in the request, as ParseShellCommands finds them.
+type ShellPolicyRule struct {
+	Tool    string   `json:"tool,omitempty"`    // run_shell or python_runtime
+	Command string   `json:"command,omitempty"` // leading words of argv; "*" matches any word
+	Env     []string `json:"env,omitempty"`     // variables that must be assigned before the command
+	Safety  []string `json:"safety,omitempty"`  // safety levels, any of which matches
+	Paths   []string `json:"paths,omitempty"`   // globs matched against path arguments and output files; dir/** matches anything under dir
+	Action  string   `json:"action"`            // sandbox, host or deny; host is refused in project files
+	Reason  string   `json:"reason,omitempty"`  // shown to the model when a command is denied
+}
+
+// LoadShellPolicyFile reads a policy file. Unknown fields are rejected so a
+// misspelled condition doesn't silently widen a rule.
+func LoadShellPolicyFile(path string) (ShellPolicyFile, error) {
+	var f ShellPolicyFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return f, fmt.Errorf("parse shell policy %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Compile turns the file's rules into ShellRules, in order. Relative path
+// patterns are resolved against baseDir; source names the file in default
+// reasons and errors.
+func (f ShellPolicyFile) Compile(source, baseDir string) ([]ShellRule, error) {
+	rules := make([]ShellRule, 0, len(f.Rules))
+	for i, r := range f.Rules {
+		rule, err := r.compile(fmt.Sprintf("%s rule %d", source, i+1), baseDir)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadShellPolicy builds the shell policy for a project: rules from the
+// user's policy file, then the project's, then the default rules. The first
+// matching rule decides, so a project file can only tighten routing: the
+// user's rules come first and a project rule routing to the host is an
+// error. Missing files are skipped.
+func LoadShellPolicy(projectDir string) (ShellPolicy, error) {
+	var rules []ShellRule
+	files := []struct {
+		path, baseDir string
+		project       bool
+	}{
+		{expandDirTemplate(UserShellPolicyPath, ""), projectDir, false},
+		{filepath.Join(projectDir, ProjectShellPolicyPath), projectDir, true},
+	}
+	for _, file := range files {
+		f, err := LoadShellPolicyFile(file.path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if file.project {
+			for i, r := range f.Rules {
+				if strings.EqualFold(r.Action, "host") {
+					return nil, fmt.Errorf("%s rule %d: a project policy can only deny or sandbox commands; put host rules in %s", file.path, i+1, UserShellPolicyPath)
+				}
+			}
+		}
+		compiled, err := f.Compile(file.path, file.baseDir)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, compiled...)
+	}
+	return RuleBasedShellPolicy(append(rules, defaultShellRules()...)...), nil
+}
+
+func (r ShellPolicyRule) compile(name, baseDir string) (ShellRule, error) {
+	decision := ShellDecision{Reason: r.Reason}
+	switch strings.ToLower(r.Action) {
+	case "sandbox":
+		decision.Route = ShellRouteSandboxFirst
+	case "host":
+		decision.Route = ShellRouteHostDirect
+	case "deny":
+		decision.Route = ShellRouteDeny
+	default:
+		return nil, fmt.Errorf("%s: unknown action %q (want sandbox, host or deny)", name, r.Action)
+	}
+	if decision.Reason == "" {
+		decision.Reason = "matched " + name
+	}
+
+	pattern := CommandPattern{Argv: strings.Fields(r.Command), Env: r.Env}
+	paths := make([]string, len(r.Paths))
+	for i, p := range r.Paths {
+		p = expandDirTemplate(p, "")
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, p)
+		}
+		if _, err := filepath.Match(strings.TrimSuffix(p, "/**"), ""); err != nil {
+			return nil, fmt.Errorf("%s: path %q: %w", name, r.Paths[i], err)
+		}
+		paths[i] = filepath.Clean(p)
+	}
+	perCommand := len(pattern.Argv) > 0 || len(pattern.Env) > 0 || len(paths) > 0
+
+	return func(req ShellRequest) (ShellDecision, bool) {
+		if r.Tool != "" && r.Tool != req.ToolName {
+			return ShellDecision{}, false
+		}
+		if len(r.Safety) > 0 && !slices.ContainsFunc(r.Safety, func(s string) bool {
+			return strings.EqualFold(s, strings.TrimSpace(req.Safety))
+		}) {
+			return ShellDecision{}, false
+		}
+		if !perCommand {
+			return decision, true
+		}
+		for _, cmd := range req.Commands() {
+			if pattern.Match(cmd) && (len(paths) == 0 || commandTouchesPaths(cmd, paths)) {
+				return decision, true
+			}
+		}
+		return ShellDecision{}, false
+	}, nil
+}
+
+// commandTouchesPaths reports whether any of cmd's path-like arguments or
+// output files matches one of patterns.
+func commandTouchesPaths(cmd ShellCommand, patterns []string) bool {
+	var candidates []string
+	for _, arg := range cmd.Argv[min(1, len(cmd.Argv)):] {
+		if _, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(arg, "--") {
+			arg = value
+		}
+		if arg != "" && !strings.HasPrefix(arg, "-") {
+			candidates = append(candidates, arg)
+		}
+	}
+	candidates = append(candidates, cmd.OutputFiles()...)
+
+	for _, c := range candidates {
+		abs, err := filepath.Abs(expandDirTemplate(c, ""))
+		if err != nil {
+			continue
+		}
+		if real, err := resolveExistingPrefix(abs); err == nil {
+			abs = real
+		}
+		for _, p := range patterns {
+			if matchPathPattern(p, abs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPathPattern matches path against a filepath.Match pattern, where a
+// trailing /** matches the directory and everything under it.
+func matchPathPattern(pattern, path string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/**"); ok {
+		if !strings.ContainsAny(dir, "*?[") {
+			if real, err := resolveExistingPrefix(dir); err == nil {
+				dir = real
+			}
+			return pathWithin(dir, path)
+		}
+		for p := path; ; p = filepath.Dir(p) {
+			if ok, _ := filepath.Match(dir, p); ok {
+				return true
+			}
+			if p == filepath.Dir(p) {
+				return false
+			}
+		}
+	}
+	ok, _ := filepath.Match(pattern, path)
+	return ok
+}
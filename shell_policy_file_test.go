@@ -0,0 +1,118 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadShellPolicyUserThenProjectThenDefaults(t *testing.T) {
+	project, home := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFiles(t, project, map[string]string{
+		ProjectShellPolicyPath: `{"rules": [
+			{"command": "git push", "action": "deny", "reason": "releases are pushed by CI"},
+			{"command": "rm", "paths": ["secrets/**"], "action": "deny"},
+			{"tool": "run_shell", "safety": ["privileged"], "action": "sandbox"}
+		]}`,
+	})
+	writeTestFiles(t, home, map[string]string{
+		".bono/policy.json": `{"rules": [
+			{"command": "git fetch", "action": "host", "reason": "user trusts git fetch"},
+			{"env": ["DEPLOY_TOKEN"], "action": "deny"},
+			{"paths": ["/etc/*"], "action": "deny"}
+		]}`,
+	})
+
+	policy, err := LoadShellPolicy(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command, safety string
+		route           ShellRoute
+		reason          string
+	}{
+		{"cd app && git push origin main", "modify", ShellRouteDeny, "releases are pushed by CI"},
+		{"git fetch", "read-only", ShellRouteHostDirect, "user trusts git fetch"},
+		{"rm -rf " + filepath.Join(project, "secrets", "keys"), "destructive", ShellRouteDeny, ProjectShellPolicyPath + " rule 2"},
+		{"rm -rf build", "destructive", ShellRouteSandboxFirst, ""},
+		{"sudo ls", "privileged", ShellRouteSandboxFirst, ProjectShellPolicyPath + " rule 3"},
+		{"DEPLOY_TOKEN=x ./deploy.sh", "modify", ShellRouteDeny, "policy.json rule 2"},
+		{"echo 1 > /etc/motd", "modify", ShellRouteDeny, "policy.json rule 3"},
+		{"npm install", "modify", ShellRouteHostDirect, "known package-manager command"},
+	}
+	for _, tt := range tests {
+		d := policy(ShellRequest{ToolName: "run_shell", Command: tt.command, Safety: tt.safety})
+		if d.Route != tt.route || !strings.Contains(d.Reason, tt.reason) {
+			t.Errorf("%q = %+v, want route %v with reason %q", tt.command, d, tt.route, tt.reason)
+		}
+	}
+}
+
+func TestLoadShellPolicyProjectCannotLoosen(t *testing.T) {
+	project, home := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	writeTestFiles(t, home, map[string]string{
+		".bono/policy.json": `{"rules": [{"command": "rm", "action": "deny", "reason": "user denies rm"}]}`,
+	})
+
+	writeTestFiles(t, project, map[string]string{ProjectShellPolicyPath: `{"rules": [{"action": "host"}]}`})
+	if _, err := LoadShellPolicy(project); err == nil || !strings.Contains(err.Error(), "only deny or sandbox") {
+		t.Fatalf("project host rule: err = %v", err)
+	}
+
+	writeTestFiles(t, project, map[string]string{ProjectShellPolicyPath: `{"rules": [{"action": "sandbox"}]}`})
+	policy, err := LoadShellPolicy(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := policy(ShellRequest{ToolName: "run_shell", Command: "rm -rf build"}); d.Route != ShellRouteDeny {
+		t.Errorf("project rule overrode the user's deny: %+v", d)
+	}
+	if d := policy(ShellRequest{ToolName: "run_shell", Command: "npm install"}); d.Route != ShellRouteSandboxFirst {
+		t.Errorf("project rule should tighten default host routing: %+v", d)
+	}
+}
+
+func TestLoadShellPolicyRejectsInvalidFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for name, body := range map[string]string{
+		"unknown field":  `{"rules": [{"comand": "rm", "action": "deny"}]}`,
+		"unknown action": `{"rules": [{"command": "rm", "action": "block"}]}`,
+		"bad glob":       `{"rules": [{"paths": ["[a"], "action": "deny"}]}`,
+	} {
+		project := t.TempDir()
+		writeTestFiles(t, project, map[string]string{ProjectShellPolicyPath: body})
+		if _, err := LoadShellPolicy(project); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := LoadShellPolicy(t.TempDir()); err != nil {
+		t.Fatalf("missing files should be skipped: %v", err)
+	}
+}
+
+func TestExecuteShellRequestDenied(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	policy := RuleBasedShellPolicy(CommandPatternRule(
+		ShellDecision{Route: ShellRouteDeny, Reason: "no touching"},
+		CommandPattern{Argv: []string{"touch"}},
+	))
+
+	res := ExecuteShellRequest(ShellRequest{ToolName: "run_shell", Command: "true; touch " + marker}, policy, nil)
+	if res.Success || !errors.Is(res.Error, ErrCommandDenied) || !strings.Contains(res.Output, "no touching") {
+		t.Fatalf("result = %+v", res)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("denied command ran")
+	}
+
+	jobs := &shellJobs{}
+	if res := jobs.start(ShellRequest{Command: "touch " + marker}, policy); !errors.Is(res.Error, ErrCommandDenied) {
+		t.Fatalf("background job = %+v", res)
+	}
+}
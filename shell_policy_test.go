@@ -1,6 +1,10 @@
 package core
 
-import "testing"
+import (
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestDefaultShellPolicyRoutesKnownCommandsOutsideSandbox(t *testing.T) {
 	tests := []ShellRequest{
@@ -8,6 +12,13 @@ func TestDefaultShellPolicyRoutesKnownCommandsOutsideSandbox(t *testing.T) {
 		{ToolName: "run_shell", Command: "git push origin main", Safety: "modify"},
 		{ToolName: "run_shell", Command: "curl https://example.com", Safety: "modify"},
 		{ToolName: "run_shell", Command: "echo hello", Safety: "network"},
+		{ToolName: "run_shell", Command: "cd web && npm install", Safety: "modify"},
+		{ToolName: "run_shell", Command: "FOO=1 curl https://example.com", Safety: "modify"},
+		{ToolName: "run_shell", Command: "bash -c 'git push'", Safety: "modify"},
+		{ToolName: "run_shell", Command: "git -C repo fetch origin", Safety: "modify"},
+		{ToolName: "run_shell", Command: "echo $(wget -qO- example.com) | wc -c", Safety: "modify"},
+		{ToolName: "run_shell", Command: "(cd x; sudo -u me pip install foo) > log 2>&1", Safety: "modify"},
+		{ToolName: "run_shell", Command: "env -i HOME=/tmp /usr/bin/curl example.com", Safety: "modify"},
 	}
 
 	for _, req := range tests {
@@ -22,11 +33,70 @@ func TestDefaultShellPolicyRoutesKnownCommandsOutsideSandbox(t *testing.T) {
 }
 
 func TestDefaultShellPolicyLeavesSafeCommandsSandboxed(t *testing.T) {
-	req := ShellRequest{ToolName: "run_shell", Command: "git status", Safety: "read-only"}
+	for _, command := range []string{
+		"git status",
+		"echo 'npm install' && grep curl notes.txt",
+		"curlie example.com",
+		"git log --grep push",
+	} {
+		req := ShellRequest{ToolName: "run_shell", Command: command, Safety: "read-only"}
+		if decision := DecideShellRequest(nil, req); decision.Route != ShellRouteSandboxFirst {
+			t.Fatalf("expected sandbox-first route for %q, got %#v", command, decision)
+		}
+	}
+}
 
-	decision := DecideShellRequest(nil, req)
-	if decision.Route != ShellRouteSandboxFirst {
-		t.Fatalf("expected sandbox-first route, got %#v", decision)
+func TestParseShellCommands(t *testing.T) {
+	cmds, err := ParseShellCommands(`cd "my dir" && FOO=1 BAR='x y' make -j4 >out.log 2>&1 | tee -a log; sh -c "rm -rf build"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var argv []string
+	for _, c := range cmds {
+		argv = append(argv, strings.Join(c.Argv, " "))
+	}
+	want := []string{"cd my dir", "make -j4", "tee -a log", "sh -c rm -rf build", "rm -rf build"}
+	if !slices.Equal(argv, want) {
+		t.Fatalf("argv = %q, want %q", argv, want)
+	}
+
+	mk := cmds[1]
+	if mk.Env["FOO"] != "1" || mk.Env["BAR"] != "x y" {
+		t.Fatalf("env = %v", mk.Env)
+	}
+	if !slices.Equal(mk.OutputFiles(), []string{"out.log"}) || len(mk.Redirects) != 2 {
+		t.Fatalf("redirects = %+v", mk.Redirects)
+	}
+	if len(cmds[2].OutputFiles()) != 0 {
+		t.Fatalf("tee should have no redirects, got %+v", cmds[2].Redirects)
+	}
+
+	if _, err := ParseShellCommands("echo (("); err == nil {
+		t.Fatal("expected syntax error")
+	}
+}
+
+func TestCommandPatternRule(t *testing.T) {
+	policy := RuleBasedShellPolicy(CommandPatternRule(
+		ShellDecision{Route: ShellRouteHostDirect, Reason: "matched"},
+		CommandPattern{Argv: []string{"docker", "*", "push"}},
+		CommandPattern{Env: []string{"AWS_PROFILE"}},
+		CommandPattern{OutputFile: "/etc/*"},
+	))
+
+	tests := map[string]ShellRoute{
+		"docker image push app":        ShellRouteHostDirect,
+		"docker push app":              ShellRouteSandboxFirst,
+		"AWS_PROFILE=prod aws s3 ls":   ShellRouteHostDirect,
+		"echo x | tee /etc/hosts":      ShellRouteSandboxFirst,
+		"true && echo x >> /etc/hosts": ShellRouteHostDirect,
+		"echo x > /etc/hosts.d/a 2>&1": ShellRouteSandboxFirst,
+		"echo x 2>/etc/err":            ShellRouteHostDirect,
+	}
+	for command, want := range tests {
+		if got := policy(ShellRequest{Command: command}).Route; got != want {
+			t.Errorf("%q routed %v, want %v", command, got, want)
+		}
 	}
 }
 
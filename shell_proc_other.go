@@ -0,0 +1,21 @@
+//go:build !unix
+
+package core
+
+import (
+	"os"
+	"os/exec"
+)
+
+// configureProcessGroup is a no-op where process groups are unavailable;
+// exec.CommandContext still kills the direct child on cancellation.
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+// exitSignal reports no signal where wait statuses are not exposed.
+func exitSignal(state *os.ProcessState) string { return "" }
+
+// interruptProcessGroup interrupts the direct child only; it fails where
+// os.Interrupt can't be delivered.
+func interruptProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(os.Interrupt)
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package core
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configureProcessGroup runs cmd in its own process group and makes context
+// cancellation kill the whole group, so grandchildren spawned by sh die too.
+func configureProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// exitSignal names the signal that terminated the process, or "" if it exited.
+func exitSignal(state *os.ProcessState) string {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return ws.Signal().String()
+	}
+	return ""
+}
+
+// interruptProcessGroup sends SIGINT to cmd's process group, like Ctrl-C.
+func interruptProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+}
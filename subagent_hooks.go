@@ -65,24 +65,7 @@ func (h *persistHook) AfterComplete(_ context.Context, result *SubAgentResult) e
 }
 
 func (h *persistHook) resolveDir(cwd string) string {
-	dir := h.dirTemplate
-
-	// Expand ~ to home directory.
-	if strings.HasPrefix(dir, "~/") {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			dir = filepath.Join(home, dir[2:])
-		}
-	}
-
-	// Expand {cwd} placeholder.
-	if strings.Contains(dir, "{cwd}") {
-		// Strip leading slash so path nests cleanly under the base dir.
-		cwdClean := strings.TrimPrefix(cwd, "/")
-		dir = strings.ReplaceAll(dir, "{cwd}", cwdClean)
-	}
-
-	return dir
+	return expandDirTemplate(h.dirTemplate, cwd)
 }
 
 // approvalHook prompts the user to approve, reject, or revise the subagent output.
@@ -0,0 +1,268 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ApplyPatchTool returns the apply_patch tool definition. Under a sandbox, a
+// patch touching files outside the allowed paths is blocked unless onFallback
+// approves each of them.
+func ApplyPatchTool(onFallback func(cmd, reason string) bool) *ToolDef {
+	return &ToolDef{
+		Name:        "apply_patch",
+		Description: "Applies a patch that can add, delete, rename and modify several files in one call. Prefer this over repeated edit_file calls for changes spanning many places or files. Accepts a unified diff (as produced by `diff -u` or `git diff`, with ---/+++ headers and @@ hunks) or a structured patch:\n*** Begin Patch\n*** Update File: path\n*** Move to: new/path (optional)\n@@ optional line to seek to first\n context line\n-removed line\n+added line\n*** Add File: path\n+line\n*** Delete File: path\n*** End Patch\nContext lines are matched with whitespace tolerance and small line offsets. The patch is atomic: if any hunk fails, no file is changed and the failing hunk is reported with the reason.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"patch": map[string]any{
+					"type":        "string",
+					"description": "The full patch text, either a unified diff or a *** Begin Patch ... *** End Patch block. Include about 3 lines of unchanged context around each change.",
+				},
+			},
+			"required": []any{"patch"},
+		},
+		Execute: func(args map[string]any) ToolResult {
+			patch, _ := args["patch"].(string)
+			if blocked := guardFileAccess("apply_patch", patchPaths(args), true, onFallback); blocked != nil {
+				return *blocked
+			}
+			return ExecuteApplyPatch(patch)
+		},
+		Permission:  askPermission("modifies files"),
+		WritesFiles: patchPaths,
+		Preview: func(args map[string]any) ToolResult {
+			patch, _ := args["patch"].(string)
+			if blocked := guardFileAccess("apply_patch", patchPaths(args), false, nil); blocked != nil {
+				return *blocked
+			}
+			return applyPatch(patch, true)
+		},
+	}
+}
+
+// patchPaths lists every file a patch touches, including rename targets.
+func patchPaths(args map[string]any) []string {
+	text, _ := args["patch"].(string)
+	patches, err := parsePatch(text)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, p := range patches {
+		paths = append(paths, p.path)
+		if p.newPath != "" {
+			paths = append(paths, p.newPath)
+		}
+	}
+	return paths
+}
+
+// stagedFile is a file's state before and after the patch.
+type stagedFile struct {
+	path       string
+	origExists bool
+	orig       string
+	origMode   os.FileMode
+	exists     bool
+	content    string
+	mode       os.FileMode
+}
+
+func (f *stagedFile) changed() bool {
+	return f.exists != f.origExists || f.content != f.orig || (f.exists && f.mode != f.origMode)
+}
+
+// ExecuteApplyPatch applies a unified diff or "*** Begin Patch" block.
+// Every change is computed in memory first, so either all files are
+// updated or none are.
+func ExecuteApplyPatch(patch string) ToolResult {
+	return applyPatch(patch, false)
+}
+
+// applyPatch implements ExecuteApplyPatch; with dryRun set it only reports
+// the diff.
+func applyPatch(patch string, dryRun bool) ToolResult {
+	patches, err := parsePatch(patch)
+	if err != nil {
+		return patchFailure(err)
+	}
+
+	staged := make(map[string]*stagedFile)
+	var order []string
+	load := func(path string) (*stagedFile, error) {
+		key := filepath.Clean(path)
+		if f, ok := staged[key]; ok {
+			return f, nil
+		}
+		f := &stagedFile{path: key, mode: 0644}
+		info, err := os.Stat(key)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		case info.IsDir():
+			return nil, fmt.Errorf("%s is a directory", path)
+		default:
+			data, err := os.ReadFile(key)
+			if err != nil {
+				return nil, err
+			}
+			f.origExists, f.orig, f.origMode = true, string(data), info.Mode().Perm()
+			f.exists, f.content, f.mode = true, f.orig, f.origMode
+		}
+		staged[key] = f
+		order = append(order, key)
+		return f, nil
+	}
+
+	var summary, notes []string
+	for _, p := range patches {
+		src, err := load(p.path)
+		if err != nil {
+			return patchFailure(err)
+		}
+		switch p.op {
+		case patchAdd:
+			if src.exists {
+				return patchFailure(fmt.Errorf("add %s: file already exists", p.path))
+			}
+			content, _, err := applyHunks("", p.hunks)
+			if err != nil {
+				return patchFailure(fmt.Errorf("add %s: %w", p.path, err))
+			}
+			src.exists, src.content = true, content
+			summary = append(summary, "A "+p.path)
+
+		case patchDelete:
+			if !src.exists {
+				return patchFailure(fmt.Errorf("delete %s: file not found", p.path))
+			}
+			src.exists, src.content = false, ""
+			summary = append(summary, "D "+p.path)
+
+		case patchUpdate:
+			if !src.exists {
+				return patchFailure(fmt.Errorf("update %s: file not found", p.path))
+			}
+			content, hunkNotes, err := applyHunks(src.content, p.hunks)
+			if err != nil {
+				return patchFailure(fmt.Errorf("update %s: %w", p.path, err))
+			}
+			for _, n := range hunkNotes {
+				notes = append(notes, p.path+": "+n)
+			}
+			line := fmt.Sprintf("M %s (%d hunk(s))", p.path, len(p.hunks))
+			if p.newPath != "" && filepath.Clean(p.newPath) != src.path {
+				dst, err := load(p.newPath)
+				if err != nil {
+					return patchFailure(err)
+				}
+				if dst.exists {
+					return patchFailure(fmt.Errorf("rename %s: %s already exists", p.path, p.newPath))
+				}
+				dst.exists, dst.content, dst.mode = true, content, src.mode
+				src.exists, src.content = false, ""
+				line = fmt.Sprintf("R %s -> %s (%d hunk(s))", p.path, p.newPath, len(p.hunks))
+			} else {
+				src.content = content
+			}
+			summary = append(summary, line)
+		}
+	}
+
+	var diff strings.Builder
+	for _, path := range order {
+		if f := staged[path]; f.changed() {
+			diff.WriteString(fileDiff(path, f.origExists, f.orig, f.exists, f.content))
+		}
+	}
+
+	out := strings.Join(summary, "\n")
+	if len(notes) > 0 {
+		out += "\nNotes:\n" + strings.Join(notes, "\n")
+	}
+	if dryRun {
+		return previewResult("Patch would apply:\n"+out, diff.String())
+	}
+	if err := commitStagedFiles(order, staged); err != nil {
+		return patchFailure(err)
+	}
+	return ToolResult{
+		Success: true,
+		Output:  "Applied patch:\n" + out,
+		Status:  fmt.Sprintf("applied %d file(s)", len(summary)),
+		Diff:    diff.String(),
+	}
+}
+
+// commitStagedFiles stages every changed file beside its target, then moves
+// them into place. A failure part way rolls back completed files.
+func commitStagedFiles(order []string, staged map[string]*stagedFile) error {
+	writes := make(map[string]*fileWrite)
+	discard := func() {
+		for _, w := range writes {
+			w.discard()
+		}
+	}
+
+	for _, path := range order {
+		f := staged[path]
+		if !f.changed() || !f.exists {
+			continue
+		}
+		w, err := stageFileWrite(path, []byte(f.content), f.mode)
+		if err != nil {
+			discard()
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+		writes[path] = w
+	}
+
+	var done []*stagedFile
+	for _, path := range order {
+		f := staged[path]
+		if !f.changed() {
+			continue
+		}
+		var err error
+		if f.exists {
+			err = writes[path].commit()
+			delete(writes, path)
+		} else {
+			err = os.Remove(path)
+		}
+		if err != nil {
+			discard()
+			for _, d := range done {
+				rollbackStagedFile(d)
+			}
+			return fmt.Errorf("commit %s: %w (earlier files rolled back)", path, err)
+		}
+		done = append(done, f)
+	}
+	return nil
+}
+
+func rollbackStagedFile(f *stagedFile) {
+	if !f.origExists {
+		os.Remove(f.path)
+		return
+	}
+	os.WriteFile(f.path, []byte(f.orig), f.origMode)
+}
+
+// patchFailure reports a patch that was not applied. Output carries the
+// reason so the model can fix the failing hunk.
+func patchFailure(err error) ToolResult {
+	err = fmt.Errorf("%w: %v", ErrPatchFailed, err)
+	return ToolResult{
+		Success: false,
+		Output:  "Patch not applied, no files were changed. " + err.Error(),
+		Status:  "fail: " + err.Error(),
+		Error:   err,
+	}
+}
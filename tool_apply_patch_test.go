@@ -0,0 +1,212 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestExecuteApplyPatchUnifiedMultiFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTestFiles(t, dir, map[string]string{
+		"main.go":  "package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n",
+		"old.txt":  "keep\nrename me\n",
+		"gone.txt": "bye\n",
+	})
+
+	patch := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -3,1 +3,1 @@
+-func a() {}
++func a() { println("a") }
+@@ -7,1 +7,2 @@
+ func c() {}
++func d() {}
+diff --git a/old.txt b/new.txt
+similarity index 50%
+rename from old.txt
+rename to new.txt
+--- a/old.txt
++++ b/new.txt
+@@ -1,2 +1,2 @@
+ keep
+-rename me
++renamed
+--- /dev/null
++++ b/sub/added.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	res := ExecuteApplyPatch(patch)
+	if !res.Success {
+		t.Fatalf("apply failed: %s", res.Output)
+	}
+
+	if got := readTestFile(t, "main.go"); got != "package main\n\nfunc a() { println(\"a\") }\n\nfunc b() {}\n\nfunc c() {}\nfunc d() {}\n" {
+		t.Fatalf("main.go = %q", got)
+	}
+	if got := readTestFile(t, "new.txt"); got != "keep\nrenamed\n" {
+		t.Fatalf("new.txt = %q", got)
+	}
+	if _, err := os.Stat("old.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("old.txt should be renamed away")
+	}
+	if got := readTestFile(t, "sub/added.txt"); got != "hello\nworld\n" {
+		t.Fatalf("added.txt = %q", got)
+	}
+	if _, err := os.Stat("gone.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("gone.txt should be deleted")
+	}
+	for _, want := range []string{"M main.go (2 hunk(s))", "R old.txt -> new.txt", "A sub/added.txt", "D gone.txt"} {
+		if !strings.Contains(res.Output, want) {
+			t.Fatalf("output missing %q:\n%s", want, res.Output)
+		}
+	}
+}
+
+func TestExecuteApplyPatchStructured(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTestFiles(t, dir, map[string]string{
+		"app.py": "class A:\n    def run(self):\n        return 1\n\nclass B:\n    def run(self):\n        return 1\n",
+	})
+
+	patch := `*** Begin Patch
+*** Update File: app.py
+*** Move to: lib/app.py
+@@ class B:
+     def run(self):
+-        return 1
++        return 2
+*** Add File: README.md
++# App
+
++Docs.
+*** End Patch`
+	res := ExecuteApplyPatch(patch)
+	if !res.Success {
+		t.Fatalf("apply failed: %s", res.Output)
+	}
+	want := "class A:\n    def run(self):\n        return 1\n\nclass B:\n    def run(self):\n        return 2\n"
+	if got := readTestFile(t, "lib/app.py"); got != want {
+		t.Fatalf("lib/app.py = %q", got)
+	}
+	if got := readTestFile(t, "README.md"); got != "# App\n\nDocs.\n" {
+		t.Fatalf("README.md = %q", got)
+	}
+}
+
+func TestExecuteApplyPatchFuzzyContext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.go")
+	// Two extra lines at the top shift the hunk; indentation drifted to tabs.
+	writeTestFiles(t, dir, map[string]string{"f.go": "// a\n// b\nfunc f() {\n\tx := 1\n\treturn x\n}\n"})
+
+	patch := "--- a/" + path + "\n+++ b/" + path + "\n@@ -1,4 +1,4 @@\n func f() {\n-    x := 1\n+    x := 2\n     return x\n"
+	res := ExecuteApplyPatch(patch)
+	if !res.Success {
+		t.Fatalf("apply failed: %s", res.Output)
+	}
+	if got := readTestFile(t, path); got != "// a\n// b\nfunc f() {\n    x := 2\n\treturn x\n}\n" {
+		t.Fatalf("f.go = %q", got)
+	}
+	if !strings.Contains(res.Output, "offset +2 lines, ignoring whitespace") {
+		t.Fatalf("expected fuzz note in output:\n%s", res.Output)
+	}
+}
+
+func TestExecuteApplyPatchAtomicFailure(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTestFiles(t, dir, map[string]string{
+		"a.txt": "one\ntwo\n",
+		"b.txt": "alpha\nbeta\ngamma\n",
+	})
+
+	patch := `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ alpha
+-beta
++BETA
+@@ -3,1 +3,1 @@
+-delta
++DELTA
+`
+	res := ExecuteApplyPatch(patch)
+	if res.Success {
+		t.Fatal("expected failure")
+	}
+	if !errors.Is(res.Error, ErrPatchFailed) {
+		t.Fatalf("error = %v, want ErrPatchFailed", res.Error)
+	}
+	if !strings.Contains(res.Output, "update b.txt: hunk 2/2 (@@ -3,1 +3,1 @@)") || !strings.Contains(res.Output, `"delta"`) {
+		t.Fatalf("failure should name the hunk and the missing line:\n%s", res.Output)
+	}
+	if got := readTestFile(t, "a.txt"); got != "one\ntwo\n" {
+		t.Fatalf("a.txt modified despite failure: %q", got)
+	}
+	if got := readTestFile(t, "b.txt"); got != "alpha\nbeta\ngamma\n" {
+		t.Fatalf("b.txt modified despite failure: %q", got)
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"empty", "", "no file changes"},
+		{"hunk without header", "@@ -1 +1 @@\n-a\n+b\n", "hunk before any file header"},
+		{"structured without end", "*** Begin Patch\n*** Delete File: x\n", "missing \"*** End Patch\""},
+		{"structured add without plus", "*** Begin Patch\n*** Add File: x\n-a\n*** End Patch\n", "every line must start with '+'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePatch(tt.patch)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
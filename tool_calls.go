@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// runToolCalls executes the tool calls of one assistant response and returns the
+// tool result messages in ToolCallID order. With MaxParallelToolCalls > 1, runs of
+// consecutive ReadOnly tools execute concurrently; any other tool acts as a barrier.
+// Each call is checked against the permission rules before a batch starts:
+// denied calls are skipped and their reason returned to the model, and
+// OnToolCall fires, in order, only for calls the rules ask about. OnToolStart
+// then fires for every call of the batch before it runs, and OnToolDone fires
+// after the batch finishes, all in order on the caller's goroutine.
+// allowSet restricts callable tools (subagents); nil or empty allows all.
+// cancelled reports that OnToolCall declined a call; later calls were not run.
+func (a *Agent) runToolCalls(ctx context.Context, calls []ToolCall, allowSet map[string]bool) (results []Message, cancelled bool) {
+	results = make([]Message, 0, len(calls))
+
+	for start := 0; start < len(calls); {
+		if ctx.Err() != nil {
+			break
+		}
+		end := a.toolBatchEnd(calls, start, allowSet)
+		batch := calls[start:end]
+		start = end
+
+		args := make([]map[string]any, len(batch))
+		for i, tc := range batch {
+			json.Unmarshal([]byte(tc.Function.Arguments), &args[i])
+		}
+
+		// Runtime rejection for disallowed tools (always a batch of one).
+		if !toolAllowed(allowSet, batch[0].Function.Name) {
+			results = append(results, Message{
+				Role:       "tool",
+				ToolCallID: batch[0].ID,
+				Content:    fmt.Sprintf("error: tool %q is not available in this mode", batch[0].Function.Name),
+			})
+			continue
+		}
+
+		// Check permissions; OnToolCall decides only the calls rules ask about.
+		out := make([]ToolResult, len(batch))
+		denied := make([]bool, len(batch))
+		for i, tc := range batch {
+			switch decision := a.checkPermission(tc.Function.Name, args[i], true); decision.Permission {
+			case PermissionDeny:
+				out[i], denied[i] = permissionDeniedResult(decision), true
+			case PermissionAsk:
+				if a.OnToolCall != nil && !a.OnToolCall(tc.Function.Name, args[i]) {
+					return results, true
+				}
+			}
+		}
+
+		if a.OnToolStart != nil {
+			for i, tc := range batch {
+				a.OnToolStart(tc.Function.Name, args[i])
+			}
+		}
+
+		if len(batch) == 1 {
+			if !denied[0] {
+				out[0] = a.executeTool(withToolCallID(ctx, batch[0].ID), batch[0].Function.Name, args[0])
+			}
+		} else {
+			sem := make(chan struct{}, a.config.MaxParallelToolCalls)
+			var wg sync.WaitGroup
+			for i, tc := range batch {
+				if denied[i] {
+					continue
+				}
+				wg.Add(1)
+				sem <- struct{}{}
+				go func(i int, tc ToolCall) {
+					defer wg.Done()
+					defer func() { <-sem }()
+					out[i] = a.executeTool(withToolCallID(ctx, tc.ID), tc.Function.Name, args[i])
+				}(i, tc)
+			}
+			wg.Wait()
+		}
+
+		for i, tc := range batch {
+			if a.OnToolDone != nil {
+				a.OnToolDone(tc.Function.Name, args[i], out[i])
+			}
+			results = append(results, Message{
+				Role:       "tool",
+				ToolCallID: tc.ID,
+				Content:    out[i].Output,
+			})
+		}
+	}
+
+	return results, false
+}
+
+// toolBatchEnd returns the exclusive end index of the batch starting at start.
+func (a *Agent) toolBatchEnd(calls []ToolCall, start int, allowSet map[string]bool) int {
+	if a.config.MaxParallelToolCalls <= 1 || !a.parallelSafe(calls[start].Function.Name, allowSet) {
+		return start + 1
+	}
+	end := start + 1
+	for end < len(calls) && a.parallelSafe(calls[end].Function.Name, allowSet) {
+		end++
+	}
+	return end
+}
+
+// parallelSafe reports whether a tool may run concurrently with its neighbours.
+func (a *Agent) parallelSafe(name string, allowSet map[string]bool) bool {
+	if !toolAllowed(allowSet, name) {
+		return false
+	}
+	tool, ok := a.registry.Get(name)
+	return ok && tool.ReadOnly
+}
+
+// executeTool looks up and runs a single tool.
+func (a *Agent) executeTool(ctx context.Context, name string, args map[string]any) ToolResult {
+	tool, ok := a.registry.Get(name)
+	if !ok {
+		return ToolResult{Success: false, Error: fmt.Errorf("unknown tool: %s", name), Status: "fail: unknown tool"}
+	}
+	if tool.WritesFiles != nil {
+		a.checkpointFiles(tool.WritesFiles(args))
+	}
+	return a.applyOutputBudget(name, tool.Run(ctx, args))
+}
+
+// PreviewTool computes what a tool call would do without applying it, for
+// showing a diff from OnToolCall. ok is false when the tool is unknown or
+// has no preview.
+func (a *Agent) PreviewTool(name string, args map[string]any) (result ToolResult, ok bool) {
+	tool, found := a.registry.Get(name)
+	if !found || tool.Preview == nil {
+		return ToolResult{}, false
+	}
+	return tool.Preview(args), true
+}
+
+type toolCallIDKey struct{}
+
+// withToolCallID tags ctx with the ID of the tool call it runs.
+func withToolCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, toolCallIDKey{}, id)
+}
+
+// toolCallID returns the ID of the tool call ctx runs, or "" outside one.
+func toolCallID(ctx context.Context) string {
+	id, _ := ctx.Value(toolCallIDKey{}).(string)
+	return id
+}
+
+// shellOutputHook returns the ShellOutputFunc forwarding a command's output
+// to OnToolOutputDelta under ctx's tool call ID, or nil when the hook is unset.
+func (a *Agent) shellOutputHook(ctx context.Context) ShellOutputFunc {
+	if a.OnToolOutputDelta == nil {
+		return nil
+	}
+	id := toolCallID(ctx)
+	return func(stream, chunk string) {
+		a.OnToolOutputDelta(id, stream, chunk)
+	}
+}
+
+func toolAllowed(allowSet map[string]bool, name string) bool {
+	return len(allowSet) == 0 || allowSet[name]
+}
@@ -0,0 +1,214 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newToolCallTestAgent(maxParallel int, tools ...*ToolDef) *Agent {
+	a := &Agent{config: Config{MaxParallelToolCalls: maxParallel}, registry: NewRegistry()}
+	for _, t := range tools {
+		a.registry.Register(t)
+	}
+	return a
+}
+
+func toolCall(id, name string) ToolCall {
+	return ToolCall{ID: id, Type: "function", Function: FunctionCall{Name: name, Arguments: `{"id":"` + id + `"}`}}
+}
+
+func TestRunToolCallsParallelReadOnlyKeepsOrder(t *testing.T) {
+	var running, peak int32
+	slowRead := &ToolDef{
+		Name:     "slow_read",
+		ReadOnly: true,
+		Execute: func(args map[string]any) ToolResult {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return ToolResult{Success: true, Output: "out-" + args["id"].(string)}
+		},
+	}
+	a := newToolCallTestAgent(4, slowRead)
+
+	var mu sync.Mutex
+	var events []string
+	a.OnToolCall = func(name string, args map[string]any) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, "call-"+args["id"].(string))
+		return true
+	}
+	a.OnToolDone = func(name string, args map[string]any, result ToolResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, "done-"+args["id"].(string))
+	}
+
+	calls := []ToolCall{toolCall("a", "slow_read"), toolCall("b", "slow_read"), toolCall("c", "slow_read")}
+	results, cancelled := a.runToolCalls(context.Background(), calls, nil)
+
+	if cancelled {
+		t.Fatal("unexpected cancellation")
+	}
+	if peak < 2 {
+		t.Fatalf("expected read-only tools to overlap, peak concurrency = %d", peak)
+	}
+	for i, id := range []string{"a", "b", "c"} {
+		if results[i].ToolCallID != id || results[i].Content != "out-"+id {
+			t.Fatalf("result %d = %+v, want id %q", i, results[i], id)
+		}
+	}
+	want := []string{"call-a", "call-b", "call-c", "done-a", "done-b", "done-c"}
+	if fmt.Sprint(events) != fmt.Sprint(want) {
+		t.Fatalf("hook order = %v, want %v", events, want)
+	}
+}
+
+func TestRunToolCallsMutatingToolIsBarrier(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	record := func(name string, readOnly bool) *ToolDef {
+		return &ToolDef{
+			Name:     name,
+			ReadOnly: readOnly,
+			Execute: func(args map[string]any) ToolResult {
+				mu.Lock()
+				order = append(order, args["id"].(string))
+				mu.Unlock()
+				return ToolResult{Success: true}
+			},
+		}
+	}
+	a := newToolCallTestAgent(4, record("reader", true), record("writer", false))
+
+	calls := []ToolCall{toolCall("r1", "reader"), toolCall("w", "writer"), toolCall("r2", "reader")}
+	a.runToolCalls(context.Background(), calls, nil)
+
+	if len(order) != 3 || order[1] != "w" {
+		t.Fatalf("expected writer to run between readers, got %v", order)
+	}
+}
+
+func TestRunToolCallsSequentialByDefault(t *testing.T) {
+	var running, peak int32
+	read := &ToolDef{
+		Name:     "reader",
+		ReadOnly: true,
+		Execute: func(args map[string]any) ToolResult {
+			if n := atomic.AddInt32(&running, 1); n > atomic.LoadInt32(&peak) {
+				atomic.StoreInt32(&peak, n)
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return ToolResult{Success: true}
+		},
+	}
+	a := newToolCallTestAgent(0, read)
+
+	a.runToolCalls(context.Background(), []ToolCall{toolCall("a", "reader"), toolCall("b", "reader")}, nil)
+
+	if peak != 1 {
+		t.Fatalf("expected sequential execution, peak concurrency = %d", peak)
+	}
+}
+
+func TestRunToolCallsDeclinedStopsBatch(t *testing.T) {
+	executed := 0
+	read := &ToolDef{
+		Name:     "reader",
+		ReadOnly: true,
+		Execute: func(args map[string]any) ToolResult {
+			executed++
+			return ToolResult{Success: true}
+		},
+	}
+	a := newToolCallTestAgent(4, read)
+	a.OnToolCall = func(name string, args map[string]any) bool {
+		return args["id"] != "b"
+	}
+
+	_, cancelled := a.runToolCalls(context.Background(), []ToolCall{toolCall("a", "reader"), toolCall("b", "reader")}, nil)
+
+	if !cancelled {
+		t.Fatal("expected cancellation when OnToolCall declines")
+	}
+	if executed != 0 {
+		t.Fatalf("expected no tool in the declined batch to run, ran %d", executed)
+	}
+}
+
+func TestShellOutputStreamsByToolCallID(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	var mu sync.Mutex
+	streamed := map[string]string{}
+	var a *Agent
+	shell := RunShellToolContext(func(ctx context.Context, req ShellRequest) ToolResult {
+		req.OnOutput = a.shellOutputHook(ctx)
+		return ExecuteShellRequestContext(ctx, req, nil, nil)
+	})
+	a = newToolCallTestAgent(1, shell)
+	a.OnToolOutputDelta = func(id, stream, delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed[id+"/"+stream] += delta
+	}
+
+	call := ToolCall{ID: "call-1", Type: "function", Function: FunctionCall{Name: "run_shell", Arguments: `{"command":"echo out; echo err >&2"}`}}
+	results, _ := a.runToolCalls(context.Background(), []ToolCall{call}, nil)
+
+	if streamed["call-1/stdout"] != "out\n" || streamed["call-1/stderr"] != "err\n" {
+		t.Fatalf("streamed = %q", streamed)
+	}
+	// Output keeps both streams; their relative order depends on scheduling.
+	content, _ := results[0].Content.(string)
+	if !strings.Contains(content, "out\n") || !strings.Contains(content, "err\n") {
+		t.Fatalf("results = %+v", results)
+	}
+}
+
+func TestRunToolCallsSerializesSandboxFallback(t *testing.T) {
+	var a *Agent
+	blockedRead := &ToolDef{
+		Name:     "blocked_read",
+		ReadOnly: true,
+		Execute: func(args map[string]any) ToolResult {
+			return ToolResult{Success: a.sandboxFallback("blocked_read", "outside allowed paths")}
+		},
+	}
+	a = newToolCallTestAgent(4, blockedRead)
+
+	var prompting, peak int32
+	a.OnSandboxFallback = func(command, reason string) bool {
+		n := atomic.AddInt32(&prompting, 1)
+		defer atomic.AddInt32(&prompting, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		return true
+	}
+
+	calls := []ToolCall{toolCall("1", "blocked_read"), toolCall("2", "blocked_read"), toolCall("3", "blocked_read")}
+	a.runToolCalls(context.Background(), calls, nil)
+	if peak != 1 {
+		t.Fatalf("OnSandboxFallback ran %d at a time, want 1", peak)
+	}
+}
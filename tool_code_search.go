@@ -1,12 +1,25 @@
 package core
 
+import "context"
+
 // SearchFunc is the function signature for code search.
-// Called by the tool with the query string and raw args map from the LLM.
-type SearchFunc func(query string, opts map[string]any) ToolResult
+// Called by the tool with the call context, the query string and raw args map from the LLM.
+type SearchFunc func(ctx context.Context, query string, opts map[string]any) ToolResult
 
 // CodeSearchTool returns the code_search tool definition.
 // searchFn is injected by the caller with the actual search implementation.
 func CodeSearchTool(searchFn SearchFunc) *ToolDef {
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		query, _ := args["query"].(string)
+		if query == "" {
+			return ToolResult{
+				Success: false,
+				Output:  "query parameter is required",
+				Status:  "fail: empty query",
+			}
+		}
+		return searchFn(ctx, query, args)
+	}
 	return &ToolDef{
 		Name: "code_search",
 		Description: `Search the indexed codebase by meaning, intent, and structure—not just exact text.
@@ -65,19 +78,9 @@ Returns ranked matches with file paths, line numbers, and code snippets.`,
 			},
 			"required": []any{"query"},
 		},
-		Execute: func(args map[string]any) ToolResult {
-			query, _ := args["query"].(string)
-			if query == "" {
-				return ToolResult{
-					Success: false,
-					Output:  "query parameter is required",
-					Status:  "fail: empty query",
-				}
-			}
-			return searchFn(query, args)
-		},
-		AutoApprove: func(sandboxed bool) bool {
-			return true // read-only operation
-		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     allowPermission("read-only search"),
+		ReadOnly:       true,
 	}
 }
@@ -20,8 +20,6 @@ func CompactContextTool(compact func(summary string) ToolResult) *ToolDef {
 			summary, _ := args["summary"].(string)
 			return compact(summary)
 		},
-		AutoApprove: func(sandboxed bool) bool {
-			return true
-		},
+		Permission: allowPermission("only rewrites conversation history"),
 	}
 }
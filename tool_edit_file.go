@@ -6,11 +6,18 @@ import (
 	"strings"
 )
 
-// EditFileTool returns the edit_file tool definition.
+// EditFileTool returns the edit_file tool definition. Under a sandbox, edits outside
+// the allowed paths are blocked.
 func EditFileTool() *ToolDef {
+	return EditFileToolWithFallback(nil)
+}
+
+// EditFileToolWithFallback is like EditFileTool, but edits outside the allowed paths
+// go ahead when onFallback approves them.
+func EditFileToolWithFallback(onFallback func(cmd, reason string) bool) *ToolDef {
 	return &ToolDef{
 		Name:        "edit_file",
-		Description: "Performs a search-and-replace operation in a file. Use this for making targeted changes to existing files. The old_string must match exactly including all whitespace and newlines. Fails if old_string is not found. Fails if old_string matches multiple times unless replace_all is true. Always use read_file first to see the exact text you need to match.",
+		Description: "Performs a search-and-replace operation in a file. Use this for making targeted changes to existing files. The old_string should match exactly; if it doesn't, matching falls back to ignoring line endings, trailing whitespace and overall indentation (new_string is re-indented to fit). Fails if old_string is not found, showing the closest region with line numbers. Fails if old_string matches multiple times unless replace_all is true. To make several changes to one file in a single call, pass them as edits. Always use read_file first to see the exact text you need to match.",
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -20,7 +27,7 @@ func EditFileTool() *ToolDef {
 				},
 				"old_string": map[string]any{
 					"type":        "string",
-					"description": "The exact text to find and replace. Must match character-for-character including whitespace and newlines.",
+					"description": "The text to find and replace. Copy it exactly from the file, including whitespace and newlines.",
 				},
 				"new_string": map[string]any{
 					"type":        "string",
@@ -31,24 +38,83 @@ func EditFileTool() *ToolDef {
 					"default":     false,
 					"description": "If true, replaces all occurrences. If false (default), fails when multiple matches exist.",
 				},
+				"edits": map[string]any{
+					"type":        "array",
+					"description": "Several edits to the same file, applied in order (each sees the result of the previous one) and written together only if all succeed. Use instead of old_string/new_string/replace_all.",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"old_string":  map[string]any{"type": "string"},
+							"new_string":  map[string]any{"type": "string"},
+							"replace_all": map[string]any{"type": "boolean", "default": false},
+						},
+						"required": []any{"old_string", "new_string"},
+					},
+				},
 			},
-			"required": []any{"path", "old_string", "new_string"},
+			"required": []any{"path"},
 		},
 		Execute: func(args map[string]any) ToolResult {
 			path, _ := args["path"].(string)
-			oldStr, _ := args["old_string"].(string)
-			newStr, _ := args["new_string"].(string)
-			replaceAll, _ := args["replace_all"].(bool)
-			return ExecuteEditFile(path, oldStr, newStr, replaceAll)
+			if blocked := guardFileAccess("edit_file", []string{path}, true, onFallback); blocked != nil {
+				return *blocked
+			}
+			return ExecuteEditFileBatch(path, editFileArgs(args))
 		},
-		AutoApprove: func(sandboxed bool) bool {
-			return false
+		Permission:  askPermission("modifies files"),
+		WritesFiles: pathArg,
+		Preview: func(args map[string]any) ToolResult {
+			path, _ := args["path"].(string)
+			if blocked := guardFileAccess("edit_file", []string{path}, false, nil); blocked != nil {
+				return *blocked
+			}
+			return editFile(path, editFileArgs(args), true)
 		},
 	}
 }
 
-// ExecuteEditFile performs string replacement in a file.
+// editFileArgs reads either the edits array or the single-edit arguments.
+func editFileArgs(args map[string]any) []FileEdit {
+	if raw, ok := args["edits"].([]any); ok && len(raw) > 0 {
+		return parseFileEdits(raw)
+	}
+	oldStr, _ := args["old_string"].(string)
+	newStr, _ := args["new_string"].(string)
+	replaceAll, _ := args["replace_all"].(bool)
+	return []FileEdit{{OldString: oldStr, NewString: newStr, ReplaceAll: replaceAll}}
+}
+
+// FileEdit is one search-and-replace step of an edit_file batch.
+type FileEdit struct {
+	OldString  string
+	NewString  string
+	ReplaceAll bool
+}
+
+// ExecuteEditFile performs string replacement in a file. old_string is matched
+// exactly first, then with line endings normalized, then ignoring trailing
+// whitespace, then at any indentation; the first tier with a match is used.
 func ExecuteEditFile(path, oldStr, newStr string, replaceAll bool) ToolResult {
+	return ExecuteEditFileBatch(path, []FileEdit{{OldString: oldStr, NewString: newStr, ReplaceAll: replaceAll}})
+}
+
+// ExecuteEditFileBatch applies edits to one file in order, each against the
+// result of the previous one. The file is written once, only if every edit
+// matched; otherwise it is left untouched and the failing edit is reported.
+func ExecuteEditFileBatch(path string, edits []FileEdit) ToolResult {
+	return editFile(path, edits, false)
+}
+
+// editFile implements ExecuteEditFileBatch; with dryRun set it only reports
+// the diff.
+func editFile(path string, edits []FileEdit, dryRun bool) ToolResult {
+	if len(edits) == 0 {
+		return ToolResult{
+			Success: false,
+			Error:   fmt.Errorf("edit_file: no edits given"),
+			Status:  "fail: no edits",
+		}
+	}
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return ToolResult{
@@ -58,31 +124,32 @@ func ExecuteEditFile(path, oldStr, newStr string, replaceAll bool) ToolResult {
 		}
 	}
 
-	count := strings.Count(string(content), oldStr)
-	if count == 0 {
-		return ToolResult{
-			Success: false,
-			Error:   ErrStringNotFound,
-			Status:  "fail: string not found",
+	text := string(content)
+	reports := make([]string, len(edits))
+	for i, edit := range edits {
+		matches, tier := findEditMatches(text, edit.OldString, edit.NewString)
+		count := len(matches)
+		if count == 0 {
+			return batchEditFailure(editNotFound(path, text, edit.OldString), i, len(edits))
 		}
-	}
-
-	if count > 1 && !replaceAll {
-		return ToolResult{
-			Success: false,
-			Error:   ErrMultipleMatches,
-			Status:  fmt.Sprintf("fail: %d matches (use replace_all)", count),
+		if count > 1 && !edit.ReplaceAll {
+			return batchEditFailure(ToolResult{
+				Success: false,
+				Output:  fmt.Sprintf("old_string matches %d places in %s; add surrounding context to make it unique, or set replace_all.", count, path),
+				Error:   ErrMultipleMatches,
+				Status:  fmt.Sprintf("fail: %d matches (use replace_all)", count),
+			}, i, len(edits))
 		}
-	}
+		text = applyEditMatches(text, matches)
 
-	var newContent string
-	if replaceAll {
-		newContent = strings.ReplaceAll(string(content), oldStr, newStr)
-	} else {
-		newContent = strings.Replace(string(content), oldStr, newStr, 1)
+		reports[i] = fmt.Sprintf("replaced %d occurrence(s)", count)
+		if tier != matchExact {
+			reports[i] += fmt.Sprintf(" (%s match)", tier)
+		}
 	}
 
-	if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+	diff, err := writeFileDiff(path, []byte(text), dryRun)
+	if err != nil {
 		return ToolResult{
 			Success: false,
 			Error:   err,
@@ -90,9 +157,81 @@ func ExecuteEditFile(path, oldStr, newStr string, replaceAll bool) ToolResult {
 		}
 	}
 
+	output, status := reports[0], "ok"
+	if len(edits) > 1 {
+		for i := range reports {
+			reports[i] = fmt.Sprintf("edit %d: %s", i+1, reports[i])
+		}
+		output, status = strings.Join(reports, "\n"), fmt.Sprintf("ok: %d edits", len(edits))
+	}
+	if dryRun {
+		return previewResult(output, diff)
+	}
 	return ToolResult{
 		Success: true,
-		Output:  fmt.Sprintf("replaced %d occurrence(s)", count),
-		Status:  "ok",
+		Output:  output,
+		Status:  status,
+		Diff:    diff,
+	}
+}
+
+// batchEditFailure labels a failed edit with its position in a batch and
+// notes that nothing was written. Single edits are returned unchanged.
+func batchEditFailure(res ToolResult, i, n int) ToolResult {
+	if n == 1 {
+		return res
+	}
+	label := fmt.Sprintf("edit %d/%d", i+1, n)
+	res.Output = label + ": " + res.Output + " No edits were written."
+	if i > 0 {
+		res.Output += " Line numbers refer to the file after the earlier edits."
+	}
+	res.Error = fmt.Errorf("%s: %w", label, res.Error)
+	res.Status = strings.Replace(res.Status, "fail: ", "fail: "+label+": ", 1)
+	return res
+}
+
+// parseFileEdits reads the edit_file "edits" argument.
+func parseFileEdits(raw []any) []FileEdit {
+	edits := make([]FileEdit, 0, len(raw))
+	for _, item := range raw {
+		m, _ := item.(map[string]any)
+		oldStr, _ := m["old_string"].(string)
+		newStr, _ := m["new_string"].(string)
+		replaceAll, _ := m["replace_all"].(bool)
+		edits = append(edits, FileEdit{OldString: oldStr, NewString: newStr, ReplaceAll: replaceAll})
+	}
+	return edits
+}
+
+// applyEditMatches replaces each match, in order, with its replacement.
+func applyEditMatches(content string, matches []editMatch) string {
+	var sb strings.Builder
+	prev := 0
+	for _, m := range matches {
+		sb.WriteString(content[prev:m.start])
+		sb.WriteString(m.replacement)
+		prev = m.end
+	}
+	sb.WriteString(content[prev:])
+	return sb.String()
+}
+
+// editNotFound reports a failed match, quoting the most similar region of
+// the file with line numbers so the next attempt can copy it exactly.
+func editNotFound(path, content, oldStr string) ToolResult {
+	output := fmt.Sprintf("old_string not found in %s (tried exact, line-ending, trailing-whitespace and indentation-relative matching).", path)
+	status := "fail: string not found"
+	err := ErrStringNotFound
+	if first, last, region := closestEditRegion(content, oldStr); region != "" {
+		output += fmt.Sprintf(" Closest match is lines %d-%d:\n%s", first, last, region)
+		status = fmt.Sprintf("fail: string not found (closest: lines %d-%d)", first, last)
+		err = fmt.Errorf("%w (closest: lines %d-%d)", ErrStringNotFound, first, last)
+	}
+	return ToolResult{
+		Success: false,
+		Output:  output,
+		Error:   err,
+		Status:  status,
 	}
 }
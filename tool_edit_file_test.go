@@ -0,0 +1,191 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteEditFileMatchTiers(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		old, new   string
+		replaceAll bool
+		want       string
+		wantTier   string
+	}{
+		{
+			name:    "exact",
+			content: "a := 1\nb := 2\n",
+			old:     "b := 2",
+			new:     "b := 3",
+			want:    "a := 1\nb := 3\n",
+		},
+		{
+			name:     "crlf file with lf old_string",
+			content:  "one\r\ntwo\r\nthree\r\n",
+			old:      "one\ntwo\n",
+			new:      "ONE\nTWO\n",
+			want:     "ONE\r\nTWO\r\nthree\r\n",
+			wantTier: "line-ending-normalized",
+		},
+		{
+			name:     "mixed line endings keep the crlf after the match",
+			content:  "a\r\nfoo\r\nbar\nbaz\r\n",
+			old:      "a\nfoo",
+			new:      "a\nFOO",
+			want:     "a\r\nFOO\r\nbar\nbaz\r\n",
+			wantTier: "line-ending-normalized",
+		},
+		{
+			name:     "mixed line endings with a match starting at a newline",
+			content:  "a\r\nfoo\r\nbar\n",
+			old:      "\nfoo\n",
+			new:      "\nFOO\n",
+			want:     "a\r\nFOO\r\nbar\n",
+			wantTier: "line-ending-normalized",
+		},
+		{
+			name:     "trailing whitespace in file",
+			content:  "func f() {  \n\treturn 1\t\n}\n",
+			old:      "func f() {\n\treturn 1\n}",
+			new:      "func f() {\n\treturn 2\n}",
+			want:     "func f() {\n\treturn 2\n}\n",
+			wantTier: "trailing-whitespace-insensitive",
+		},
+		{
+			name:     "indentation relative, spaces to tabs",
+			content:  "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n",
+			old:      "if x {\n    y()\n}\n",
+			new:      "if x {\n    y()\n    z()\n}\n",
+			want:     "func f() {\n\tif x {\n\t\ty()\n\t\tz()\n\t}\n}\n",
+			wantTier: "indentation-relative",
+		},
+		{
+			name:       "replace all",
+			content:    "  x()  \n  x()\n",
+			old:        "x()",
+			new:        "y()",
+			replaceAll: true,
+			want:       "  y()  \n  y()\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "f")
+			os.WriteFile(path, []byte(tt.content), 0644)
+
+			res := ExecuteEditFile(path, tt.old, tt.new, tt.replaceAll)
+			if !res.Success {
+				t.Fatalf("edit failed: %v\n%s", res.Error, res.Output)
+			}
+			if got := readTestFile(t, path); got != tt.want {
+				t.Fatalf("content = %q, want %q", got, tt.want)
+			}
+			if tt.wantTier != "" && !strings.Contains(res.Output, tt.wantTier) {
+				t.Fatalf("output %q should mention %s", res.Output, tt.wantTier)
+			}
+		})
+	}
+}
+
+func TestExecuteEditFileNotFoundShowsClosestRegion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f.go")
+	os.WriteFile(path, []byte("package f\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n"), 0644)
+
+	res := ExecuteEditFile(path, "func add(a, b int) int {\n\treturn a - b\n}", "x", false)
+	if res.Success {
+		t.Fatal("expected failure")
+	}
+	if !errors.Is(res.Error, ErrStringNotFound) {
+		t.Fatalf("error = %v, want ErrStringNotFound", res.Error)
+	}
+	if !strings.Contains(res.Output, "lines 3-5") || !strings.Contains(res.Output, "   4 | \treturn a + b") {
+		t.Fatalf("expected closest region with line numbers:\n%s", res.Output)
+	}
+}
+
+func TestExecuteEditFileMultipleMatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(path, []byte("x\nx\n"), 0644)
+
+	res := ExecuteEditFile(path, "x", "y", false)
+	if !errors.Is(res.Error, ErrMultipleMatches) {
+		t.Fatalf("error = %v, want ErrMultipleMatches", res.Error)
+	}
+	if got := readTestFile(t, path); got != "x\nx\n" {
+		t.Fatalf("file modified: %q", got)
+	}
+}
+
+func TestExecuteEditFileBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(path, []byte("a\nb\nb\nc\n"), 0644)
+
+	res := ExecuteEditFileBatch(path, []FileEdit{
+		{OldString: "a", NewString: "A"},
+		{OldString: "b", NewString: "B", ReplaceAll: true},
+		{OldString: "A\nB", NewString: "AB"}, // sees the result of the earlier edits
+	})
+	if !res.Success {
+		t.Fatalf("batch failed: %v\n%s", res.Error, res.Output)
+	}
+	if got := readTestFile(t, path); got != "AB\nB\nc\n" {
+		t.Fatalf("content = %q", got)
+	}
+	want := "edit 1: replaced 1 occurrence(s)\nedit 2: replaced 2 occurrence(s)\nedit 3: replaced 1 occurrence(s)"
+	if res.Output != want {
+		t.Fatalf("output = %q, want %q", res.Output, want)
+	}
+}
+
+func TestExecuteEditFileBatchIsAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(path, []byte("x\ny\ny\n"), 0644)
+
+	tests := []struct {
+		name    string
+		edits   []FileEdit
+		wantErr error
+		label   string
+	}{
+		{"not found", []FileEdit{{OldString: "x", NewString: "X"}, {OldString: "zzz", NewString: "Z"}}, ErrStringNotFound, "edit 2/2"},
+		{"ambiguous", []FileEdit{{OldString: "y", NewString: "Y"}, {OldString: "x", NewString: "X"}}, ErrMultipleMatches, "edit 1/2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ExecuteEditFileBatch(path, tt.edits)
+			if res.Success || !errors.Is(res.Error, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", res.Error, tt.wantErr)
+			}
+			if !strings.HasPrefix(res.Output, tt.label+": ") || !strings.Contains(res.Status, tt.label) {
+				t.Fatalf("failure should name %s: output %q, status %q", tt.label, res.Output, res.Status)
+			}
+			if got := readTestFile(t, path); got != "x\ny\ny\n" {
+				t.Fatalf("file modified: %q", got)
+			}
+		})
+	}
+}
+
+func TestEditFileToolParsesEdits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "f")
+	os.WriteFile(path, []byte("one two\n"), 0644)
+
+	res := EditFileTool().Execute(map[string]any{
+		"path": path,
+		"edits": []any{
+			map[string]any{"old_string": "one", "new_string": "1"},
+			map[string]any{"old_string": "two", "new_string": "2"},
+		},
+	})
+	if !res.Success {
+		t.Fatalf("edit failed: %v", res.Error)
+	}
+	if got := readTestFile(t, path); got != "1 2\n" {
+		t.Fatalf("content = %q", got)
+	}
+}
@@ -10,6 +10,31 @@ import (
 // This tool is auto-approved because planning is a read-only operation;
 // if approval is needed, the subagent's hooks will handle it.
 func EnterPlanModeTool(runSubAgent func(ctx context.Context, projectDesc string) (*SubAgentResult, error)) *ToolDef {
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		desc, ok := args["project_description"].(string)
+		if !ok || desc == "" {
+			return ToolResult{
+				Success: false,
+				Error:   fmt.Errorf("project_description is required"),
+				Status:  "fail: missing project_description",
+			}
+		}
+
+		result, err := runSubAgent(ctx, desc)
+		if err != nil {
+			return ToolResult{
+				Success: false,
+				Error:   err,
+				Status:  fmt.Sprintf("fail: %v", err),
+			}
+		}
+
+		return ToolResult{
+			Success: true,
+			Output:  result.Content,
+			Status:  "plan: created planning document",
+		}
+	}
 	return &ToolDef{
 		Name: "enter_plan_mode",
 		Description: "Starts the planning subagent to create a comprehensive planning document. " +
@@ -26,35 +51,8 @@ func EnterPlanModeTool(runSubAgent func(ctx context.Context, projectDesc string)
 			},
 			"required": []any{"project_description"},
 		},
-		Execute: func(args map[string]any) ToolResult {
-			desc, ok := args["project_description"].(string)
-			if !ok || desc == "" {
-				return ToolResult{
-					Success: false,
-					Error:   fmt.Errorf("project_description is required"),
-					Status:  "fail: missing project_description",
-				}
-			}
-
-			// Use background context for subagent execution
-			// (tool execution doesn't have access to request context)
-			result, err := runSubAgent(context.Background(), desc)
-			if err != nil {
-				return ToolResult{
-					Success: false,
-					Error:   err,
-					Status:  fmt.Sprintf("fail: %v", err),
-				}
-			}
-
-			return ToolResult{
-				Success: true,
-				Output:  result.Content,
-				Status:  "plan: created planning document",
-			}
-		},
-		AutoApprove: func(sandboxed bool) bool {
-			return true // Planning is a read-only operation
-		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     allowPermission("planning is read-only"),
 	}
 }
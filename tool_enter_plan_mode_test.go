@@ -107,19 +107,19 @@ func TestEnterPlanModeTool_SubAgentError(t *testing.T) {
 	}
 }
 
-func TestEnterPlanModeTool_AutoApprove(t *testing.T) {
+func TestEnterPlanModeTool_Permission(t *testing.T) {
 	runSubAgent := func(ctx context.Context, projectDesc string) (*SubAgentResult, error) {
 		return nil, nil
 	}
 
 	tool := EnterPlanModeTool(runSubAgent)
 
-	// AutoApprove should return true for both sandboxed and non-sandboxed
-	if !tool.AutoApprove(true) {
-		t.Error("AutoApprove(true) should return true")
+	// Planning should be allowed for both sandboxed and non-sandboxed calls
+	if tool.Permission(PermissionRequest{Sandboxed: true}).Permission != PermissionAllow {
+		t.Error("sandboxed call should be allowed")
 	}
-	if !tool.AutoApprove(false) {
-		t.Error("AutoApprove(false) should return true")
+	if tool.Permission(PermissionRequest{Sandboxed: false}).Permission != PermissionAllow {
+		t.Error("unsandboxed call should be allowed")
 	}
 }
 
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewToolLeavesFilesUntouched(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeTestFiles(t, ".", map[string]string{"a.txt": "one\ntwo\n"})
+
+	a := &Agent{registry: NewRegistry()}
+	a.registry.Register(WriteFileTool())
+	a.registry.Register(EditFileTool())
+	a.registry.Register(ApplyPatchTool(nil))
+	a.registry.Register(CompactContextTool(nil))
+
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"edit_file", map[string]any{"path": "a.txt", "old_string": "two", "new_string": "2"}, "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"},
+		{"write_file", map[string]any{"path": "new.txt", "content": "hi\n"}, "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,1 @@\n+hi\n"},
+		{"apply_patch", map[string]any{"patch": "*** Begin Patch\n*** Delete File: a.txt\n*** End Patch"}, "--- a/a.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-one\n-two\n"},
+	}
+	for _, tt := range tests {
+		res, ok := a.PreviewTool(tt.name, tt.args)
+		if !ok || !res.Success || res.Status != "preview" {
+			t.Fatalf("%s: preview = %+v, ok=%v", tt.name, res, ok)
+		}
+		if res.Diff != tt.want {
+			t.Errorf("%s: diff =\n%s\nwant\n%s", tt.name, res.Diff, tt.want)
+		}
+		if !strings.HasPrefix(res.Output, "Preview only") {
+			t.Errorf("%s: output = %q", tt.name, res.Output)
+		}
+	}
+
+	if got := readTestFile(t, "a.txt"); got != "one\ntwo\n" {
+		t.Fatalf("a.txt changed by preview: %q", got)
+	}
+	if _, err := os.Stat("new.txt"); !os.IsNotExist(err) {
+		t.Fatal("new.txt created by preview")
+	}
+	entries, _ := os.ReadDir(".")
+	if len(entries) != 1 {
+		t.Fatalf("preview left files behind: %v", entries)
+	}
+
+	if _, ok := a.PreviewTool("compact_context", nil); ok {
+		t.Fatal("expected no preview for compact_context")
+	}
+}
+
+func TestFileToolsReportDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f.txt")
+	os.WriteFile(path, []byte("a\r\nb\r\n"), 0644)
+
+	res := ExecuteEditFile(path, "b", "c", false)
+	if !res.Success {
+		t.Fatalf("edit failed: %v", res.Error)
+	}
+	if !strings.Contains(res.Diff, "-b\r\n+c\r\n") {
+		t.Fatalf("diff = %q", res.Diff)
+	}
+
+	// Rewriting identical content reports no diff.
+	if res := ExecuteWriteFile(path, "a\nc\n"); !res.Success || res.Diff != "" {
+		t.Fatalf("expected empty diff, got %+v", res)
+	}
+}
@@ -1,22 +1,66 @@
 package core
 
 import (
+	"context"
 	"encoding/base64"
+	"errors"
 	"strings"
 )
 
 // PythonRuntimeTool returns the python_runtime tool definition.
 // exec is the shell executor — the agent injects sandbox+fallback handling.
+// Without a kernel, persistent calls fail; use PythonRuntimeToolContext to
+// provide one.
 func PythonRuntimeTool(exec func(req ShellRequest) ToolResult) *ToolDef {
+	if exec == nil {
+		return PythonRuntimeToolContext(nil, nil)
+	}
+	return PythonRuntimeToolContext(func(_ context.Context, req ShellRequest) ToolResult {
+		return exec(req)
+	}, nil)
+}
+
+// PythonRuntimeToolContext is like PythonRuntimeTool, but exec receives the
+// tool call's context, and kernel runs persistent calls and inspect/restart
+// actions in a long-lived Python process.
+func PythonRuntimeToolContext(exec func(ctx context.Context, req ShellRequest) ToolResult, kernel func(ctx context.Context, req PythonKernelRequest) ToolResult) *ToolDef {
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		if isPythonKernelCall(args) {
+			if kernel == nil {
+				return kernelFailure(errors.New("python: persistent process unavailable"), "")
+			}
+			req := PythonKernelRequest{}
+			req.Action, _ = args["action"].(string)
+			req.Code, _ = args["code"].(string)
+			req.Name, _ = args["name"].(string)
+			req.Safety, _ = args["safety"].(string)
+			return kernel(ctx, req)
+		}
+		req := ShellRequestFromToolArgs("python_runtime", args)
+		return exec(ctx, req)
+	}
 	return &ToolDef{
 		Name:        "python_runtime",
-		Description: "Runs a Python script and returns stdout/stderr. Combines multiple operations into a single step — file reads, data processing, shell commands via subprocess, and output formatting all happen inside the script. Intermediate data stays in the script and never enters the conversation. Only the final print() output is returned. Particularly effective for: structured data (JSON, CSV, regex parsing), multi-file operations, aggregation/counting, and any task that would otherwise require multiple sequential tool calls.",
+		Description: "Runs a Python script and returns stdout/stderr. Combines multiple operations into a single step — file reads, data processing, shell commands via subprocess, and output formatting all happen inside the script. Intermediate data stays in the script and never enters the conversation. Only the final print() output is returned. Particularly effective for: structured data (JSON, CSV, regex parsing), multi-file operations, aggregation/counting, and any task that would otherwise require multiple sequential tool calls. Set persistent=true to run in a long-lived Python process whose variables, imports and loaded data survive between calls; there the value of a final expression is printed, action=inspect lists variables (or describes one given by name), and action=restart clears all state. A persistent call that times out is interrupted with KeyboardInterrupt and keeps its state.",
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
 				"code": map[string]any{
 					"type":        "string",
-					"description": "The Python code to execute.",
+					"description": "The Python code to execute. Ignored for inspect and restart.",
+				},
+				"persistent": map[string]any{
+					"type":        "boolean",
+					"description": "Run in the persistent Python process, keeping variables across calls. Defaults to false (fresh process per call).",
+				},
+				"action": map[string]any{
+					"type":        "string",
+					"enum":        []any{"run", "inspect", "restart"},
+					"description": "Persistent process action: run code (default), inspect variables, or restart and clear all state.",
+				},
+				"name": map[string]any{
+					"type":        "string",
+					"description": "For action=inspect: variable or expression to describe. Omit to list all variables.",
 				},
 				"description": map[string]any{
 					"type":        "string",
@@ -30,13 +74,9 @@ func PythonRuntimeTool(exec func(req ShellRequest) ToolResult) *ToolDef {
 			},
 			"required": []any{"code", "description", "safety"},
 		},
-		Execute: func(args map[string]any) ToolResult {
-			req := ShellRequestFromToolArgs("python_runtime", args)
-			return exec(req)
-		},
-		AutoApprove: func(sandboxed bool) bool {
-			return sandboxed && IsSandboxEnabled()
-		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     sandboxedPermission,
 	}
 }
 
@@ -58,3 +98,11 @@ func pythonCommand(code string) string {
 	b.WriteString("PY\n")
 	return b.String()
 }
+
+// isPythonKernelCall reports whether python_runtime arguments ask for the
+// persistent kernel: persistent mode, or an inspect or restart action.
+func isPythonKernelCall(args map[string]any) bool {
+	persistent, _ := args["persistent"].(bool)
+	action, _ := args["action"].(string)
+	return persistent || (action != "" && action != "run")
+}
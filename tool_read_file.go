@@ -8,8 +8,15 @@ import (
 
 const defaultMaxLines = 50
 
-// ReadFileTool returns the read_file tool definition.
+// ReadFileTool returns the read_file tool definition. Under a sandbox, reads outside
+// the allowed paths are blocked.
 func ReadFileTool() *ToolDef {
+	return ReadFileToolWithFallback(nil)
+}
+
+// ReadFileToolWithFallback is like ReadFileTool, but reads outside the allowed paths
+// go ahead when onFallback approves them.
+func ReadFileToolWithFallback(onFallback func(cmd, reason string) bool) *ToolDef {
 	return &ToolDef{
 		Name:        "read_file",
 		Description: "Reads a single file's contents. For files over 100 lines, use line_start/line_end to read in chunks rather than loading the entire file. Start with a small range (e.g., first 50 lines) to understand structure, then read specific sections as needed. Full-file reads waste context and should be avoided.",
@@ -46,11 +53,13 @@ func ReadFileTool() *ToolDef {
 			lineEnd, _ := args["line_end"].(float64)
 			maxLines, _ := args["max_lines"].(float64)
 			showLineNumbers, _ := args["show_line_numbers"].(bool)
+			if blocked := guardFileAccess("read_file", []string{path}, false, onFallback); blocked != nil {
+				return *blocked
+			}
 			return ExecuteReadFile(path, int(lineStart), int(lineEnd), int(maxLines), showLineNumbers)
 		},
-		AutoApprove: func(sandboxed bool) bool {
-			return true
-		},
+		Permission: allowPermission("reads files"),
+		ReadOnly:   true,
 	}
 }
 
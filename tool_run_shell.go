@@ -1,6 +1,9 @@
 package core
 
-import "strings"
+import (
+	"context"
+	"strings"
+)
 
 // explorationPrefixes are command prefixes that indicate the model is exploring the project.
 var explorationPrefixes = []string{
@@ -31,12 +34,35 @@ func explorationNudge() string {
 
 // RunShellTool returns the run_shell tool definition.
 // exec is the shell executor — the agent injects sandbox+fallback handling.
+// Use RunShellToolContext for an executor that can stop the command.
 func RunShellTool(exec func(req ShellRequest) ToolResult) *ToolDef {
+	if exec == nil {
+		return RunShellToolContext(nil)
+	}
+	return RunShellToolContext(func(_ context.Context, req ShellRequest) ToolResult {
+		return exec(req)
+	})
+}
+
+// RunShellToolContext is like RunShellTool, but exec receives the tool
+// call's context and should stop the command when it is done.
+func RunShellToolContext(exec func(ctx context.Context, req ShellRequest) ToolResult) *ToolDef {
 	nudged := false
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		req := ShellRequestFromToolArgs("run_shell", args)
+		result := exec(ctx, req)
+
+		if !nudged && result.Success && !req.Background && isExplorationCommand(req.Command) {
+			result.Output += "\n\n" + explorationNudge()
+			nudged = true
+		}
+
+		return result
+	}
 
 	return &ToolDef{
 		Name:        "run_shell",
-		Description: "Executes a single shell command and returns the output. Use for CLI tools, build commands, git operations, package managers, and file exploration.",
+		Description: "Executes a single shell command and returns the output. Use for CLI tools, build commands, git operations, package managers, and file exploration. For long-running processes such as dev servers and watchers, set background to start a job and get its ID immediately.",
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -53,22 +79,16 @@ func RunShellTool(exec func(req ShellRequest) ToolResult) *ToolDef {
 					"enum":        []any{"read-only", "modify", "destructive", "network", "privileged"},
 					"description": "Safety level: read-only, modify, destructive, network, privileged",
 				},
+				"background": map[string]any{
+					"type":        "boolean",
+					"default":     false,
+					"description": "If true, start the command as a background job with no timeout and return its job ID without waiting. Read its output with shell_job_output and stop it with shell_job_kill.",
+				},
 			},
 			"required": []any{"command", "description", "safety"},
 		},
-		Execute: func(args map[string]any) ToolResult {
-			req := ShellRequestFromToolArgs("run_shell", args)
-			result := exec(req)
-
-			if !nudged && result.Success && isExplorationCommand(req.Command) {
-				result.Output += "\n\n" + explorationNudge()
-				nudged = true
-			}
-
-			return result
-		},
-		AutoApprove: func(sandboxed bool) bool {
-			return sandboxed && IsSandboxEnabled()
-		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     sandboxedPermission,
 	}
 }
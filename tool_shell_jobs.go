@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// ShellJobOutputTool returns the shell_job_output tool definition.
+// output reads a background job's new output, waiting up to wait for some.
+func ShellJobOutputTool(output func(ctx context.Context, id string, wait time.Duration) ToolResult) *ToolDef {
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		id, _ := args["id"].(string)
+		wait, _ := args["wait_seconds"].(float64)
+		return output(ctx, id, time.Duration(wait*float64(time.Second)))
+	}
+	return &ToolDef{
+		Name:        "shell_job_output",
+		Description: "Reads the output a background job (started with run_shell background=true) has produced since the last read, along with whether it is still running or its exit code. Use wait_seconds to wait for output, e.g. until a dev server is listening.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id": map[string]any{
+					"type":        "string",
+					"description": "The job ID returned by run_shell, e.g. 'job-1'",
+				},
+				"wait_seconds": map[string]any{
+					"type":        "number",
+					"description": "If there is no new output yet, wait up to this many seconds (max 30) for some or for the job to exit. Defaults to 0.",
+				},
+			},
+			"required": []any{"id"},
+		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     allowPermission("reads job output"),
+	}
+}
+
+// ShellJobStatusTool returns the shell_job_status tool definition.
+// status describes one job, or all jobs for an empty id.
+func ShellJobStatusTool(status func(id string) ToolResult) *ToolDef {
+	return &ToolDef{
+		Name:        "shell_job_status",
+		Description: "Shows whether background jobs are running or how they exited. Omit id to list every job.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id": map[string]any{
+					"type":        "string",
+					"description": "The job ID to check. Omit to list all jobs.",
+				},
+			},
+		},
+		Execute: func(args map[string]any) ToolResult {
+			id, _ := args["id"].(string)
+			return status(id)
+		},
+		Permission: allowPermission("reads job status"),
+		ReadOnly:   true,
+	}
+}
+
+// ShellJobKillTool returns the shell_job_kill tool definition.
+// kill stops a job and everything it started.
+func ShellJobKillTool(kill func(id string) ToolResult) *ToolDef {
+	return &ToolDef{
+		Name:        "shell_job_kill",
+		Description: "Stops a background job and every process it started. Its remaining output can still be read with shell_job_output.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"id": map[string]any{
+					"type":        "string",
+					"description": "The job ID to stop, e.g. 'job-1'",
+				},
+			},
+			"required": []any{"id"},
+		},
+		Execute: func(args map[string]any) ToolResult {
+			id, _ := args["id"].(string)
+			return kill(id)
+		},
+		Permission: allowPermission("stops a job the agent started"),
+	}
+}
@@ -5,6 +5,18 @@ import "context"
 // WebFetchTool creates a ToolDef for the WebFetch tool.
 // The fetchFn is injected by WebService and handles URL fetching + summarization.
 func WebFetchTool(fetchFn func(ctx context.Context, url, question string) ToolResult) *ToolDef {
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		url, _ := args["url"].(string)
+		if url == "" {
+			return ToolResult{
+				Success: false,
+				Output:  "url parameter is required",
+				Status:  "fail: empty url",
+			}
+		}
+		question, _ := args["question"].(string)
+		return fetchFn(ctx, url, question)
+	}
 	return &ToolDef{
 		Name: "WebFetch",
 		Description: "Fetch and read the content of a specific URL. Use this when you already have a URL " +
@@ -24,20 +36,9 @@ func WebFetchTool(fetchFn func(ctx context.Context, url, question string) ToolRe
 			},
 			"required": []any{"url"},
 		},
-		Execute: func(args map[string]any) ToolResult {
-			url, _ := args["url"].(string)
-			if url == "" {
-				return ToolResult{
-					Success: false,
-					Output:  "url parameter is required",
-					Status:  "fail: empty url",
-				}
-			}
-			question, _ := args["question"].(string)
-			return fetchFn(context.Background(), url, question)
-		},
-		AutoApprove: func(sandboxed bool) bool {
-			return true
-		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     allowPermission("fetches a web page"),
+		ReadOnly:       true,
 	}
 }
@@ -5,6 +5,18 @@ import "context"
 // WebSearchTool creates a ToolDef for the WebSearch tool.
 // The searchFn is injected by WebService and handles routing + backend calls.
 func WebSearchTool(searchFn func(ctx context.Context, query, mode string) ToolResult) *ToolDef {
+	execute := func(ctx context.Context, args map[string]any) ToolResult {
+		query, _ := args["query"].(string)
+		if query == "" {
+			return ToolResult{
+				Success: false,
+				Output:  "query parameter is required",
+				Status:  "fail: empty query",
+			}
+		}
+		mode, _ := args["mode"].(string)
+		return searchFn(ctx, query, mode)
+	}
 	return &ToolDef{
 		Name: "WebSearch",
 		Description: "Search the web for information. " +
@@ -27,20 +39,9 @@ func WebSearchTool(searchFn func(ctx context.Context, query, mode string) ToolRe
 			},
 			"required": []any{"query"},
 		},
-		Execute: func(args map[string]any) ToolResult {
-			query, _ := args["query"].(string)
-			if query == "" {
-				return ToolResult{
-					Success: false,
-					Output:  "query parameter is required",
-					Status:  "fail: empty query",
-				}
-			}
-			mode, _ := args["mode"].(string)
-			return searchFn(context.Background(), query, mode)
-		},
-		AutoApprove: func(sandboxed bool) bool {
-			return true
-		},
+		Execute:        backgroundExecute(execute),
+		ExecuteContext: execute,
+		Permission:     allowPermission("searches the web"),
+		ReadOnly:       true,
 	}
 }
@@ -1,15 +1,17 @@
 package core
 
-import (
-	"os"
-	"path/filepath"
-)
-
-// WriteFileTool returns the write_file tool definition.
+// WriteFileTool returns the write_file tool definition. Under a sandbox, writes outside
+// the allowed paths are blocked.
 func WriteFileTool() *ToolDef {
+	return WriteFileToolWithFallback(nil)
+}
+
+// WriteFileToolWithFallback is like WriteFileTool, but writes outside the allowed paths
+// go ahead when onFallback approves them.
+func WriteFileToolWithFallback(onFallback func(cmd, reason string) bool) *ToolDef {
 	return &ToolDef{
 		Name:        "write_file",
-		Description: "Creates a new file or completely overwrites an existing file with the provided content. Use this only when creating new files or replacing entire file contents. For partial modifications, use edit_file instead. Creates parent directories automatically if they don't exist. File is written with 0644 permissions.",
+		Description: "Creates a new file or completely overwrites an existing file with the provided content. Use this only when creating new files or replacing entire file contents. For partial modifications, use edit_file instead. Creates parent directories automatically if they don't exist. New files get 0644 permissions; an existing file keeps its permissions, owner, line endings (CRLF/LF) and byte order mark.",
 		Parameters: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
@@ -27,24 +29,33 @@ func WriteFileTool() *ToolDef {
 		Execute: func(args map[string]any) ToolResult {
 			path, _ := args["path"].(string)
 			content, _ := args["content"].(string)
+			if blocked := guardFileAccess("write_file", []string{path}, true, onFallback); blocked != nil {
+				return *blocked
+			}
 			return ExecuteWriteFile(path, content)
 		},
-		AutoApprove: func(sandboxed bool) bool {
-			return false
+		Permission:  askPermission("modifies files"),
+		WritesFiles: pathArg,
+		Preview: func(args map[string]any) ToolResult {
+			path, _ := args["path"].(string)
+			content, _ := args["content"].(string)
+			if blocked := guardFileAccess("write_file", []string{path}, false, nil); blocked != nil {
+				return *blocked
+			}
+			return writeFile(path, content, true)
 		},
 	}
 }
 
 // ExecuteWriteFile writes content to a file, creating parent directories if needed.
+// The write is atomic; an existing file keeps its mode, owner, BOM and line endings.
 func ExecuteWriteFile(path, content string) ToolResult {
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
-		return ToolResult{
-			Success: false,
-			Error:   err,
-			Status:  "fail: " + err.Error(),
-		}
-	}
-	err := os.WriteFile(path, []byte(content), 0644)
+	return writeFile(path, content, false)
+}
+
+// writeFile implements ExecuteWriteFile; with dryRun set it only reports the diff.
+func writeFile(path, content string, dryRun bool) ToolResult {
+	diff, err := writeFileDiff(path, []byte(content), dryRun)
 	if err != nil {
 		return ToolResult{
 			Success: false,
@@ -52,9 +63,13 @@ func ExecuteWriteFile(path, content string) ToolResult {
 			Status:  "fail: " + err.Error(),
 		}
 	}
+	if dryRun {
+		return previewResult("ok", diff)
+	}
 	return ToolResult{
 		Success: true,
 		Output:  "ok",
 		Status:  "written",
+		Diff:    diff,
 	}
 }
@@ -1,48 +1,99 @@
 package core
 
-import "strings"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
 
 // ExecuteShell runs a shell command using the configured executor (sandboxed or passthrough).
 func ExecuteShell(command string) ToolResult {
-	result, _ := GetExecutor().Run(command)
+	return ExecuteShellContext(context.Background(), command)
+}
+
+// ExecuteShellContext is like ExecuteShell but kills the command when ctx is done.
+func ExecuteShellContext(ctx context.Context, command string) ToolResult {
+	result, _ := runShellExecutor(ctx, GetExecutor(), command, nil)
 	return result
 }
 
 // ExecuteShellUnsandboxed runs a shell command directly without sandboxing.
 // Used for fallback when sandbox blocks a command and user approves unsandboxed execution.
 func ExecuteShellUnsandboxed(command string) ToolResult {
+	return ExecuteShellUnsandboxedContext(context.Background(), command)
+}
+
+// ExecuteShellUnsandboxedContext is like ExecuteShellUnsandboxed but kills the command when ctx is done.
+func ExecuteShellUnsandboxedContext(ctx context.Context, command string) ToolResult {
+	return executeShellUnsandboxed(ctx, command, nil)
+}
+
+func executeShellUnsandboxed(ctx context.Context, command string, onOutput ShellOutputFunc) ToolResult {
 	passthrough := &PassthroughExecutor{}
-	result, _ := passthrough.Run(command)
+	result, _ := passthrough.RunStream(ctx, command, onOutput)
 	return result
 }
 
 // ExecuteShellRequest routes a shell-backed request according to policy.
 func ExecuteShellRequest(req ShellRequest, policy ShellPolicy, onFallback func(cmd, reason string) bool) ToolResult {
+	return ExecuteShellRequestContext(context.Background(), req, policy, onFallback)
+}
+
+// ExecuteShellRequestContext is like ExecuteShellRequest but kills the command when ctx is done.
+// Output is streamed to req.OnOutput, if set, while the command runs.
+func ExecuteShellRequestContext(ctx context.Context, req ShellRequest, policy ShellPolicy, onFallback func(cmd, reason string) bool) ToolResult {
 	decision := DecideShellRequest(policy, req)
-	if decision.Route == ShellRouteHostDirect {
-		return ExecuteShellUnsandboxed(req.Command)
+	switch decision.Route {
+	case ShellRouteDeny:
+		return deniedResult(decision)
+	case ShellRouteHostDirect:
+		return executeShellUnsandboxed(ctx, req.Command, req.OnOutput)
+	}
+	return executeShellWithSandbox(ctx, req.Command, onFallback, req.OnOutput)
+}
+
+// deniedResult reports a command the shell policy denied, with its reason.
+func deniedResult(decision ShellDecision) ToolResult {
+	err := fmt.Errorf("%w: %s", ErrCommandDenied, decision.Reason)
+	return ToolResult{
+		Success: false,
+		Output:  "Command denied by policy: " + decision.Reason + ". Do not retry it in another form; ask the user if it is needed.",
+		Error:   err,
+		Status:  "denied",
 	}
-	return ExecuteShellWithSandbox(req.Command, onFallback)
 }
 
 // ExecuteShellWithSandbox executes a shell command with sandbox support.
 // If a sandboxed execution fails and fallback is enabled, onFallback can approve
 // re-running the command outside the sandbox.
 func ExecuteShellWithSandbox(command string, onFallback func(cmd, reason string) bool) ToolResult {
+	return ExecuteShellWithSandboxContext(context.Background(), command, onFallback)
+}
+
+// ExecuteShellWithSandboxContext is like ExecuteShellWithSandbox but kills the
+// command when ctx is done. A cancelled command never offers host fallback.
+func ExecuteShellWithSandboxContext(ctx context.Context, command string, onFallback func(cmd, reason string) bool) ToolResult {
+	return executeShellWithSandbox(ctx, command, onFallback, nil)
+}
+
+func executeShellWithSandbox(ctx context.Context, command string, onFallback func(cmd, reason string) bool, onOutput ShellOutputFunc) ToolResult {
 	if !IsSandboxEnabled() {
 		// No sandbox available - execute directly
-		return ExecuteShellUnsandboxed(command)
+		return executeShellUnsandboxed(ctx, command, onOutput)
 	}
 
 	// Try sandboxed execution
-	result := ExecuteShell(command)
+	result, _ := runShellExecutor(ctx, GetExecutor(), command, onOutput)
+	if ctx.Err() != nil {
+		return result
+	}
 
 	// Any sandboxed failure can offer host fallback. Some sandboxed network failures
 	// surface as normal command errors (for example DNS resolution) rather than explicit
 	// sandbox denials, so relying on SandboxError alone misses the approval path.
 	if shouldOfferSandboxFallback(result) {
 		if onFallback != nil && onFallback(command, sandboxFallbackReason(result)) {
-			return ExecuteShellUnsandboxed(command)
+			return executeShellUnsandboxed(ctx, command, onOutput)
 		}
 		return result
 	}
@@ -1,9 +1,12 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"os/exec"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestExecutePython(t *testing.T) {
@@ -25,3 +28,138 @@ func TestExecutePython(t *testing.T) {
 		t.Fatalf("expected output to contain 2, got: %s", result.Output)
 	}
 }
+
+func TestPythonRuntimeToolWithoutKernel(t *testing.T) {
+	var ran []string
+	tool := PythonRuntimeTool(func(req ShellRequest) ToolResult {
+		ran = append(ran, req.Command)
+		return ToolResult{Success: true}
+	})
+
+	if res := tool.Execute(map[string]any{"code": "print(1)"}); !res.Success {
+		t.Fatalf("fresh call failed: %v", res.Error)
+	}
+	if res := tool.Execute(map[string]any{"code": "x = 1", "persistent": true}); res.Success {
+		t.Fatal("persistent call succeeded without a kernel")
+	}
+	if len(ran) != 1 {
+		t.Fatalf("exec ran %d times, want 1", len(ran))
+	}
+}
+
+func TestPythonKernelKeepsState(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found in PATH")
+	}
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	k := &pythonKernel{}
+	t.Cleanup(k.close)
+	ctx := context.Background()
+	run := func(req PythonKernelRequest) ToolResult {
+		t.Helper()
+		return k.call(ctx, req, 10*time.Second)
+	}
+
+	if res := run(PythonKernelRequest{Code: "import json\nx = [1, 2, 3]\nprint('set')"}); !res.Success || res.Output != "set" {
+		t.Fatalf("first run = %+v", res)
+	}
+	if res := run(PythonKernelRequest{Code: "sum(x) * 2"}); !res.Success || res.Output != "12" {
+		t.Fatalf("second run = %+v", res)
+	}
+	if res := run(PythonKernelRequest{Action: "inspect"}); !strings.Contains(res.Output, "x: list = [1, 2, 3]") || !strings.Contains(res.Output, "modules: json") {
+		t.Fatalf("inspect = %q", res.Output)
+	}
+	if res := run(PythonKernelRequest{Action: "inspect", Name: "x"}); !strings.Contains(res.Output, "len: 3") {
+		t.Fatalf("inspect x = %q", res.Output)
+	}
+
+	res := run(PythonKernelRequest{Code: "1/0"})
+	if res.Success || !strings.Contains(res.Output, "ZeroDivisionError") || strings.Contains(res.Output, "_run") {
+		t.Fatalf("error run = %+v", res)
+	}
+
+	run(PythonKernelRequest{Action: "restart"})
+	if res := run(PythonKernelRequest{Code: "x"}); res.Success || !strings.Contains(res.Output, "NameError") {
+		t.Fatalf("x survived restart: %+v", res)
+	}
+}
+
+func TestPythonKernelRoutesEachCall(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found in PATH")
+	}
+	if !sandboxAvailable() {
+		t.Skip("sandbox not available")
+	}
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(DefaultSandboxConfig())
+
+	policy := RuleBasedShellPolicy(func(req ShellRequest) (ShellDecision, bool) {
+		switch req.Safety {
+		case "network":
+			return ShellDecision{Route: ShellRouteHostDirect}, true
+		case "destructive":
+			return ShellDecision{Route: ShellRouteDeny, Reason: "no destructive python"}, true
+		}
+		return ShellDecision{}, false
+	})
+	k := &pythonKernel{policy: policy}
+	t.Cleanup(k.close)
+	ctx := context.Background()
+	run := func(req PythonKernelRequest) ToolResult {
+		t.Helper()
+		return k.call(ctx, req, 10*time.Second)
+	}
+
+	if res := run(PythonKernelRequest{Code: "x = 1", Safety: "read_only"}); !res.Success || !res.ExecMeta.Sandboxed {
+		t.Fatalf("sandboxed run = %+v", res)
+	}
+	res := run(PythonKernelRequest{Code: "print(x)", Safety: "destructive"})
+	if !errors.Is(res.Error, ErrCommandDenied) {
+		t.Fatalf("denied run = %+v", res)
+	}
+	if res := run(PythonKernelRequest{Code: "x", Safety: "read_only"}); res.Output != "1" {
+		t.Fatalf("kernel lost state after a denied call: %+v", res)
+	}
+
+	res = run(PythonKernelRequest{Code: "'x' in globals()", Safety: "network"})
+	if !res.Success || res.ExecMeta.Sandboxed || !strings.Contains(res.Output, "routed on the host") || !strings.HasSuffix(res.Output, "False") {
+		t.Fatalf("host run reused the sandboxed kernel: %+v", res)
+	}
+	res = run(PythonKernelRequest{Code: "1", Safety: "read_only"})
+	if !res.Success || !res.ExecMeta.Sandboxed || !strings.Contains(res.Output, "routed in the sandbox") {
+		t.Fatalf("sandboxed run reused the host kernel: %+v", res)
+	}
+}
+
+func TestPythonKernelTimeoutInterrupts(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not found in PATH")
+	}
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(SandboxConfig{})
+
+	k := &pythonKernel{}
+	t.Cleanup(k.close)
+	ctx := context.Background()
+
+	k.call(ctx, PythonKernelRequest{Code: "n = 0"}, 10*time.Second)
+	res := k.call(ctx, PythonKernelRequest{Code: "import time\nwhile True:\n    n += 1\n    time.sleep(0.01)"}, 300*time.Millisecond)
+	if res.Success || !res.ExecMeta.TimedOut || !strings.Contains(res.Output, "KeyboardInterrupt") || !strings.Contains(res.Output, "Interrupted: timed out") {
+		t.Fatalf("timed out run = %+v", res)
+	}
+	if res := k.call(ctx, PythonKernelRequest{Code: "n > 0"}, 10*time.Second); res.Output != "True" {
+		t.Fatalf("state lost after interrupt: %+v", res)
+	}
+
+	k.proc.stop()
+	res = k.call(ctx, PythonKernelRequest{Code: "print('back')"}, 10*time.Second)
+	if !res.Success || !strings.Contains(res.Output, "kernel had exited") || !strings.HasSuffix(res.Output, "back") {
+		t.Fatalf("after exit = %+v", res)
+	}
+}
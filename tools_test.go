@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -131,3 +133,76 @@ func TestExecuteShellWithSandboxFallbackOnTimeout(t *testing.T) {
 		t.Fatalf("expected timeout status, got %q", result.Status)
 	}
 }
+
+func TestExecuteShellWithSandboxContextCancelSkipsFallback(t *testing.T) {
+	prev := defaultExecutor
+	t.Cleanup(func() { defaultExecutor = prev })
+	InitSandbox(DefaultSandboxConfig())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	result := ExecuteShellWithSandboxContext(ctx, "sleep 5 & wait", func(cmd, reason string) bool {
+		t.Fatal("fallback should not be offered for a cancelled command")
+		return true
+	})
+
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected cancellation to kill the command promptly, took %s", elapsed)
+	}
+	if result.Success {
+		t.Fatal("expected cancelled command to fail")
+	}
+	if !errors.Is(result.Error, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", result.Error)
+	}
+	if !strings.Contains(result.Status, "cancelled") {
+		t.Fatalf("expected cancelled status, got %q", result.Status)
+	}
+}
+
+func TestToolDefRunPrefersExecuteContext(t *testing.T) {
+	type ctxKey struct{}
+	tool := &ToolDef{
+		Name: "ctx_tool",
+		Execute: func(args map[string]any) ToolResult {
+			t.Fatal("Execute should not be called when ExecuteContext is set")
+			return ToolResult{}
+		},
+		ExecuteContext: func(ctx context.Context, args map[string]any) ToolResult {
+			v, _ := ctx.Value(ctxKey{}).(string)
+			return ToolResult{Success: true, Output: v}
+		},
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "from-ctx")
+	if got := tool.Run(ctx, nil); got.Output != "from-ctx" {
+		t.Fatalf("expected ExecuteContext output, got %q", got.Output)
+	}
+}
+
+func TestToolDefRunSkipsLegacyExecuteWhenCancelled(t *testing.T) {
+	calls := 0
+	tool := &ToolDef{
+		Name: "legacy_tool",
+		Execute: func(args map[string]any) ToolResult {
+			calls++
+			return ToolResult{Success: true, Output: "ran"}
+		},
+	}
+
+	if got := tool.Run(context.Background(), nil); got.Output != "ran" {
+		t.Fatalf("expected legacy Execute to run, got %q", got.Output)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := tool.Run(ctx, nil)
+	if calls != 1 {
+		t.Fatalf("expected legacy Execute to be skipped after cancellation, calls=%d", calls)
+	}
+	if result.Success || !errors.Is(result.Error, context.Canceled) {
+		t.Fatalf("expected cancelled result, got %+v", result)
+	}
+}
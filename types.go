@@ -1,6 +1,12 @@
 // Package core provides the agent loop and API client for building CLI/TUI agents.
 package core
 
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
 // Message represents a message in the conversation history.
 type Message struct {
 	Role       string     `json:"role"`
@@ -59,13 +65,20 @@ type ToolResult struct {
 	Status   string    // Human-readable status for display
 	Error    error     // Error if execution failed
 	ExecMeta *ExecMeta // Execution metadata (non-nil for shell tools)
+	Diff     string    // Unified diff of the files changed, or that a preview would change
 }
 
 // ExecMeta contains metadata about shell command execution.
 type ExecMeta struct {
-	Sandboxed     bool   // true if command ran inside sandbox
-	SandboxError  bool   // true if sandbox blocked execution
-	SandboxReason string // reason if sandbox blocked (e.g., "write outside cwd")
+	Sandboxed     bool          // true if command ran inside sandbox
+	SandboxError  bool          // true if sandbox blocked execution
+	SandboxReason string        // reason if sandbox blocked (e.g., "write outside cwd")
+	ExitCode      int           // process exit code; -1 if killed by a signal or never started
+	Signal        string        // signal that killed the process (e.g., "killed"), if any
+	Duration      time.Duration // wall time from start to exit
+	TimedOut      bool          // true if CommandTimeout killed the command
+	Stdout        string        // standard output alone
+	Stderr        string        // standard error alone
 }
 
 // ToolDef is a self-contained tool definition: schema + execution + policy.
@@ -76,7 +89,73 @@ type ToolDef struct {
 	Description string
 	Parameters  map[string]any
 	Execute     func(args map[string]any) ToolResult
-	AutoApprove func(sandboxed bool) bool
+	// ExecuteContext is the context-aware form of Execute. When set, Run prefers it
+	// so cancelling the chat context stops child processes and in-flight HTTP calls.
+	ExecuteContext func(ctx context.Context, args map[string]any) ToolResult
+	// Permission is the tool's default decision for a call, used when no
+	// Config.PermissionRules rule matches. Nil asks.
+	Permission func(req PermissionRequest) PermissionDecision
+	// ReadOnly marks tools without side effects that are safe to run concurrently
+	// with other ReadOnly calls when Config.MaxParallelToolCalls > 1.
+	ReadOnly bool
+	// WritesFiles returns the paths a call will modify so the agent can
+	// checkpoint them before Run. Nil for tools that don't write files.
+	WritesFiles func(args map[string]any) []string
+	// Preview computes a call's result, including Diff, without side effects,
+	// so approval hooks can show a change before it is applied. Nil for tools
+	// that can't preview.
+	Preview func(args map[string]any) ToolResult
+}
+
+// Run executes the tool with ctx. Tools that only define Execute are adapted:
+// they cannot be interrupted, but are skipped when ctx is already done.
+func (t *ToolDef) Run(ctx context.Context, args map[string]any) ToolResult {
+	if t.ExecuteContext != nil {
+		return t.ExecuteContext(ctx, args)
+	}
+	if err := ctx.Err(); err != nil {
+		return cancelledToolResult(err)
+	}
+	if t.Execute == nil {
+		return ToolResult{Success: false, Error: fmt.Errorf("tool %s has no execute function", t.Name), Status: "fail: not executable"}
+	}
+	return t.Execute(args)
+}
+
+// pathArg returns the "path" argument as a one-element list, for WritesFiles.
+func pathArg(args map[string]any) []string {
+	if path, _ := args["path"].(string); path != "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// backgroundExecute adapts a context-aware execute function to the legacy
+// Execute signature for callers that invoke tools without a context.
+func backgroundExecute(fn func(ctx context.Context, args map[string]any) ToolResult) func(args map[string]any) ToolResult {
+	return func(args map[string]any) ToolResult {
+		return fn(context.Background(), args)
+	}
+}
+
+// cancelledToolResult reports a tool call aborted by context cancellation.
+func cancelledToolResult(err error) ToolResult {
+	return ToolResult{
+		Success: false,
+		Output:  "cancelled: " + err.Error(),
+		Error:   err,
+		Status:  "cancelled",
+	}
+}
+
+// previewResult reports what a dry run of a file-changing tool would do.
+func previewResult(output, diff string) ToolResult {
+	return ToolResult{
+		Success: true,
+		Output:  "Preview only, no files were changed. " + output,
+		Status:  "preview",
+		Diff:    diff,
+	}
 }
 
 // Tool returns the API-ready Tool struct for sending to the LLM.
@@ -84,14 +84,20 @@ func NewWebService(cfg WebConfig) (*WebService, error) {
 	}
 
 	transport := &capturingTransport{base: http.DefaultTransport}
-	inner, err := llm.NewCompletionsClient(llm.Config{
+	llmConfig := llm.Config{
 		APIKey:      cfg.APIKey,
 		BaseURL:     cfg.BaseURL,
 		HTTPClient:  &http.Client{Transport: transport},
 		HTTPReferer: "https://webforspeed.com",
 		AppTitle:    "webforspeed Bono",
 		Categories:  "cli-agent",
-	})
+	}
+	if cfg.APILogPath != "" {
+		llmConfig.OnRetry = func(a llm.RetryAttempt) {
+			logRetryAttempt(cfg.APILogPath, transport.lastCapture(), a)
+		}
+	}
+	inner, err := llm.NewCompletionsClient(llmConfig)
 	if err != nil {
 		return nil, fmt.Errorf("web: create provider: %w", err)
 	}
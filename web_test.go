@@ -254,8 +254,8 @@ func TestWebSearchToolDef(t *testing.T) {
 	if tool.Name != "WebSearch" {
 		t.Errorf("unexpected name: %s", tool.Name)
 	}
-	if !tool.AutoApprove(false) {
-		t.Error("WebSearch should auto-approve")
+	if tool.Permission(PermissionRequest{}).Permission != PermissionAllow {
+		t.Error("WebSearch should be allowed")
 	}
 
 	result := tool.Execute(map[string]any{"query": "test query"})
@@ -295,8 +295,8 @@ func TestWebFetchToolDef(t *testing.T) {
 	if tool.Name != "WebFetch" {
 		t.Errorf("unexpected name: %s", tool.Name)
 	}
-	if !tool.AutoApprove(false) {
-		t.Error("WebFetch should auto-approve")
+	if tool.Permission(PermissionRequest{}).Permission != PermissionAllow {
+		t.Error("WebFetch should be allowed")
 	}
 
 	result := tool.Execute(map[string]any{"url": "https://example.com", "question": "what is it"})